@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ControlAPI local http status, metrics and control, config api.listen
+type ControlAPI struct {
+	server *http.Server
+	comp   *Compositor
+	scenes *SceneScheduler
+	cpu    *CPUStat
+	notify *Notifier
+	mux    sync.RWMutex
+}
+
+// NewControlAPI creates the API server for the compositor and scheduler
+func NewControlAPI(listen string, comp *Compositor, scenes *SceneScheduler, cpu *CPUStat, notify *Notifier) *ControlAPI {
+
+	api := &ControlAPI{
+		comp:   comp,
+		scenes: scenes,
+		cpu:    cpu,
+		notify: notify,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/frame.png`, api.frame)
+	mux.HandleFunc(`/status`, api.status)
+	mux.HandleFunc(`/scene`, api.scene)
+	mux.HandleFunc(`/brightness`, api.brightness)
+	mux.HandleFunc(`/toggle/`, api.toggle)
+	mux.HandleFunc(`/notify`, api.notification)
+	mux.HandleFunc(`/lms/`, api.control)
+	mux.Handle(`/metrics`, metrics)
+
+	api.server = &http.Server{
+		Addr:         listen,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return api
+
+}
+
+// Start serves in the background
+func (api *ControlAPI) Start() {
+	go func() {
+		err := api.server.ListenAndServe()
+		if nil != err && http.ErrServerClosed != err {
+			logAPI.Error(`serve failed`, `listen`, api.server.Addr, `err`, err)
+		}
+	}()
+}
+
+// Close stops the server
+func (api *ControlAPI) Close() error {
+	return api.server.Close()
+}
+
+// Attach switches to the modules of a reloaded clock
+func (api *ControlAPI) Attach(comp *Compositor, scenes *SceneScheduler, cpu *CPUStat) {
+	api.mux.Lock()
+	api.comp, api.scenes, api.cpu = comp, scenes, cpu
+	api.mux.Unlock()
+}
+
+func (api *ControlAPI) parts() (*Compositor, *SceneScheduler, *CPUStat) {
+	api.mux.RLock()
+	defer api.mux.RUnlock()
+	return api.comp, api.scenes, api.cpu
+}
+
+// frame GET the composed frame as PNG
+func (api *ControlAPI) frame(rw http.ResponseWriter, req *http.Request) {
+	if http.MethodGet != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set(`Content-Type`, `image/png`)
+	rw.Header().Set(`Cache-Control`, `no-cache`)
+	comp, _, _ := api.parts()
+	png.Encode(rw, comp.Snapshot())
+}
+
+// status GET the module state as JSON
+func (api *ControlAPI) status(rw http.ResponseWriter, req *http.Request) {
+
+	if http.MethodGet != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	comp, scenes, cpu := api.parts()
+	scene := ``
+	if sc := scenes.Current(); nil != sc {
+		scene = sc.Name
+	}
+	st := comp.Stats()
+	wc := currentWeather().Current
+	dl, next := currentDaylight()
+
+	status := map[string]interface{}{
+		`time`:       timeNow(),
+		`layout`:     strings.ToLower(currentConfig().RGB.Layout),
+		`scene`:      scene,
+		`scenes`:     scenes.Names(),
+		`brightness`: currentBrightness(),
+		`dimmer`:     dimmer.Status(),
+		`daylight`:   dl.isdaylight,
+		`sun`:        sunStatus(),
+		`capture`:    capture.Get(),
+		`instrument`: instrument.Get(),
+		`experiment`: experiment.Get(),
+		`frames`: map[string]interface{}{
+			`frames`:   st.Frames,
+			`composed`: st.Composed,
+			`overruns`: st.Overruns,
+			`last_ms`:  st.Last.Seconds() * 1000,
+			`avg_ms`:   st.Average.Seconds() * 1000,
+			`fps`:      st.FPS,
+		},
+		`weather`: map[string]interface{}{
+			`current`: wc,
+			`tempF`:   wc.tempF,
+			`tempC`:   wc.tempC,
+			`next`:    next,
+		},
+		`cpu`:    cpu.Status(),
+		`health`: health.Status(),
+	}
+	lms, news, transit := currentSources()
+	if nil != lms {
+		status[`lms`] = lms.Status()
+	}
+	if nil != transit {
+		status[`mbta`] = transit.Status()
+	}
+	if nil != news {
+		status[`news`] = news.Status()
+	}
+
+	rw.Header().Set(`Content-Type`, `application/json`)
+	enc := json.NewEncoder(rw)
+	enc.SetIndent(``, `  `)
+	enc.Encode(status)
+
+}
+
+// scene POST name=<scene>|none, optional for=<duration>; an empty name
+// returns to scheduling
+func (api *ControlAPI) scene(rw http.ResponseWriter, req *http.Request) {
+	if http.MethodPost != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	until, err := expiry(req.FormValue(`for`))
+	if nil == err {
+		_, scenes, _ := api.parts()
+		err = scenes.Force(req.FormValue(`name`), until)
+	}
+	if nil != err {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// brightness POST value=0..100|auto, optional for=<duration>, without it
+// brightness.override or until auto
+func (api *ControlAPI) brightness(rw http.ResponseWriter, req *http.Request) {
+
+	if http.MethodPost != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	value := req.FormValue(`value`)
+	if `auto` == value || `` == value {
+		dimmer.Clear()
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	b, err := strconv.Atoi(value)
+	if nil == err && (b < 0 || b > 100) {
+		err = fmt.Errorf("brightness must be 0..100, have %d", b)
+	}
+	var until time.Time
+	if nil == err {
+		until, err = expiry(req.FormValue(`for`))
+	}
+	if nil != err {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dimmer.Override(b, until)
+	rw.WriteHeader(http.StatusNoContent)
+
+}
+
+// toggle POST /toggle/capture|instrument|experiment, optional value=true|false
+func (api *ControlAPI) toggle(rw http.ResponseWriter, req *http.Request) {
+
+	if http.MethodPost != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var flag *switchFlag
+	switch strings.TrimPrefix(req.URL.Path, `/toggle/`) {
+	case `capture`:
+		flag = &capture
+	case `instrument`:
+		flag = &instrument
+	case `experiment`:
+		flag = &experiment
+	default:
+		http.NotFound(rw, req)
+		return
+	}
+
+	v := !flag.Get()
+	if s := req.FormValue(`value`); `` != s {
+		var err error
+		v, err = strconv.ParseBool(s)
+		if nil != err {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	flag.Set(v)
+
+	rw.Header().Set(`Content-Type`, `application/json`)
+	json.NewEncoder(rw).Encode(map[string]bool{`value`: v})
+
+}
+
+// notification POST text=<message>, optional for=<duration>; empty clears
+func (api *ControlAPI) notification(rw http.ResponseWriter, req *http.Request) {
+	if http.MethodPost != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	text := req.FormValue(`text`)
+	if `` == text {
+		api.notify.Clear()
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var d time.Duration
+	if f := req.FormValue(`for`); `` != f {
+		var err error
+		if d, err = time.ParseDuration(f); nil != err {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	api.notify.Notify(text, d)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// control POST /lms/<command>, optional value=, a transport command to
+// the player shown
+func (api *ControlAPI) control(rw http.ResponseWriter, req *http.Request) {
+	if http.MethodPost != req.Method {
+		http.Error(rw, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	command := strings.TrimPrefix(req.URL.Path, `/lms/`)
+	if _, err := lmsRequest(command, req.FormValue(`value`)); nil != err {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lms, _, _ := currentSources()
+	if err := lms.Control(command, req.FormValue(`value`)); nil != err {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// expiry parses an optional duration from now, zero time when empty
+func expiry(d string) (time.Time, error) {
+	if `` == d {
+		return time.Time{}, nil
+	}
+	dur, err := time.ParseDuration(d)
+	if nil != err {
+		return time.Time{}, err
+	}
+	return timeNow().Add(dur), nil
+}
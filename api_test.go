@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testAPI the API handler on a scheduler without scenes, the dimmer and
+// capture switch are restored by the func returned
+func testAPI(t *testing.T) (http.Handler, func()) {
+	scenes, err := NewSceneScheduler(NewWidgetRegistry(), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dm, cp := dimmer, capture.Get()
+	dimmer = NewDimmer()
+	api := NewControlAPI(`127.0.0.1:0`, nil, scenes, &CPUStat{}, NewNotifier())
+	return api.server.Handler, func() {
+		dimmer = dm
+		capture.Set(cp)
+	}
+}
+
+func TestAPIMethods(t *testing.T) {
+
+	h, restore := testAPI(t)
+	defer restore()
+
+	for _, tc := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, `/status`},
+		{http.MethodPost, `/frame.png`},
+		{http.MethodGet, `/scene`},
+		{http.MethodGet, `/brightness`},
+		{http.MethodPut, `/brightness`},
+		{http.MethodGet, `/toggle/capture`},
+		{http.MethodGet, `/notify`},
+		{http.MethodGet, `/lms/play`},
+	} {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+		if http.StatusMethodNotAllowed != rw.Code {
+			t.Errorf("%s %s: %d, want %d", tc.method, tc.path, rw.Code, http.StatusMethodNotAllowed)
+		}
+	}
+
+}
+
+func TestAPIRequests(t *testing.T) {
+
+	h, restore := testAPI(t)
+	defer restore()
+
+	for _, tc := range []struct {
+		path string
+		form url.Values
+		want int
+	}{
+		{`/brightness`, url.Values{`value`: {`50`}}, http.StatusNoContent},
+		{`/brightness`, url.Values{`value`: {`50`}, `for`: {`10m`}}, http.StatusNoContent},
+		{`/brightness`, url.Values{`value`: {`auto`}}, http.StatusNoContent},
+		{`/brightness`, url.Values{`value`: {`bright`}}, http.StatusBadRequest},
+		{`/brightness`, url.Values{`value`: {`101`}}, http.StatusBadRequest},
+		{`/brightness`, url.Values{`value`: {`-1`}}, http.StatusBadRequest},
+		{`/brightness`, url.Values{`value`: {`50`}, `for`: {`soon`}}, http.StatusBadRequest},
+		{`/scene`, url.Values{`name`: {`none`}}, http.StatusNoContent},
+		{`/scene`, url.Values{}, http.StatusNoContent},
+		{`/scene`, url.Values{`name`: {`nosuch`}}, http.StatusBadRequest},
+		{`/scene`, url.Values{`name`: {`none`}, `for`: {`soon`}}, http.StatusBadRequest},
+		{`/toggle/capture`, url.Values{`value`: {`false`}}, http.StatusOK},
+		{`/toggle/capture`, url.Values{`value`: {`maybe`}}, http.StatusBadRequest},
+		{`/toggle/nosuch`, url.Values{}, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.form.Encode()))
+		req.Header.Set(`Content-Type`, `application/x-www-form-urlencoded`)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if tc.want != rw.Code {
+			t.Errorf("POST %s %s: %d %q, want %d", tc.path, tc.form.Encode(), rw.Code, strings.TrimSpace(rw.Body.String()), tc.want)
+		}
+	}
+
+	if capture.Get() {
+		t.Error(`capture still on after value=false`)
+	}
+
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"fmt"
 	"image"
 	"image/jpeg"
 	"io/ioutil"
@@ -48,7 +47,7 @@ func InitImageCache(base string, cleanup bool) *CACache {
 }
 
 func (car *CACache) isOlder(t time.Time, d time.Duration) bool {
-	return time.Now().Sub(t) > d
+	return timeNow().Sub(t) > d
 }
 
 func (car *CACache) cleanup() (err error) {
@@ -73,11 +72,18 @@ func (car *CACache) cleanup() (err error) {
 
 // Close the diskv client connection
 func (car *CACache) Close() {
-	fmt.Println(`not implemented`)
+	logCache.Debug(`close not implemented`)
 }
 
 // Get returns the response corresponding to key if present.
 func (car *CACache) Get(key string) (resp []byte, ok bool) {
+	defer func() {
+		if ok {
+			mCacheHits.Inc()
+		} else {
+			mCacheMisses.Inc()
+		}
+	}()
 	ok = car.conn.Has(key)
 	if ok {
 		resp, _ = car.conn.Read(key)
@@ -89,7 +95,7 @@ func (car *CACache) Get(key string) (resp []byte, ok bool) {
 		}
 
 		car.mu.Lock()
-		if car.MaxAge > 0 && le.Value.(*entry).expires <= time.Now().Unix() {
+		if car.MaxAge > 0 && le.Value.(*entry).expires <= timeNow().Unix() {
 			car.deleteElement(le)
 			car.maybeDeleteOldest()
 			car.mu.Unlock() // Avoiding defer overhead
@@ -112,7 +118,7 @@ func (car *CACache) GetImage(key string) (im image.Image, ok bool) {
 
 	im, _, err := image.Decode(bytes.NewReader(resp))
 	if err != nil {
-		fmt.Println(`caught`, err)
+		logCache.Warn(`decode failed`, `key`, key, `err`, err)
 		return nil, false
 	}
 	return im, true
@@ -123,7 +129,7 @@ func (car *CACache) GetImage(key string) (im image.Image, ok bool) {
 func (car *CACache) Set(key string, resp []byte) {
 	err := car.conn.Write(key, resp)
 	if nil != err {
-		fmt.Println(`set caught`, err)
+		logCache.Warn(`write failed`, `key`, key, `err`, err)
 	}
 	car.setLRU(key)
 }
@@ -133,7 +139,7 @@ func (car *CACache) setLRU(key string) {
 	// LRU mech.
 	expires := int64(0)
 	if car.MaxAge > 0 {
-		expires = time.Now().Unix() + car.MaxAge
+		expires = timeNow().Unix() + car.MaxAge
 	}
 
 	car.mu.Lock()
@@ -159,14 +165,14 @@ func (car *CACache) SetImage(key string, im image.Image) {
 	if nil == err {
 		car.Set(key, buff.Bytes())
 	} else {
-		fmt.Println(`caught`, err)
+		logCache.Warn(`encode failed`, `key`, key, `err`, err)
 	}
 }
 
 func (car *CACache) maybeDeleteOldest() {
 
 	if car.MaxAge > 0 {
-		now := time.Now().Unix()
+		now := timeNow().Unix()
 		for le := car.lru.Front(); le != nil && le.Value.(*entry).expires <= now; le = car.lru.Front() {
 			car.deleteElement(le)
 		}
@@ -180,6 +186,25 @@ func (car *CACache) deleteElement(le *list.Element) {
 	delete(car.cache, e.key)
 	err := car.conn.Erase(e.key)
 	if nil != err {
-		fmt.Println(`caught`, err)
+		logCache.Warn(`erase failed`, `key`, e.key, `err`, err)
+	}
+}
+
+// Purge erases every cached image, the count erased is returned
+func (car *CACache) Purge() (n int, err error) {
+	car.mu.Lock()
+	defer car.mu.Unlock()
+	var keys []string
+	for key := range car.conn.Keys(nil) {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if err = car.conn.Erase(key); nil != err {
+			return
+		}
+		n++
 	}
+	car.lru.Init()
+	car.cache = make(map[string]*list.Element)
+	return
 }
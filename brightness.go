@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dimmer sets the panel brightness from the sun: RGB.daybright while it
+// is up, RGB.nightbright once it is below the twilight depth, ramping
+// between along the curve.  Levels step evenly in perceived brightness,
+// gamma, and fade rather than jump.  An hour level, then a manual
+// override, take precedence over the sun
+type Dimmer struct {
+	day     int
+	night   int
+	depth   float64 // degrees below the horizon where night starts
+	curve   func(f float64) float64
+	gamma   float64
+	fade    time.Duration
+	hours   map[int]int
+	timeout time.Duration // manual override default, zero until cleared
+	lat     float64
+	lng     float64
+
+	manual struct {
+		active bool
+		value  int
+		until  time.Time
+	}
+	level     float64 // perceived, 0..1, on the panel now
+	target    float64
+	source    string
+	elevation float64
+	stepped   time.Time
+	mux       sync.Mutex
+}
+
+// brightness curves over the twilight ramp, f 0 at night to 1 by day
+var curves = map[string]func(f float64) float64{
+	`linear`: func(f float64) float64 { return f },
+	`smooth`: func(f float64) float64 { return f * f * (3 - 2*f) },
+	`early`:  math.Sqrt,
+	`late`:   func(f float64) float64 { return f * f },
+}
+
+var dimmer = NewDimmer()
+
+// NewDimmer a dimmer at the default levels, Configure sets it up
+func NewDimmer() *Dimmer {
+	return &Dimmer{
+		day:   20,
+		night: 20,
+		depth: twilights[`civil`],
+		curve: curves[`smooth`],
+		gamma: 2.2,
+		level: -1,
+	}
+}
+
+// Configure takes the levels, ramp and location from c, an override in
+// force stays
+func (dm *Dimmer) Configure(c *Config) {
+	b := c.Brightness
+	dm.mux.Lock()
+	defer dm.mux.Unlock()
+	dm.day, dm.night = c.RGB.DayBright, c.RGB.NightBright
+	dm.depth = twilights[strings.ToLower(b.Twilight)]
+	dm.curve = curves[strings.ToLower(b.Curve)]
+	dm.gamma, dm.fade, dm.timeout = b.Gamma, b.Fade, b.Override
+	dm.lat, dm.lng = c.Moon.Lat, c.Moon.Lng
+	dm.hours = make(map[int]int)
+	for h, l := range b.Hours {
+		hr, _ := strconv.Atoi(h)
+		dm.hours[hr] = l
+	}
+}
+
+// Override holds brightness b until the given time, when zero for the
+// configured timeout or, with none, until cleared
+func (dm *Dimmer) Override(b int, until time.Time) {
+	dm.mux.Lock()
+	defer dm.mux.Unlock()
+	if until.IsZero() && dm.timeout > 0 {
+		until = timeNow().Add(dm.timeout)
+	}
+	dm.manual.active, dm.manual.value, dm.manual.until = true, b, until
+}
+
+// Clear the manual override
+func (dm *Dimmer) Clear() {
+	dm.mux.Lock()
+	dm.manual.active = false
+	dm.mux.Unlock()
+}
+
+// Level the brightness, 0..100, for the panel now, faded toward the target
+func (dm *Dimmer) Level() int {
+
+	dm.mux.Lock()
+	defer dm.mux.Unlock()
+
+	dm.target, dm.source = dm.aim(timeNow())
+
+	wall := time.Now()
+	if dm.level < 0 || dm.fade <= 0 {
+		dm.level = dm.target
+	} else {
+		step := float64(wall.Sub(dm.stepped)) / float64(dm.fade)
+		if d := dm.target - dm.level; math.Abs(d) <= step {
+			dm.level = dm.target
+		} else {
+			dm.level += math.Copysign(step, d)
+		}
+	}
+	dm.stepped = wall
+	return dm.linear(dm.level)
+
+}
+
+// aim the perceived target level at now, and what set it
+func (dm *Dimmer) aim(now time.Time) (float64, string) {
+
+	if dm.manual.active {
+		if dm.manual.until.IsZero() || now.Before(dm.manual.until) {
+			return dm.perceived(dm.manual.value), `manual`
+		}
+		dm.manual.active = false
+	}
+	if l, ok := dm.hours[now.Hour()]; ok {
+		return dm.perceived(l), `hour`
+	}
+
+	day, night := dm.perceived(dm.day), dm.perceived(dm.night)
+	located := 0 != dm.lat || 0 != dm.lng
+	if sunWeather || !located {
+		// the weather server's sunrise and sunset, as sun.weather asks or
+		// for want of a location
+		if rise, set, ok := weatherSunTimes(now); ok {
+			return night + dm.curve(dm.ramp(now, rise, set))*(day-night), `weather`
+		}
+	}
+	if !located {
+		if dl, _ := currentDaylight(); dl.isdaylight {
+			return day, `daylight`
+		}
+		return night, `daylight`
+	}
+
+	dm.elevation = sunElevation(now, dm.lat, dm.lng)
+	f := (dm.elevation + dm.depth) / (dm.depth + sunHorizon)
+	f = math.Max(0, math.Min(1, f))
+	return night + dm.curve(f)*(day-night), `sun`
+
+}
+
+// ramp the twilight fraction, 0 at night to 1 by day, from sunrise and
+// sunset alone; the sun moves about a degree every four minutes so the
+// twilight depth takes that many minutes either side
+func (dm *Dimmer) ramp(now, rise, set time.Time) float64 {
+	span := time.Duration(dm.depth * float64(4*time.Minute))
+	f := math.Min(float64(now.Sub(rise.Add(-span))), float64(set.Add(span).Sub(now))) / float64(span)
+	return math.Max(0, math.Min(1, f))
+}
+
+// perceived level, 0..1, of brightness l
+func (dm *Dimmer) perceived(l int) float64 {
+	return math.Pow(float64(l)/100, 1/dm.gamma)
+}
+
+// linear brightness, 0..100, of perceived level p
+func (dm *Dimmer) linear(p float64) int {
+	return int(math.Round(100 * math.Pow(p, dm.gamma)))
+}
+
+// Status the levels and what set them
+func (dm *Dimmer) Status() map[string]interface{} {
+	dm.mux.Lock()
+	defer dm.mux.Unlock()
+	st := map[string]interface{}{
+		`level`:  dm.linear(math.Max(dm.level, 0)),
+		`target`: dm.linear(dm.target),
+		`source`: dm.source,
+	}
+	if `sun` == dm.source {
+		st[`elevation`] = math.Round(dm.elevation*100) / 100
+	}
+	if dm.manual.active && !dm.manual.until.IsZero() {
+		st[`until`] = dm.manual.until
+	}
+	return st
+}
+
+// currentBrightness the panel brightness now
+func currentBrightness() int {
+	return dimmer.Level()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// stoppedTime a time source stood still at t
+type stoppedTime struct {
+	t time.Time
+}
+
+func (st *stoppedTime) Now() time.Time                     { return st.t }
+func (st *stoppedTime) Wall(d time.Duration) time.Duration { return d }
+
+// testDimmer 80 by day and 10 by night over civil twilight, linear in
+// curve and gamma unless a case sets them; the time source and weather
+// sun times are restored by the func returned
+func testDimmer(at time.Time) (*Dimmer, *stoppedTime, func()) {
+	ts, ws, rise, set := timeSource, sunWeather, sunrise, sunset
+	clock := &stoppedTime{t: at}
+	timeSource = clock
+	dm := NewDimmer()
+	dm.day, dm.night = 80, 10
+	dm.curve, dm.gamma = curves[`linear`], 1
+	dm.hours = map[int]int{}
+	return dm, clock, func() {
+		timeSource, sunWeather, sunrise, sunset = ts, ws, rise, set
+	}
+}
+
+func TestDimmerCurves(t *testing.T) {
+	for _, tc := range []struct {
+		curve string
+		f     float64
+		want  float64
+	}{
+		{`linear`, 0.5, 0.5},
+		{`smooth`, 0.25, 0.15625},
+		{`smooth`, 0.5, 0.5},
+		{`early`, 0.25, 0.5},
+		{`late`, 0.5, 0.25},
+		{`late`, 1, 1},
+	} {
+		if got := curves[tc.curve](tc.f); math.Abs(tc.want-got) > 1e-9 {
+			t.Errorf("%s(%v) = %v, want %v", tc.curve, tc.f, got, tc.want)
+		}
+	}
+}
+
+func TestDimmerGamma(t *testing.T) {
+
+	dm := NewDimmer()
+	for _, tc := range []struct {
+		gamma float64
+		level int
+		want  float64
+	}{
+		{1, 25, 0.25},
+		{2, 25, 0.5},
+		{2.2, 0, 0},
+		{2.2, 100, 1},
+	} {
+		dm.gamma = tc.gamma
+		if got := dm.perceived(tc.level); math.Abs(tc.want-got) > 1e-9 {
+			t.Errorf("gamma %v: perceived %d = %v, want %v", tc.gamma, tc.level, got, tc.want)
+		}
+	}
+
+	// every level survives the round trip
+	dm.gamma = 2.2
+	for l := 0; l <= 100; l++ {
+		if got := dm.linear(dm.perceived(l)); l != got {
+			t.Errorf("level %d comes back %d", l, got)
+		}
+	}
+
+}
+
+func TestDimmerAim(t *testing.T) {
+
+	boston, _ := time.LoadLocation(`America/New_York`)
+	day := time.Date(2020, time.October, 16, 0, 0, 0, 0, boston)
+	for _, tc := range []struct {
+		name     string
+		at       time.Duration
+		weather  bool // sun.weather, sunrise 07:00 and sunset 18:00
+		located  bool
+		curve    string
+		gamma    float64
+		min, max int
+		source   string
+	}{
+		// the sun over Boston: civil dawn 06:37, rise 07:04, set 18:04
+		{`night`, 6*time.Hour + 20*time.Minute, false, true, ``, 0, 10, 10, `sun`},
+		{`civil twilight`, 6*time.Hour + 50*time.Minute, false, true, ``, 0, 20, 70, `sun`},
+		{`sunrise`, 7*time.Hour + 15*time.Minute, false, true, ``, 0, 80, 80, `sun`},
+		{`noon`, 12 * time.Hour, false, true, ``, 0, 80, 80, `sun`},
+		{`after dusk`, 22 * time.Hour, false, true, ``, 0, 10, 10, `sun`},
+		// the weather server's times ramp 24 minutes either side
+		{`weather night`, 6*time.Hour + 30*time.Minute, true, true, ``, 0, 10, 10, `weather`},
+		{`weather dawn`, 6*time.Hour + 48*time.Minute, true, true, ``, 0, 45, 45, `weather`},
+		{`weather sunrise`, 7 * time.Hour, true, true, ``, 0, 80, 80, `weather`},
+		{`weather dusk`, 18*time.Hour + 12*time.Minute, true, true, ``, 0, 45, 45, `weather`},
+		{`weather without a location`, 6*time.Hour + 48*time.Minute, false, false, ``, 0, 45, 45, `weather`},
+		// curve and gamma shape the middle of the ramp
+		{`late curve`, 6*time.Hour + 48*time.Minute, true, true, `late`, 0, 28, 28, `weather`},
+		{`early curve`, 6*time.Hour + 48*time.Minute, true, true, `early`, 0, 59, 59, `weather`},
+		{`gamma`, 6*time.Hour + 48*time.Minute, true, true, ``, 2, 37, 37, `weather`},
+	} {
+		dm, clock, done := testDimmer(day.Add(tc.at))
+		if tc.located {
+			dm.lat, dm.lng = 42.36, -71.06
+		}
+		if `` != tc.curve {
+			dm.curve = curves[tc.curve]
+		}
+		if 0 != tc.gamma {
+			dm.gamma = tc.gamma
+		}
+		sunWeather = tc.weather
+		sunrise, sunset = time.Time{}, time.Time{}
+		if tc.weather || !tc.located {
+			sunrise, _ = parseTime(`07:00 AM`)
+			sunset, _ = parseTime(`06:00 PM`)
+		}
+		p, source := dm.aim(clock.t)
+		if got := dm.linear(p); got < tc.min || got > tc.max || tc.source != source {
+			t.Errorf("%s: %d from %s, want %d..%d from %s", tc.name, got, source, tc.min, tc.max, tc.source)
+		}
+		done()
+	}
+
+}
+
+func TestDimmerOverride(t *testing.T) {
+
+	t0 := time.Date(2020, time.October, 16, 3, 30, 0, 0, time.UTC)
+	dm, clock, done := testDimmer(t0)
+	defer done()
+	sunrise, sunset = time.Time{}, time.Time{}
+	dm.hours = map[int]int{3: 50}
+
+	steps := []struct {
+		name   string
+		at     time.Duration
+		act    func()
+		want   int
+		source string
+	}{
+		{`hour level`, 0, nil, 50, `hour`},
+		{`override`, time.Minute, func() { dm.Override(30, clock.t.Add(10*time.Minute)) }, 30, `manual`},
+		{`override holds`, 10 * time.Minute, nil, 30, `manual`},
+		{`override expires`, 11 * time.Minute, nil, 50, `hour`},
+		{`timeout`, 12 * time.Minute, func() { dm.timeout = 5 * time.Minute; dm.Override(70, time.Time{}) }, 70, `manual`},
+		{`timeout expires`, 18 * time.Minute, nil, 50, `hour`},
+		{`until cleared`, 19 * time.Minute, func() { dm.timeout = 0; dm.Override(90, time.Time{}) }, 90, `manual`},
+		{`still held`, 48 * time.Hour, nil, 90, `manual`},
+		{`cleared`, 48 * time.Hour, dm.Clear, 50, `hour`},
+	}
+	for _, step := range steps {
+		clock.t = t0.Add(step.at)
+		if nil != step.act {
+			step.act()
+		}
+		if got := dm.Level(); step.want != got || step.source != dm.source {
+			t.Errorf("%s: %d from %s, want %d from %s", step.name, got, dm.source, step.want, step.source)
+		}
+	}
+
+}
+
+func TestDimmerFade(t *testing.T) {
+
+	dm, _, done := testDimmer(time.Date(2020, time.October, 16, 3, 0, 0, 0, time.UTC))
+	defer done()
+	dm.fade = time.Hour
+
+	dm.Override(20, time.Time{})
+	if got := dm.Level(); 20 != got {
+		t.Fatalf("first level %d, want 20 at once", got)
+	}
+	dm.Override(80, time.Time{})
+	if got := dm.Level(); got >= 25 {
+		t.Errorf("level %d, want a slow fade from 20 toward 80", got)
+	}
+
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// command line, flags ahead of the subcommand: rgbclock [flags] [command]
+var (
+	configPath  = flag.String(`config`, ``, `config file (default config.yml in $HOME/rgbclock or .)`)
+	layoutFlag  = flag.String(`layout`, ``, `layout, overrides RGB.layout`)
+	backendFlag = flag.String(`backend`, ``, `display backend, overrides RGB.backend`)
+	logLevel    = flag.String(`log-level`, ``, `log level, overrides log.level`)
+	weatherURI  = flag.String(`weather`, os.Getenv(weatherServerURI), `weather server URI, default $`+weatherServerURI)
+	checkConfig = flag.Bool(`check-config`, false, `same as the check-config command`)
+)
+
+type cliCommand struct {
+	name  string
+	args  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []cliCommand{
+	{`run`, ``, `drive the display, the default`, cmdRun},
+	{`render-once`, `[--out frame.png] [--scale n] [--fixtures dir --at time]`, `compose one frame and save it as PNG`, cmdRenderOnce},
+	{`replay`, `[--from time] [--speed n] dir`, `drive the display from recorded payloads, time running speed times faster`, cmdReplay},
+	{`check-config`, ``, `report config problems, exit 1 if any`, cmdCheckConfig},
+	{`list-icons`, ``, `list the weather icons and their svg files`, cmdListIcons},
+	{`cache`, `purge`, `erase the cover art cache`, cmdCache},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: %s [flags] [command]\n\ncommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.usage)
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	name, args := `run`, flag.Args()
+	if *checkConfig {
+		name = `check-config`
+	} else if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	os.Exit(dispatch(name, args))
+
+}
+
+// dispatch runs the named command for its exit code, 2 if unknown
+func dispatch(name string, args []string) int {
+	for _, cmd := range commands {
+		if name == cmd.name {
+			return cmd.run(args)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	flag.Usage()
+	return 2
+}
+
+// noArgs complains of stray arguments, false if any
+func noArgs(name string, args []string) bool {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: unexpected arguments %v\n", name, args)
+		return false
+	}
+	return true
+}
+
+func cmdRun(args []string) int {
+	if !noArgs(`run`, args) {
+		return 2
+	}
+	c, err := loadConfig()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	watchConfig()
+	return runClock(c)
+}
+
+// cmdReplay runs the clock on the fixtures in dir, see Fixtures, from a
+// replayed time rather than the wall clock
+func cmdReplay(args []string) int {
+
+	fs := flag.NewFlagSet(`replay`, flag.ContinueOnError)
+	from := fs.String(`from`, ``, `replay start, RFC3339, default midnight today`)
+	speed := fs.Float64(`speed`, 60, `replayed seconds per second`)
+	if err := fs.Parse(args); nil != err {
+		return 2
+	}
+	if 1 != fs.NArg() || *speed <= 0 {
+		fmt.Fprintln(os.Stderr, `usage: replay [--from time] [--speed n] dir`)
+		return 2
+	}
+
+	y, m, d := time.Now().Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	if `` != *from {
+		var err error
+		if start, err = time.Parse(time.RFC3339, *from); nil != err {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	f, err := NewFixtures(fs.Arg(0))
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	c, err := loadConfig()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fixtures, timeSource = f, NewReplayTime(start, *speed)
+	// sources age from the replayed start
+	health.started = timeNow()
+	logMain.Info(`replay`, `fixtures`, fs.Arg(0), `from`, start, `speed`, *speed)
+
+	watchConfig()
+	return runClock(c)
+
+}
+
+func cmdCheckConfig(args []string) int {
+	if !noArgs(`check-config`, args) {
+		return 2
+	}
+	return reportConfig(readConfig())
+}
+
+// cmdRenderOnce builds the clock from the config, composes a single
+// frame with whatever the sources have on hand and writes it
+func cmdRenderOnce(args []string) int {
+
+	fs := flag.NewFlagSet(`render-once`, flag.ContinueOnError)
+	out := fs.String(`out`, `frame.png`, `PNG file to write`)
+	scale := fs.Int(`scale`, 1, `upscale factor`)
+	fixtures := fs.String(`fixtures`, ``, `folder of weather.json, lms.json and mbta.json to render instead of the live sources`)
+	at := fs.String(`at`, ``, `frame time, RFC3339, with --fixtures`)
+	if err := fs.Parse(args); nil != err {
+		return 2
+	}
+	if !noArgs(`render-once`, fs.Args()) {
+		return 2
+	}
+	// fixtures replay one moment, the live sources are now
+	if (`` == *fixtures) != (`` == *at) {
+		fmt.Fprintln(os.Stderr, `render-once: --fixtures and --at go together`)
+		return 2
+	}
+
+	c, err := loadConfig()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	tm := time.Now()
+	if `` != *at {
+		if tm, err = time.Parse(time.RFC3339, *at); nil != err {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	defer supervisor.Wait(shutdownGrace)
+	if `` == *fixtures {
+		prepareAssets()
+	} else {
+		cacheFixedIcons()
+	}
+
+	cl, err := newClock(c, NewNotifier())
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer cl.Stop()
+
+	var frame image.Image
+	if `` != *fixtures {
+		var in FrameInput
+		if in, err = readFixtures(*fixtures, tm); nil == err {
+			frame, err = cl.Render(in)
+		}
+		if nil != err {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		cl.Compose(tm)
+		frame = cl.comp.Frame()
+	}
+	if *scale > 1 {
+		frame = imaging.Resize(frame, W**scale, H**scale, imaging.NearestNeighbor)
+	}
+
+	fh, err := os.Create(*out)
+	if nil == err {
+		err = png.Encode(fh, frame)
+		if cerr := fh.Close(); nil == err {
+			err = cerr
+		}
+	}
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s: %dx%d %s layout\n", *out, frame.Bounds().Dx(), frame.Bounds().Dy(), layout)
+	return 0
+
+}
+
+// cmdListIcons prints each icon with its svg, exit 1 if any svg is missing
+func cmdListIcons(args []string) int {
+
+	if !noArgs(`list-icons`, args) {
+		return 2
+	}
+	mapInit()
+
+	im := icons()
+	names := make([]string, 0, len(im))
+	for n := range im {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	missing := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, n := range names {
+		i := im[n]
+		state := `ok`
+		if !fileExists(iconFile(i)) {
+			state = `missing`
+			missing++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", n, iconFile(i), state)
+	}
+	tw.Flush()
+	if missing > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d icons missing\n", missing, len(names))
+		return 1
+	}
+	return 0
+
+}
+
+func cmdCache(args []string) int {
+
+	if 1 != len(args) || `purge` != args[0] {
+		fmt.Fprintln(os.Stderr, `usage: cache purge`)
+		return 2
+	}
+	folder, err := cacheFolder()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	n, err := InitImageCache(folder, false).Purge()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s: %d cached images purged\n", folder, n)
+	return 0
+
+}
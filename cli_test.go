@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// quiet sends stderr to the null device, the complaints are the point
+// of these tests not their output
+func quiet() func() {
+	stderr := os.Stderr
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if nil != err {
+		return func() {}
+	}
+	os.Stderr = null
+	return func() { os.Stderr = stderr; null.Close() }
+}
+
+// TestCLIUsage bad command lines exit 2 before touching the config
+func TestCLIUsage(t *testing.T) {
+
+	defer quiet()()
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want int
+	}{
+		{`run`, []string{`now`}, 2},
+		{`check-config`, []string{`config.yml`}, 2},
+		{`list-icons`, []string{`weather`}, 2},
+		{`render-once`, []string{`frame.png`}, 2},
+		{`render-once`, []string{`--fixtures`, `testdata/fixtures`}, 2},
+		{`render-once`, []string{`--at`, `2020-10-16T10:23:37-04:00`}, 2},
+		{`render-once`, []string{`--scale`, `big`}, 2},
+		{`replay`, nil, 2},
+		{`replay`, []string{`--speed`, `0`, `testdata/day`}, 2},
+		{`replay`, []string{`--from`, `noon`, `testdata/day`}, 2},
+		{`cache`, nil, 2},
+		{`cache`, []string{`clear`}, 2},
+		{`cache`, []string{`purge`, `all`}, 2},
+		{`bogus`, nil, 2},
+		{``, nil, 2},
+	} {
+		if got := dispatch(tc.name, tc.args); tc.want != got {
+			t.Errorf("%s %q: exit %d, want %d", tc.name, tc.args, got, tc.want)
+		}
+	}
+
+	// every command is reachable
+	for _, cmd := range commands {
+		if nil == cmd.run || `` == cmd.usage {
+			t.Errorf("%s: no run func or usage", cmd.name)
+		}
+	}
+
+}
+
+func TestCLINoArgs(t *testing.T) {
+
+	defer quiet()()
+
+	if !noArgs(`run`, nil) || !noArgs(`run`, []string{}) {
+		t.Error("no arguments refused")
+	}
+	if noArgs(`run`, []string{`extra`}) {
+		t.Error("a stray argument accepted")
+	}
+
+}
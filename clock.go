@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/gomonobold"
+)
+
+// Clock everything built from one config snapshot: the data sources,
+// faces, layout, widgets and scenes; a reload builds a new one and swaps
+type Clock struct {
+	config  *Config
+	regions *Layout
+	widgets *WidgetRegistry
+	scenes  *SceneScheduler
+	comp    *Compositor
+	cpu     *CPUStat
+	lms     *LMSServer
+	news    *News
+	transit *MBTA
+	pmap    *PanelMapping
+	phys    *image.RGBA
+	face    []string
+	cx, cy  float64
+	tm      time.Time
+	s       float64
+	started bool
+}
+
+var (
+	running    *Clock
+	runningMux sync.RWMutex
+)
+
+// frameAt the time of the frame being composed, set and read on the
+// render loop only
+var frameAt time.Time
+
+// frameTime the time widgets show, the frame time rather than the wall
+// clock so a frame composes the same however long it takes
+func frameTime() time.Time {
+	if frameAt.IsZero() {
+		return timeNow()
+	}
+	return frameAt
+}
+
+// currentClock the clock on the display
+func currentClock() *Clock {
+	runningMux.RLock()
+	defer runningMux.RUnlock()
+	return running
+}
+
+// currentSources the sources of the clock on the display, for status,
+// MQTT and input off the render loop
+func currentSources() (*LMSServer, *News, *MBTA) {
+	if cl := currentClock(); nil != cl {
+		return cl.lms, cl.news, cl.transit
+	}
+	return nil, nil, nil
+}
+
+func setClock(cl *Clock) {
+	runningMux.Lock()
+	running = cl
+	runningMux.Unlock()
+}
+
+// cacheFolder the cover art cache, beside the binary
+func cacheFolder() (string, error) {
+	base, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if nil != err {
+		return ``, err
+	}
+	return path.Join(base, `/cache/`), nil
+}
+
+// newClock builds the clock for c, the layout globals must already
+// hold c, see applyLayout; nothing runs until Start
+func newClock(c *Config, notifier *Notifier) (cl *Clock, err error) {
+
+	cl = &Clock{config: c, tm: timeNow()}
+	defer func() {
+		if nil != err {
+			cl.release()
+			cl = nil
+		}
+	}()
+
+	cache, err := cacheFolder()
+	if nil != err {
+		return
+	}
+
+	nw := c.News.Window // time to display, seed - repeat duration affects actual time
+	if `` != nw.Time {
+		feeds := make([]interface{}, 0, len(c.Feeds))
+		for _, f := range c.Feeds {
+			feeds = append(feeds, map[interface{}]interface{}{`title`: f.Title, `link`: f.Link, `active`: f.Active})
+		}
+		cl.news = InitNews(News{
+			Feeds:    feeds,
+			Detail:   c.News.Detail,
+			SeedTime: nw.Time,
+			Width:    126,
+			Limit:    62,
+			Velocity: 1,
+			Duration: (time.Duration(nw.Duration) * time.Minute),
+			Repeat:   (time.Duration(nw.Repeat) * time.Minute),
+		})
+	}
+
+	regions, err := NewLayout(layout, W, H, c.Layout().Regions)
+	if nil != err {
+		return
+	}
+	cl.regions = regions
+
+	vis := c.LMS.Visualize
+	needle := vis.Needles[strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(vis.BaseImage, `.svg`), `.png`))]
+	cl.lms = NewLMSServer(LMSConfig{
+		Host:         c.LMS.IP,
+		Port:         c.LMS.Port,
+		Player:       c.LMS.Player,
+		BaseFolder:   cache,
+		Meter:        vis.Meter,
+		MeterMode:    vis.MeterMode,
+		MeterLayout:  vis.Layout,
+		MeterBase:    path.Join(vis.BaseFolder, vis.BaseImage),
+		NeedleColor:  needle.Color,
+		NeedleWidth:  needle.Width,
+		NeedleLength: needle.Length,
+		NeedleWell:   needle.Well,
+		SSESActive:   c.LMS.SSES.Active,
+		SSESHost:     c.LMS.SSES.IP,
+		SSESPort:     c.LMS.SSES.Port,
+		SSESEndpoint: c.LMS.SSES.Endpoint,
+		CLIActive:    c.LMS.CLI.Active,
+		CLIPort:      c.LMS.CLI.Port,
+		CLIResync:    c.LMS.CLI.Resync,
+		PlayersAuto:  c.LMS.Players.Auto,
+		PlayersEvery: c.LMS.Players.Interval,
+		Priority:     c.LMS.Players.Priority,
+		Room:         c.LMS.Players.Room,
+		Queue:        c.LMS.Queue,
+		CoverSize:    coverSize(regions),
+	})
+
+	t := c.Transport
+	activeFrom, _ := parseTime(t.Active.From)
+	activeUntil, _ := parseTime(t.Active.Until)
+	cl.transit = NewMBTAClient(os.Getenv(t.APIEnv), t.Route, t.Stop, activeFrom, activeUntil, t.Active.Days, time.Duration(t.Offset)*time.Minute)
+
+	crg := regions.Region(`clock`)
+	wf := float64(crg.Rect.Dx())
+	hf := float64(crg.Rect.Dy())
+	r := wf * 0.48
+	cx, cy := crg.Center()
+	cl.cx, cl.cy = cx, cy
+	length := wf * 0.07
+	lw := wf * 0.04
+
+	ea := 0.000
+
+	font, err := truetype.Parse(gomonobold.TTF)
+	if nil != err {
+		return
+	}
+
+	if `` != fontfile {
+		fb, err := ioutil.ReadFile(fontfile)
+		if nil == err {
+			font, _ = truetype.Parse(fb)
+		}
+	}
+
+	sface := truetype.NewFace(font, &truetype.Options{
+		Size: wf * 0.145,
+	})
+	dpface := truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.11,
+	})
+	dptface := truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.055,
+	})
+	dtface := truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.08,
+	})
+	lmsface := truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.066,
+	})
+
+	cl.transit.SetFace(truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.066,
+		DPI:  72,
+	}))
+
+	// canvas to chain wiring, folding is the original two row U fold
+	if dcfg, physical := displayConfig(c); physical {
+		spec := mapper
+		if `` == spec && folding {
+			spec = `U-mapper;Rotate:180`
+		}
+		if `` != spec {
+			cl.pmap, err = NewPanelMapping(spec, chain, parallel, dcfg.Width, dcfg.Height, c.RGB.Panels)
+			if nil != err {
+				return
+			}
+			if cl.pmap.Width != W || cl.pmap.Height != H {
+				err = fmt.Errorf("mapper %q shows %dx%d, the %s layout is %dx%d",
+					spec, cl.pmap.Width, cl.pmap.Height, layout, W, H)
+				return
+			}
+			cl.phys = image.NewRGBA(cl.pmap.Bounds())
+		}
+	}
+
+	var temps []string
+
+	orangered := `#ff0000` // `#ff4500`
+	darkred := `#660000`
+
+	lastTempo := cl.tm.Format(`15 04`)
+	tempo, err := imageTime(cl.tm, hf*.2, orangered)
+	if nil != err {
+		return
+	}
+
+	// eye-candy
+	grad := gg.NewRadialGradient(cx, cy, r+2, 0, 0, r+2)
+
+	grad.AddColorStop(0, parseHexColor(colorgrad1))
+	grad.AddColorStop(1, parseHexColor(colorgrad2))
+
+	cl.lms.SetMaxLen(scroll)
+	cl.lms.SetFace(truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.066,
+		DPI:  72,
+	}), "#ff9900c0")
+
+	var icache draw.Image
+
+	if nil != cl.news {
+		cl.news.SetFace(truetype.NewFace(font, &truetype.Options{
+			Size: hf * 0.055,
+			DPI:  72,
+		}))
+	}
+
+	cl.cpu = NewCPUStat(truetype.NewFace(font, &truetype.Options{
+		Size: hf * 0.150,
+		DPI:  72,
+	}), `#00ffff77`)
+
+	togweather := false
+
+	// widgets draw into their layout region, back to front
+	widgets := NewWidgetRegistry()
+	cl.widgets = widgets
+	widgets.Register(`background`, Every(0, nil, func(dc *gg.Context, rg *Region) {
+		cornerScroll(dc, `corner-scroll`, 0.9, `#ffcc0008`, false)
+		cornerScroll(dc, `alt-corner-scroll`, 0.0, `#d4af3708`, true)
+	}))
+	widgets.Register(`clock`, WidgetFunc(func(dc *gg.Context, rg *Region) {
+
+		if nil != icache {
+			dc.DrawImage(icache, rg.Rect.Min.X, rg.Rect.Min.Y)
+		} else {
+
+			// keep it crisp, fold scroll under face
+			dc.SetHexColor("#000000")
+			dc.DrawCircle(cx, cy, r+1)
+			dc.Fill()
+
+			var imGlobal iconCache
+			imGlobal, _ = cacheImage(`global`, imGlobal, 0.666, ``)
+			dc.DrawImageAnchored(imGlobal.image, rg.Rect.Min.X, rg.Rect.Min.Y, 0, 0)
+			dc.SetFillStyle(grad)
+			dc.DrawCircle(cx, cy, r+1)
+			dc.Fill()
+
+			dc.SetHexColor(orangered)
+			dc.SetLineWidth(lw)
+			for i := 0; i < 60; i += 5 {
+
+				var th float64 = math.Pi/30.00*float64(i) - math.Pi/3.00
+				x, y := math.Cos(th), math.Sin(th)
+
+				x1, y1 := (float64(r)-float64(length))*x+cx, (float64(r)-float64(length))*y+cy
+				x2, y2 := float64(r)*x+cx, float64(r)*y+cy
+
+				dc.DrawLine(x1, y1, x2, y2)
+				dc.Stroke()
+
+			}
+
+			// cache the clock face - zero struggles
+			icache = imaging.Clone(dc.Image())
+
+		}
+
+		h, err := strconv.ParseFloat(cl.tm.Format("5.000"), 64)
+		if nil == err {
+			ea = 6 * h
+		} else {
+			ea = 6 * cl.s
+		}
+
+		dc.SetLineWidth(lw)
+		// glitchy under one second ??
+		if ea < 8 {
+			dc.SetHexColor(darkred)
+			dc.DrawCircle(float64(cx), float64(cy), float64(r))
+			dc.Stroke()
+		}
+
+		dc.SetHexColor(orangered)
+		dc.DrawArc(float64(cx), float64(cy), float64(r), degToRadians(0), degToRadians(ea))
+		dc.Stroke()
+
+		// svg time - needs crispier
+		ts := cl.tm.Format("15 04")
+		if ts != lastTempo {
+			lastTempo = ts
+			if tt, err := imageTime(cl.tm, hf*.2, orangered); nil == err {
+				tempo = tt
+			} else {
+				// the last time face stays until the next minute
+				logDisplay.Warn(`time render failed`, `err`, err)
+			}
+		}
+		if 0 == int(cl.s)%2 {
+			dc.DrawImageAnchored(tempo[0], int(cx), int(cy), 0.5, 0.5)
+		} else {
+			dc.DrawImageAnchored(tempo[1], int(cx), int(cy), 0.5, 0.5)
+		}
+
+		if showbright {
+			dc.SetHexColor("#ff9900")
+			dc.SetFontFace(dtface)
+			_, next := currentDaylight()
+			dc.DrawStringAnchored(next, cx, float64(rg.Rect.Max.Y)-(length-2), 0.5, 0.5)
+			iconsMux.RLock()
+			last := imIcon.last
+			iconsMux.RUnlock()
+			dc.DrawStringAnchored(last, cx, (float64(rg.Rect.Max.Y)-(length-2))-8, 0.5, 0.5)
+		}
+
+	}))
+	widgets.Register(`weather`, Every(time.Second, nil, func(dc *gg.Context, rg *Region) {
+		ww, wh := float64(rg.Rect.Dx()), float64(rg.Rect.Dy())
+		wx, wy := float64(rg.Rect.Min.X), float64(rg.Rect.Min.Y)
+		rcx, _ := rg.Center()
+		wc := currentWeather().Current
+		iconsMux.RLock()
+		now, precip, humid, wind, thermo := imIcon.image, imPrecip.image, imHumid.image, imWindDir.image, imThermo.image
+		iconsMux.RUnlock()
+		// place weather icon
+		if now != nil {
+			dc.DrawImageAnchored(now, int(rcx), int(wy+0.71875*wh), 0.5, 0.5)
+		}
+
+		if !mode {
+			temps = strings.Split(wc.Temperature, " ")
+		} else {
+			p := wc.Daypart0.Precipitation
+			wdx := rcx + (ww * 0.09)
+			wdy := wy + (0.25 * wh)
+			if 0 == int(cl.s)%2 {
+				if togweather && `0%` != p {
+					temps[1] = p
+					// precipitation
+					if precip != nil {
+						if `100%` == p {
+							wdx += 5.00
+						}
+						dc.DrawImageAnchored(precip, int(wdx), int(wdy), 0.5, 0.5)
+					}
+				} else {
+					temps[1] = wc.Humidity
+					// humidity
+					if humid != nil {
+						dc.DrawImageAnchored(humid, int(wdx), int(wdy), 0.5, 0.5)
+					}
+				}
+			} else {
+				togweather = !togweather
+				temps = strings.Split(wc.Wind+" -- mph", " ") // fix for "Calm"
+				// place wind icon
+				if wind != nil {
+					dc.DrawImageAnchored(wind, int(wdx), int(wdy), 0.5, 0.5)
+				}
+			}
+		}
+		dc.SetFontFace(sface)
+		wdy := wy + (0.27 * wh)
+		if !mode {
+			dc.SetHexColor("#0099ff")
+			dc.DrawStringAnchored(fmt.Sprintf("%v", math.Round(wc.tempF)), wx+8+(ww*.25), wdy, 0.5, 0.5)
+			if thermo != nil {
+				dc.DrawImageAnchored(thermo, int(rcx), 4+int(wdy), 0.5, 0.5)
+			}
+		}
+		dc.SetHexColor("#66ff99")
+		if !mode {
+			dc.DrawStringAnchored(fmt.Sprintf("%v", math.Round(wc.tempC)), wx-8+(ww*.75), wdy, 0.5, 0.5)
+		} else {
+			dc.DrawStringAnchored(temps[1], wx+(ww/3), wdy, 0.5, 0.5)
+		}
+		if health.Stale(`weather`) {
+			staleMark(dc, rg.Rect)
+		}
+	}))
+	widgets.Register(`weather_detail`, Every(time.Second, func() bool { return detail && W > 64 }, func(dc *gg.Context, rg *Region) {
+		placeWeatherDetail(dc, rg, dpface)
+	}))
+	widgets.Register(`date`, Every(time.Second, nil, func(dc *gg.Context, rg *Region) {
+		dc.SetHexColor("#ff9900")
+		dc.SetFontFace(dtface)
+		temps = hackaDate(cl.tm)
+		_, dpos := rg.Center()
+		dx := float64(rg.Rect.Min.X)
+		dw := float64(rg.Rect.Dx())
+		dc.DrawStringAnchored(temps[idx[0]], dx+dw/4, dpos, 0.5, 0.5)
+		dc.DrawStringAnchored(temps[idx[1]], dx+3*(dw/4), dpos, 0.5, 0.5)
+	}))
+	widgets.Register(`moon`, NewMoonWidget())
+	widgets.RegisterAll(cl.cpu.Widgets())
+	// the cover art takes its place when playing
+	widgets.Register(`weather_pinned`, Every(time.Second, func() bool {
+		sc := cl.scenes.Current()
+		return mode || nil == sc || (`lms` != sc.Name && `queue` != sc.Name)
+	}, func(dc *gg.Context, rg *Region) {
+		placeWeatherDetail(dc, rg, dptface)
+	}))
+	lmsw := cl.lms.Widgets(lmsface, lw)
+	if regions.Region(`lms_queue`).Hidden {
+		delete(lmsw, `lms_queue`) // no room for the up next scene
+	}
+	widgets.RegisterAll(lmsw)
+	widgets.Register(`mbta`, cl.transit.Widget(sface, lw))
+	if nil != cl.news {
+		widgets.Register(`news`, cl.news.Widget(lw))
+	}
+
+	widgets.Register(`notify`, notifier.Widget(dtface, `#ff9900`, lw))
+
+	if cl.scenes, err = NewSceneScheduler(widgets, c.Scenes); nil != err {
+		return
+	}
+
+	cl.comp = NewCompositor(regions, widgets, c.RGB.FPS)
+	cl.face = []string{`background`, `clock`, `weather`, `weather_detail`, `date`, `moon`, `cpu_temp`, `cpu_usage`, `cpu_mem`}
+	return cl, nil
+
+}
+
+// Start the widget updates
+func (cl *Clock) Start() {
+	cl.widgets.Start()
+	cl.started = true
+}
+
+// Stop the widget updates and release the sources
+func (cl *Clock) Stop() {
+	if cl.started {
+		cl.widgets.Stop()
+		cl.started = false
+	}
+	cl.release()
+}
+
+// release what the constructors start on their own
+func (cl *Clock) release() {
+	if nil != cl.news {
+		cl.news.Stop()
+	}
+	if nil != cl.cpu {
+		cl.cpu.Stop()
+	}
+	if nil != cl.lms {
+		cl.lms.Close()
+	}
+}
+
+// Compose the frame for tm, true if it changed
+func (cl *Clock) Compose(tm time.Time) bool {
+	cl.tm = tm
+	cl.s = float64(tm.Second())
+	frameAt = tm
+	// lower zone, the clock pinned top left
+	return cl.comp.Compose(cl.face, cl.scenes.Next(tm))
+}
@@ -6,10 +6,17 @@ import (
 	"image/draw"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type (
+	// switchFlag an on off switch the API flips while the render loop
+	// reads it
+	switchFlag struct {
+		on int32
+	}
+
 	iconCache struct {
 		last  string
 		image draw.Image
@@ -29,8 +36,8 @@ type (
 
 var (
 	folding     bool = false
+	mapper      string
 	detail      bool = false
-	experiment  bool = false
 	clockw      int  = 64
 	clockh      int  = 64
 	mode        bool
@@ -42,6 +49,8 @@ var (
 	chain       int     = 1
 	layout      string  = "simple"
 	hardware    string  = "adafruit-hat-pwm"
+	backend     string  = backendMatrix
+	fps         int     = 16
 	miAlpha     float64 = 0.60
 	miW         int     = 27
 	miScale     float64 = 0.90
@@ -52,7 +61,6 @@ var (
 	nightbright int     = 20
 	// event timer display
 	showbright       bool   = false
-	instrument       bool   = false
 	evut             string = ``
 	weatherserveruri string = ``
 	precipitation    string = ``
@@ -78,11 +86,14 @@ var (
 	daymode            = daylight{0, false}
 	colorgrad1  string = `#56ccf240`
 	colorgrad2  string = `#2f80ed40`
-	sunrise            = time.Now()
-	sunset             = time.Now()
-	news        *News
-	lms         *LMSServer
-	transit     *MBTA
+	sunrise     time.Time
+	sunset      time.Time
+)
+
+// switched by the API while the render loop runs
+var (
+	experiment switchFlag
+	instrument switchFlag
 )
 
 const (
@@ -91,21 +102,10 @@ const (
 	wiColor          = "#66ff99" //"yellow"
 )
 
+// sched calls what every delay under the supervisor, send on the
+// returned channel to stop
 func sched(what func(), delay time.Duration) chan bool {
-	stop := make(chan bool)
-
-	go func() {
-		for {
-			what()
-			select {
-			case <-time.After(delay):
-			case <-stop:
-				return
-			}
-		}
-	}()
-
-	return stop
+	return supervisor.Every(funcName(what), what, delay)
 }
 
 func rotateBuffer(buffer []byte) []byte {
@@ -158,3 +158,17 @@ func checkFatal(err error) {
 		panic(err)
 	}
 }
+
+// Get reports the switch
+func (sf *switchFlag) Get() bool {
+	return 1 == atomic.LoadInt32(&sf.on)
+}
+
+// Set turns the switch on or off
+func (sf *switchFlag) Set(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&sf.on, v)
+}
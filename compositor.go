@@ -0,0 +1,279 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+	xdraw "golang.org/x/image/draw"
+)
+
+type (
+	// layer caches a widget rendering at region size
+	layer struct {
+		im      *image.RGBA
+		dc      *gg.Context
+		rect    image.Rectangle
+		visible bool
+		valid   bool
+	}
+
+	// FrameStats frame time accounting
+	FrameStats struct {
+		Frames   uint64
+		Composed uint64 // frames with changes pushed to the display
+		Overruns uint64 // frames exceeding the interval
+		Last     time.Duration
+		Average  time.Duration
+		FPS      float64
+	}
+
+	// Compositor caches each widget as a layer and recomposes only the
+	// changed regions into reused face and frame buffers
+	Compositor struct {
+		layout   *Layout
+		widgets  *WidgetRegistry
+		layers   map[string]*layer
+		face     *image.RGBA // clock face stack
+		pinned   *image.RGBA // face scaled into the pinned region
+		frame    *image.RGBA
+		scene    string
+		interval time.Duration
+		tick     time.Time
+		stats    FrameStats
+		mux      sync.Mutex
+	}
+)
+
+// NewCompositor creates a compositor for the layout at the target fps
+func NewCompositor(l *Layout, widgets *WidgetRegistry, fps int) *Compositor {
+	bounds := image.Rect(0, 0, l.Width, l.Height)
+	c := &Compositor{
+		layout:  l,
+		widgets: widgets,
+		layers:  make(map[string]*layer),
+		face:    image.NewRGBA(bounds),
+		frame:   image.NewRGBA(bounds),
+		scene:   `-`,
+	}
+	c.SetFPS(fps)
+	return c
+}
+
+// SetFPS sets the target frame rate, 1..60
+func (c *Compositor) SetFPS(fps int) {
+	if fps < 1 {
+		fps = 1
+	} else if fps > 60 {
+		fps = 60
+	}
+	c.mux.Lock()
+	c.interval = time.Second / time.Duration(fps)
+	c.mux.Unlock()
+}
+
+// Stats returns the frame time accounting
+func (c *Compositor) Stats() FrameStats {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.stats
+}
+
+// Frame returns the composed frame buffer, reused between frames
+func (c *Compositor) Frame() *image.RGBA {
+	return c.frame
+}
+
+// Snapshot returns a copy of the last composed frame
+func (c *Compositor) Snapshot() *image.RGBA {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	im := image.NewRGBA(c.frame.Bounds())
+	copy(im.Pix, c.frame.Pix)
+	return im
+}
+
+// Compose renders the dirty widgets of the face regions and, when a scene
+// shows, pins the face and draws the scene regions over it; the result
+// reports whether the frame changed
+func (c *Compositor) Compose(face []string, sc *Scene) bool {
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	damage := c.update(face)
+	if !damage.Empty() {
+		c.recompose(c.face, damage, face)
+	}
+
+	name := ``
+	var regions []string
+	if nil != sc {
+		name = sc.Name
+		regions = sc.Regions
+	}
+	full := c.frame.Bounds()
+	changed := name != c.scene
+	c.scene = name
+
+	if nil == sc {
+		if changed {
+			damage = full
+		}
+		if damage.Empty() {
+			return false
+		}
+		draw.Draw(c.frame, damage, c.face, damage.Min, draw.Src)
+		return true
+	}
+
+	pin := c.layout.Region(`clock_pinned`).Rect
+	sdamage := c.update(regions)
+	if !damage.Empty() || changed || nil == c.pinned {
+		c.pinFace(pin)
+		sdamage = sdamage.Union(pin)
+	}
+	if changed {
+		sdamage = full
+	}
+	if sdamage.Empty() {
+		return false
+	}
+
+	draw.Draw(c.frame, sdamage, image.Black, image.ZP, draw.Src)
+	if r := sdamage.Intersect(pin); !r.Empty() {
+		draw.Draw(c.frame, r, c.pinned, r.Min.Sub(pin.Min), draw.Src)
+	}
+	c.overlay(c.frame, sdamage, regions)
+	return true
+
+}
+
+// Pace sleeps out the remainder of the frame interval from start
+func (c *Compositor) Pace(start time.Time, composed bool) {
+
+	c.mux.Lock()
+	elapsed := time.Since(start)
+	wait := c.interval - elapsed
+	c.stats.Frames++
+	if composed {
+		c.stats.Composed++
+	}
+	c.stats.Last = elapsed
+	mFrameSeconds.Observe(elapsed.Seconds())
+	if 0 == c.stats.Average {
+		c.stats.Average = elapsed
+	} else {
+		c.stats.Average = (7*c.stats.Average + elapsed) / 8
+	}
+	if wait < 0 {
+		c.stats.Overruns++
+	}
+	c.mux.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	// frame to frame period, sleep included
+	now := time.Now()
+	c.mux.Lock()
+	if !c.tick.IsZero() {
+		if period := now.Sub(c.tick); period > 0 {
+			c.stats.FPS = (7*c.stats.FPS + float64(time.Second)/float64(period)) / 8
+		}
+	}
+	c.tick = now
+	c.mux.Unlock()
+
+}
+
+// update renders the dirty widgets of the named regions into their
+// layers and returns the damaged area
+func (c *Compositor) update(names []string) image.Rectangle {
+
+	var damage image.Rectangle
+	for _, n := range names {
+
+		rg := c.layout.Region(n)
+		w := c.widgets.Widget(n)
+		ly := c.layers[n]
+
+		visible := nil != w && !rg.Hidden && w.Visible()
+		if nil == ly {
+			if !visible {
+				continue
+			}
+			if rg.Rect.Empty() {
+				rg.fit(w.PreferredSize())
+				if rg.Rect.Empty() {
+					continue
+				}
+			}
+			ly = newLayer(rg.Rect)
+			c.layers[n] = ly
+		}
+
+		if !visible {
+			if ly.visible {
+				damage = damage.Union(ly.rect)
+			}
+			ly.visible = false
+			continue
+		}
+
+		if !ly.valid || !ly.visible || w.Dirty() {
+			ly.clear()
+			w.Render(ly.dc, rg)
+			ly.valid = true
+			damage = damage.Union(ly.rect)
+		}
+		ly.visible = true
+
+	}
+	return damage
+
+}
+
+// recompose redraws the damaged area from black and the layers
+func (c *Compositor) recompose(dst *image.RGBA, damage image.Rectangle, names []string) {
+	draw.Draw(dst, damage, image.Black, image.ZP, draw.Src)
+	c.overlay(dst, damage, names)
+}
+
+// overlay draws the visible layers clipped to the damaged area, z-order
+func (c *Compositor) overlay(dst *image.RGBA, damage image.Rectangle, names []string) {
+	for _, rg := range c.layout.Ordered(names...) {
+		ly, ok := c.layers[rg.Name]
+		if !ok || !ly.visible {
+			continue
+		}
+		if r := damage.Intersect(ly.rect); !r.Empty() {
+			draw.Draw(dst, r, ly.im, r.Min.Sub(ly.rect.Min), draw.Over)
+		}
+	}
+}
+
+// pinFace scales the face into the pinned region buffer
+func (c *Compositor) pinFace(pin image.Rectangle) {
+	if nil == c.pinned || c.pinned.Bounds().Size() != pin.Size() {
+		c.pinned = image.NewRGBA(image.Rect(0, 0, pin.Dx(), pin.Dy()))
+	}
+	xdraw.CatmullRom.Scale(c.pinned, c.pinned.Bounds(), c.face, c.face.Bounds(), draw.Src, nil)
+}
+
+func newLayer(r image.Rectangle) *layer {
+	im := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	dc := gg.NewContextForRGBA(im)
+	// widgets draw in canvas coordinates
+	dc.Translate(float64(-r.Min.X), float64(-r.Min.Y))
+	return &layer{im: im, dc: dc, rect: r}
+}
+
+func (ly *layer) clear() {
+	for i := range ly.im.Pix {
+		ly.im.Pix[i] = 0
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// fillWidget fills its region with a colour, counting renders
+type fillWidget struct {
+	color   color.RGBA
+	dirty   bool
+	hidden  bool
+	renders int
+}
+
+func (fw *fillWidget) Start()                     {}
+func (fw *fillWidget) Stop()                      {}
+func (fw *fillWidget) PreferredSize() image.Point { return image.ZP }
+func (fw *fillWidget) Dirty() bool                { return fw.dirty }
+func (fw *fillWidget) Visible() bool              { return !fw.hidden }
+func (fw *fillWidget) Render(dc *gg.Context, rg *Region) {
+	fw.renders++
+	fw.dirty = false
+	dc.SetColor(fw.color)
+	dc.DrawRectangle(float64(rg.Rect.Min.X), float64(rg.Rect.Min.Y), float64(rg.Rect.Dx()), float64(rg.Rect.Dy()))
+	dc.Fill()
+}
+
+// testCompositor a 32x32 face of two regions, a over the top left and b
+// bottom right
+func testCompositor() (*Compositor, *fillWidget, *fillWidget) {
+	l := &Layout{Name: `test`, Width: 32, Height: 32, regions: map[string]*Region{
+		`a`: {Name: `a`, Rect: image.Rect(0, 0, 8, 8)},
+		`b`: {Name: `b`, Rect: image.Rect(16, 16, 24, 24), Z: 1},
+	}}
+	a := &fillWidget{color: color.RGBA{255, 0, 0, 255}}
+	b := &fillWidget{color: color.RGBA{0, 0, 255, 255}}
+	wr := NewWidgetRegistry()
+	wr.Register(`a`, a)
+	wr.Register(`b`, b)
+	return NewCompositor(l, wr, 16), a, b
+}
+
+func TestCompositorDirtyRegions(t *testing.T) {
+
+	c, a, b := testCompositor()
+	face := []string{`a`, `b`}
+	at := func(x, y int) color.RGBA { return c.Frame().RGBAAt(x, y) }
+
+	if !c.Compose(face, nil) {
+		t.Fatal("first frame not composed")
+	}
+	if 1 != a.renders || 1 != b.renders {
+		t.Fatalf("renders a %d b %d, want 1 each", a.renders, b.renders)
+	}
+	if a.color != at(4, 4) || b.color != at(20, 20) || (color.RGBA{0, 0, 0, 255}) != at(12, 12) {
+		t.Fatalf("frame a %v b %v between %v", at(4, 4), at(20, 20), at(12, 12))
+	}
+
+	if c.Compose(face, nil) {
+		t.Error("composed with nothing dirty")
+	}
+	if 1 != a.renders || 1 != b.renders {
+		t.Errorf("clean widgets rendered again, a %d b %d", a.renders, b.renders)
+	}
+
+	// only b redraws, a's pixels stay
+	b.color, b.dirty = color.RGBA{0, 255, 0, 255}, true
+	if !c.Compose(face, nil) {
+		t.Fatal("dirty widget not composed")
+	}
+	if 1 != a.renders || 2 != b.renders {
+		t.Errorf("renders a %d b %d, want 1 and 2", a.renders, b.renders)
+	}
+	if a.color != at(4, 4) || b.color != at(20, 20) {
+		t.Errorf("frame a %v b %v after b changed", at(4, 4), at(20, 20))
+	}
+
+	// hiding clears its damage to black
+	a.hidden = true
+	if !c.Compose(face, nil) {
+		t.Fatal("hidden widget not composed")
+	}
+	if (color.RGBA{0, 0, 0, 255}) != at(4, 4) {
+		t.Errorf("hidden region left %v", at(4, 4))
+	}
+
+}
+
+func TestCompositorPace(t *testing.T) {
+
+	c, _, _ := testCompositor()
+	c.SetFPS(1000)
+	if time.Second/60 != c.interval {
+		t.Errorf("interval %v, want clamped to 60fps", c.interval)
+	}
+
+	c.SetFPS(50)
+	start := time.Now()
+	c.Pace(start, true)
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("paced %v, want the 20ms frame slept out", elapsed)
+	}
+
+	c.Pace(time.Now().Add(-100*time.Millisecond), false)
+	st := c.Stats()
+	if 2 != st.Frames || 1 != st.Composed || 1 != st.Overruns {
+		t.Errorf("stats %+v, want 2 frames, 1 composed, 1 overrun", st)
+	}
+
+}
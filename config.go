@@ -0,0 +1,699 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+type (
+	// Config the typed config.yml, read by LoadConfig
+	Config struct {
+		Capture    bool
+		Record     RecordSection
+		Log        LogConfig
+		Stale      map[string]time.Duration
+		LMS        LMSSection
+		RGB        RGBSection
+		Brightness BrightnessSection
+		Emulator   EmulatorSection
+		API        APISection
+		Metrics    MetricsSection
+		MQTT       MQTTSection
+		Transport  TransportSection
+		Feeds      []FeedSection
+		News       NewsSection
+		Scenes     map[string]interface{} // checked by NewSceneScheduler
+		Moon       MoonSection
+		Sun        SunSection
+		Input      InputSection
+		// Layouts the top level sections with a width and height
+		Layouts map[string]LayoutSection `mapstructure:"-"`
+	}
+
+	// LMSSection Logitech Media Server, LMS
+	LMSSection struct {
+		Active    bool
+		IP        string
+		Port      int
+		Player    string
+		Remaining bool
+		Queue     int // tracks up next shown, 0 for none
+		SSES      SSESSection
+		CLI       CLISection
+		Players   PlayersSection
+		Visualize VisualizeSection
+	}
+
+	// PlayersSection follow whichever player is playing rather than only
+	// LMS.player, which stays the one shown when none is
+	PlayersSection struct {
+		Auto     bool
+		Interval time.Duration // between players queries
+		Priority []string      // player ids or names, first shown first
+		Room     bool          // name the player shown when there are several
+	}
+
+	// CLISection LMS command line interface notifications in place of
+	// polling, polled while it cannot be reached
+	CLISection struct {
+		Active bool
+		Port   int
+		Resync time.Duration // full status refresh between notifications
+	}
+
+	// SSESSection visualizer event stream
+	SSESSection struct {
+		Active   bool
+		IP       string
+		Port     int
+		Endpoint string
+	}
+
+	// VisualizeSection VU and spectrum meters, each base image has its
+	// own needle keyed by the image name
+	VisualizeSection struct {
+		Meter      string
+		MeterMode  string
+		Layout     string
+		BaseFolder string
+		BaseImage  string
+		Needles    map[string]NeedleSection `mapstructure:"-"`
+	}
+
+	// NeedleSection analog meter needle
+	NeedleSection struct {
+		Color  string
+		Width  float64
+		Length float64
+		Well   bool
+	}
+
+	// RGBSection panel and face setup
+	RGBSection struct {
+		ScrollLimit int `mapstructure:"scroll_limit"`
+		Mapper      string
+		Folding     bool
+		Panels      []interface{} // checked by NewPanelMapping
+		FontFile    string
+		Experiment  bool
+		Rows        int
+		Cols        int
+		Hardware    string
+		Backend     string
+		FPS         int
+		Layout      string
+		DayBright   int
+		NightBright int
+		ShowBright  bool
+		ShowTicker  bool
+		Instrument  bool
+		ColorGrad1  string
+		ColorGrad2  string
+	}
+
+	// BrightnessSection how the level follows the sun between
+	// RGB.daybright and RGB.nightbright
+	BrightnessSection struct {
+		Twilight string         // civil, nautical or astronomical, where night starts
+		Curve    string         // linear, smooth, early or late
+		Gamma    float64        // perceived brightness steps, 1 for linear
+		Fade     time.Duration  // time to move the whole range
+		Hours    map[string]int // hour of day, 0..23, to a fixed level
+		Override time.Duration  // manual override without a for, zero until cleared
+	}
+
+	// EmulatorSection non matrix backends
+	EmulatorSection struct {
+		Logical bool
+		Scale   int
+		Path    string
+		Listen  string
+	}
+
+	// APISection local HTTP control
+	APISection struct {
+		Active bool
+		Listen string
+	}
+
+	// MetricsSection Prometheus metrics on a listener of their own, they
+	// are served on the api listener too while it is active
+	MetricsSection struct {
+		Active bool
+		Listen string
+	}
+
+	// MQTTSection broker and topics
+	MQTTSection struct {
+		Active      bool
+		Broker      string
+		Client      string
+		Username    string
+		PasswordEnv string
+		Topic       string
+		QoS         int
+		Retain      bool
+		Interval    time.Duration
+	}
+
+	// TransportSection MBTA predictions
+	TransportSection struct {
+		Offset int
+		Route  string
+		Stop   string
+		APIEnv string
+		Active struct {
+			Days     []int // 0=Sunday
+			From     string
+			Until    string
+			Holidays bool
+			Window   int
+		}
+	}
+
+	// LayoutSection one face geometry, simple, full, jumbo or custom
+	LayoutSection struct {
+		Style     string
+		Parallel  int
+		Chain     int
+		Width     int
+		Height    int
+		Mapper    string
+		HasMapper bool `mapstructure:"-"` // mapper given, even if blank
+		Detail    bool
+		Clock     struct{ Width, Height int }
+		Icon      struct{ Main, Wind IconSection }
+		Regions   map[string]interface{} // checked by NewLayout
+	}
+
+	// IconSection weather icon placement
+	IconSection struct {
+		Alpha float64
+		Width int
+		Scale float64
+	}
+
+	// FeedSection a news feed
+	FeedSection struct {
+		Title  string
+		Link   string
+		Active bool
+	}
+
+	// NewsSection headline window
+	NewsSection struct {
+		Detail bool
+		Active bool
+		Window struct {
+			Time     string
+			Duration int // minutes
+			Repeat   int // minutes
+		}
+	}
+
+	// RecordSection frame recording, started by capture
+	RecordSection struct {
+		Format   string        // gif or frames
+		Duration time.Duration // zero records until capture is off
+		Folder   string
+		Scale    int
+		Queue    int // frames waiting for the writer, more are dropped
+		Limit    int // frames per recording
+	}
+
+	// MoonSection observer location
+	MoonSection struct {
+		Lat float64
+		Lng float64
+	}
+
+	// InputSection key presses on a Linux input device, keyboard, remote or
+	// GPIO buttons, sent to the player shown as transport commands
+	InputSection struct {
+		Active bool
+		Device string            // /dev/input/event0
+		Keys   map[string]string // key name or code to command and value
+	}
+
+	// SunSection sunrise and sunset are worked out from moon lat and lng,
+	// Weather takes them from the weather server instead
+	SunSection struct {
+		Weather bool
+	}
+
+	// ConfigErrors every problem found, one per line
+	ConfigErrors []string
+)
+
+var (
+	cfg    *Config
+	cfgMux sync.RWMutex
+)
+
+var hexColor = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// invalidKeys the mapstructure unused keys error, path and keys
+var invalidKeys = regexp.MustCompile(`^'(.*)' has invalid keys: (.*)$`)
+
+// sectionNames config.yml spelling for messages, viper lowers keys
+var sectionNames = map[string]string{`lms`: `LMS`, `rgb`: `RGB`}
+
+var backends = []string{backendMatrix, `png`, `pipe`, `http`}
+
+func (ce ConfigErrors) Error() string {
+	return fmt.Sprintf("config has %d problem(s):\n  %s", len(ce), strings.Join(ce, "\n  "))
+}
+
+func (ce *ConfigErrors) add(key, format string, a ...interface{}) {
+	*ce = append(*ce, key+`: `+fmt.Sprintf(format, a...))
+}
+
+// defaultConfig values for keys config.yml leaves out
+func defaultConfig() *Config {
+	c := &Config{}
+	c.RGB.Layout = `simple`
+	c.RGB.Backend = backendMatrix
+	c.RGB.Hardware = `adafruit-hat-pwm`
+	c.RGB.FPS = 16
+	c.RGB.Rows, c.RGB.Cols = 64, 64
+	c.RGB.DayBright, c.RGB.NightBright = 20, 20
+	c.RGB.ScrollLimit = 22
+	c.Brightness = BrightnessSection{Twilight: `civil`, Curve: `smooth`, Gamma: 2.2, Fade: 3 * time.Second}
+	c.LMS.Port = 9000
+	c.LMS.CLI = CLISection{Port: 9090, Resync: 30 * time.Second}
+	c.LMS.Players = PlayersSection{Interval: 5 * time.Second, Room: true}
+	c.LMS.Queue = 5
+	c.LMS.SSES.Endpoint = `/visionon?subscribe=VU-SA`
+	c.API.Listen = `127.0.0.1:8081`
+	c.Metrics.Listen = `127.0.0.1:8082`
+	c.MQTT.Interval = 5 * time.Second
+	c.Transport.Active.From = `04:30 AM`
+	c.Transport.Active.Until = `09:30 AM`
+	c.Record = RecordSection{Format: recordGIF, Duration: 30 * time.Second, Folder: `capture`, Scale: 2, Queue: 64, Limit: 500}
+	return c
+}
+
+// LoadConfig decodes the config viper has read and validates it
+func LoadConfig() (*Config, error) {
+
+	c := defaultConfig()
+	c.Layouts = make(map[string]LayoutSection)
+	var ce ConfigErrors
+
+	known := map[string]interface{}{
+		`capture`: &c.Capture, `record`: &c.Record, `log`: &c.Log, `stale`: &c.Stale, `lms`: &c.LMS,
+		`rgb`: &c.RGB, `brightness`: &c.Brightness, `emulator`: &c.Emulator, `api`: &c.API, `metrics`: &c.Metrics, `mqtt`: &c.MQTT,
+		`transport`: &c.Transport, `feeds`: &c.Feeds, `news`: &c.News,
+		`scenes`: &c.Scenes, `moon`: &c.Moon, `sun`: &c.Sun, `input`: &c.Input,
+	}
+
+	all := viper.AllSettings()
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := all[k]
+		if out, ok := known[k]; ok {
+			var nd map[string]NeedleSection
+			if `lms` == k {
+				v, nd = splitNeedles(v, &ce)
+			}
+			name := k
+			if n, ok := sectionNames[k]; ok {
+				name = n
+			}
+			decodeStrict(name, v, out, &ce)
+			if nil != nd {
+				c.LMS.Visualize.Needles = nd
+			}
+			continue
+		}
+		// any other section sized width x height is a layout
+		if m, ok := v.(map[string]interface{}); ok && nil != m[`width`] && nil != m[`height`] {
+			var ls LayoutSection
+			decodeStrict(k, v, &ls, &ce)
+			_, ls.HasMapper = m[`mapper`]
+			c.Layouts[k] = ls
+			continue
+		}
+		ce.add(k, "unknown key")
+	}
+	c.validate(&ce)
+	if len(ce) > 0 {
+		return c, ce
+	}
+	return c, nil
+
+}
+
+// splitNeedles takes the per image needle sections out of LMS.visualize,
+// they are keyed by base image name so cannot be declared
+func splitNeedles(v interface{}, ce *ConfigErrors) (interface{}, map[string]NeedleSection) {
+
+	lms, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	vis, ok := lms[`visualize`].(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	fixed := map[string]bool{`meter`: true, `metermode`: true, `layout`: true, `basefolder`: true, `baseimage`: true}
+	keep := make(map[string]interface{})
+	needles := make(map[string]NeedleSection)
+	for k, nv := range vis {
+		if fixed[k] {
+			keep[k] = nv
+			continue
+		}
+		var n struct{ Needle NeedleSection }
+		decodeStrict(`LMS.visualize.`+k, nv, &n, ce)
+		needles[k] = n.Needle
+	}
+	out := make(map[string]interface{})
+	for k, lv := range lms {
+		out[k] = lv
+	}
+	out[`visualize`] = keep
+	return out, needles
+
+}
+
+// decodeStrict decodes v into out, unknown keys are problems
+func decodeStrict(key string, v, out interface{}, ce *ConfigErrors) {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if nil == err {
+		err = dec.Decode(v)
+	}
+	if me, ok := err.(*mapstructure.Error); ok {
+		for _, e := range me.Errors {
+			m := invalidKeys.FindStringSubmatch(e)
+			if nil == m {
+				ce.add(key, "%s", e)
+				continue
+			}
+			k := key
+			if `` != m[1] {
+				k += `.` + strings.ToLower(m[1])
+			}
+			for _, u := range strings.Split(m[2], `, `) {
+				ce.add(k+`.`+u, "unknown key")
+			}
+		}
+	} else if nil != err {
+		ce.add(key, "%v", err)
+	}
+}
+
+// validate ranges, colours and files
+func (c *Config) validate(ce *ConfigErrors) {
+
+	if err := c.Log.check(); nil != err {
+		ce.add(`log`, "%v", err)
+	}
+	for s, d := range c.Stale {
+		if d <= 0 {
+			ce.add(`stale.`+s, "must be a positive duration, have %v", d)
+		}
+	}
+
+	r := c.RGB
+	if _, ok := c.Layouts[strings.ToLower(r.Layout)]; !ok {
+		ce.add(`RGB.layout`, "no %q layout section", r.Layout)
+	}
+	inRange(ce, `RGB.rows`, r.Rows, 1, 512)
+	inRange(ce, `RGB.cols`, r.Cols, 1, 512)
+	inRange(ce, `RGB.fps`, r.FPS, 1, 60)
+	inRange(ce, `RGB.daybright`, r.DayBright, 0, 100)
+	inRange(ce, `RGB.nightbright`, r.NightBright, 0, 100)
+	inRange(ce, `RGB.scroll_limit`, r.ScrollLimit, 1, 256)
+	oneOf(ce, `RGB.backend`, r.Backend, backends)
+	isColor(ce, `RGB.colorgrad1`, r.ColorGrad1)
+	isColor(ce, `RGB.colorgrad2`, r.ColorGrad2)
+	configFile(ce, `RGB.fontfile`, r.FontFile)
+
+	b := c.Brightness
+	oneOf(ce, `brightness.twilight`, b.Twilight, []string{`civil`, `nautical`, `astronomical`})
+	oneOf(ce, `brightness.curve`, b.Curve, []string{`linear`, `smooth`, `early`, `late`})
+	if b.Gamma < 0.1 || b.Gamma > 5 {
+		ce.add(`brightness.gamma`, "must be 0.1..5, have %v", b.Gamma)
+	}
+	if b.Fade < 0 {
+		ce.add(`brightness.fade`, "must not be negative, have %v", b.Fade)
+	}
+	if b.Override < 0 {
+		ce.add(`brightness.override`, "must not be negative, have %v", b.Override)
+	}
+	for h, l := range b.Hours {
+		if hr, err := strconv.Atoi(h); nil != err || hr < 0 || hr > 23 {
+			ce.add(`brightness.hours.`+h, "not an hour 0..23")
+		}
+		inRange(ce, `brightness.hours.`+h, l, 0, 100)
+	}
+
+	for n, l := range c.Layouts {
+		inRange(ce, n+`.width`, l.Width, 1, 4096)
+		inRange(ce, n+`.height`, l.Height, 1, 4096)
+		inRange(ce, n+`.chain`, l.Chain, 1, 64)
+		inRange(ce, n+`.parallel`, l.Parallel, 1, 3)
+		if l.Clock.Width < 0 || l.Clock.Width > l.Width || l.Clock.Height < 0 || l.Clock.Height > l.Height {
+			ce.add(n+`.clock`, "%dx%d does not fit the %dx%d canvas", l.Clock.Width, l.Clock.Height, l.Width, l.Height)
+		}
+		for _, ic := range []struct {
+			k string
+			i IconSection
+		}{{`main`, l.Icon.Main}, {`wind`, l.Icon.Wind}} {
+			if ic.i.Alpha < 0 || ic.i.Alpha > 1 {
+				ce.add(n+`.icon.`+ic.k+`.alpha`, "must be 0..1, have %v", ic.i.Alpha)
+			}
+			if ic.i.Scale < 0 {
+				ce.add(n+`.icon.`+ic.k+`.scale`, "must not be negative, have %v", ic.i.Scale)
+			}
+		}
+	}
+
+	rc := c.Record
+	oneOf(ce, `record.format`, rc.Format, []string{recordGIF, recordFrames})
+	if rc.Duration < 0 {
+		ce.add(`record.duration`, "must not be negative, have %v", rc.Duration)
+	}
+	inRange(ce, `record.scale`, rc.Scale, 1, 16)
+	inRange(ce, `record.queue`, rc.Queue, 1, 1024)
+	inRange(ce, `record.limit`, rc.Limit, 1, 100000)
+
+	if `` != c.Emulator.Listen {
+		isListen(ce, `emulator.listen`, c.Emulator.Listen)
+	}
+	inRange(ce, `emulator.scale`, c.Emulator.Scale, 0, 16)
+
+	l := c.LMS
+	if l.Active {
+		inRange(ce, `LMS.port`, l.Port, 1, 65535)
+		inRange(ce, `LMS.queue`, l.Queue, 0, 20)
+		if l.SSES.Active {
+			inRange(ce, `LMS.sses.port`, l.SSES.Port, 1, 65535)
+			if `` == l.SSES.Endpoint {
+				ce.add(`LMS.sses.endpoint`, "required when active")
+			}
+		}
+		if l.Players.Auto && l.Players.Interval < time.Second {
+			ce.add(`LMS.players.interval`, "%v under 1s", l.Players.Interval)
+		}
+		if l.CLI.Active {
+			inRange(ce, `LMS.cli.port`, l.CLI.Port, 1, 65535)
+			if l.CLI.Resync < time.Second {
+				ce.add(`LMS.cli.resync`, "%v under 1s", l.CLI.Resync)
+			}
+		}
+		if v := l.Visualize; `` != v.Meter {
+			configFile(ce, `LMS.visualize.baseimage`, path.Join(v.BaseFolder, v.BaseImage))
+		}
+	}
+	for n, nd := range l.Visualize.Needles {
+		isColor(ce, `LMS.visualize.`+n+`.needle.color`, nd.Color)
+	}
+
+	if c.API.Active {
+		isListen(ce, `api.listen`, c.API.Listen)
+	}
+	if c.Metrics.Active {
+		isListen(ce, `metrics.listen`, c.Metrics.Listen)
+		if c.API.Active && sameListen(c.API.Listen, c.Metrics.Listen) {
+			ce.add(`metrics.listen`, "%q clashes with api.listen %q, /metrics is served there already", c.Metrics.Listen, c.API.Listen)
+		}
+	}
+	if c.Input.Active {
+		if `` == c.Input.Device {
+			ce.add(`input.device`, "required when active")
+		}
+		if _, err := parseKeys(c.Input.Keys); nil != err {
+			ce.add(`input.keys`, "%v", err)
+		}
+	}
+	if c.MQTT.Active {
+		if `` == c.MQTT.Broker {
+			ce.add(`mqtt.broker`, "required when active")
+		}
+		inRange(ce, `mqtt.qos`, c.MQTT.QoS, 0, 2)
+		if c.MQTT.Interval < 0 {
+			ce.add(`mqtt.interval`, "must not be negative, have %v", c.MQTT.Interval)
+		}
+	}
+
+	t := c.Transport
+	if t.Offset < 0 {
+		ce.add(`transport.offset`, "must not be negative, have %d", t.Offset)
+	}
+	for _, d := range t.Active.Days {
+		inRange(ce, `transport.active.days`, d, 0, 6)
+	}
+	isTime(ce, `transport.active.from`, t.Active.From)
+	isTime(ce, `transport.active.until`, t.Active.Until)
+
+	for i, f := range c.Feeds {
+		if `` == f.Link {
+			ce.add(fmt.Sprintf("feeds[%d]", i), "no link")
+		}
+	}
+	if w := c.News.Window; `` != w.Time {
+		if _, err := time.Parse(`15:04`, w.Time); nil != err {
+			ce.add(`news.window.time`, "want HH:MM, have %q", w.Time)
+		}
+		inRange(ce, `news.window.duration`, w.Duration, 1, 24*60)
+		inRange(ce, `news.window.repeat`, w.Repeat, 1, 7*24*60)
+	}
+
+	if c.Moon.Lat < -90 || c.Moon.Lat > 90 {
+		ce.add(`moon.lat`, "must be -90..90, have %v", c.Moon.Lat)
+	}
+	if c.Moon.Lng < -180 || c.Moon.Lng > 180 {
+		ce.add(`moon.lng`, "must be -180..180, have %v", c.Moon.Lng)
+	}
+
+	sort.Strings(*ce)
+
+}
+
+// check the level, component levels and format without applying them
+func (lc LogConfig) check() error {
+	if `` != lc.Level {
+		if _, err := ParseLogLevel(lc.Level); nil != err {
+			return err
+		}
+	}
+	for c, l := range lc.Components {
+		if _, err := ParseLogLevel(l); nil != err {
+			return fmt.Errorf("components.%s: %v", c, err)
+		}
+	}
+	switch strings.ToLower(lc.Format) {
+	case ``, `text`, `json`:
+	default:
+		return fmt.Errorf("unknown log format %q", lc.Format)
+	}
+	if lc.MaxSize < 0 || lc.Backups < 0 {
+		return fmt.Errorf("maxsize and backups must not be negative")
+	}
+	return nil
+}
+
+func inRange(ce *ConfigErrors, key string, v, min, max int) {
+	if v < min || v > max {
+		ce.add(key, "must be %d..%d, have %d", min, max, v)
+	}
+}
+
+func oneOf(ce *ConfigErrors, key, v string, set []string) {
+	for _, s := range set {
+		if strings.EqualFold(s, v) {
+			return
+		}
+	}
+	ce.add(key, "must be one of %s, have %q", strings.Join(set, `, `), v)
+}
+
+func isColor(ce *ConfigErrors, key, v string) {
+	if `` != v && !hexColor.MatchString(v) {
+		ce.add(key, "want a #rgb, #rrggbb or #rrggbbaa colour, have %q", v)
+	}
+}
+
+func isTime(ce *ConfigErrors, key, v string) {
+	if _, err := parseTime(v); nil != err {
+		ce.add(key, "want a time such as 04:30 AM, have %q", v)
+	}
+}
+
+func isListen(ce *ConfigErrors, key, v string) {
+	if _, _, err := net.SplitHostPort(v); nil != err {
+		ce.add(key, "want host:port or :port, have %q", v)
+	}
+}
+
+// sameListen the two addresses cannot both be listened on, the same port
+// on the same host or on every host
+func sameListen(a, b string) bool {
+	ah, ap, aerr := net.SplitHostPort(a)
+	bh, bp, berr := net.SplitHostPort(b)
+	if nil != aerr || nil != berr || ap != bp {
+		return false
+	}
+	wild := func(h string) bool { return `` == h || `0.0.0.0` == h || `::` == h }
+	return ah == bh || wild(ah) || wild(bh)
+}
+
+func configFile(ce *ConfigErrors, key, p string) {
+	if `` == p {
+		return
+	}
+	if fi, err := os.Stat(p); nil != err {
+		ce.add(key, "missing file %s", p)
+	} else if fi.IsDir() {
+		ce.add(key, "%s is a directory", p)
+	}
+}
+
+// Layout returns the selected layout section
+func (c *Config) Layout() LayoutSection {
+	return c.Layouts[strings.ToLower(c.RGB.Layout)]
+}
+
+// Mapper returns the panel mapper, the layout may override RGB.mapper
+func (c *Config) Mapper() string {
+	if lc := c.Layout(); lc.HasMapper {
+		return lc.Mapper
+	}
+	return c.RGB.Mapper
+}
+
+// currentConfig the config in force, swapped whole on reload
+func currentConfig() *Config {
+	cfgMux.RLock()
+	defer cfgMux.RUnlock()
+	return cfg
+}
+
+func setConfig(c *Config) {
+	cfgMux.Lock()
+	cfg = c
+	cfgMux.Unlock()
+}
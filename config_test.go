@@ -0,0 +1,181 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testConfig loads testdata/config.yml with the overrides set on top, viper
+// is reset so nothing outlasts the case
+func testConfig(t *testing.T, set map[string]interface{}) (*Config, error) {
+
+	t.Helper()
+
+	viper.Reset()
+	viper.SetConfigFile(`testdata/config.yml`)
+	if err := viper.ReadInConfig(); nil != err {
+		t.Fatal(err)
+	}
+	for k, v := range set {
+		viper.Set(k, v)
+	}
+	return LoadConfig()
+
+}
+
+func TestConfigValid(t *testing.T) {
+
+	defer viper.Reset()
+	c, err := testConfig(t, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if `full` != c.RGB.Layout || 16 != c.RGB.FPS {
+		t.Errorf("RGB layout %q fps %d", c.RGB.Layout, c.RGB.FPS)
+	}
+	for _, n := range []string{`simple`, `full`, `jumbo`} {
+		if _, ok := c.Layouts[n]; !ok {
+			t.Errorf("no %s layout", n)
+		}
+	}
+
+}
+
+// TestConfigRejects one bad value per rule, each must be reported against
+// its key
+func TestConfigRejects(t *testing.T) {
+
+	defer viper.Reset()
+	for _, tc := range []struct {
+		key string
+		set map[string]interface{}
+	}{
+		// strict decode
+		{`bogus: unknown key`, map[string]interface{}{`bogus`: 1}},
+		{`RGB.bogus: unknown key`, map[string]interface{}{`rgb.bogus`: 1}},
+		{`LMS.sses.bogus: unknown key`, map[string]interface{}{`lms.sses.bogus`: 1}},
+		{`full.clock.depth: unknown key`, map[string]interface{}{`full.clock.depth`: 1}},
+		{`LMS.visualize.vuscale.needle.size: unknown key`, map[string]interface{}{`lms.visualize.vuscale.needle.size`: 1}},
+		{`RGB: `, map[string]interface{}{`rgb.fps`: `fast`}},
+
+		{`log: `, map[string]interface{}{`log.level`: `loud`}},
+		{`log: `, map[string]interface{}{`log.format`: `xml`}},
+		{`stale.weather: `, map[string]interface{}{`stale.weather`: `-1s`}},
+
+		{`RGB.layout: `, map[string]interface{}{`rgb.layout`: `nowhere`}},
+		{`RGB.rows: `, map[string]interface{}{`rgb.rows`: 0}},
+		{`RGB.cols: `, map[string]interface{}{`rgb.cols`: 1024}},
+		{`RGB.fps: `, map[string]interface{}{`rgb.fps`: 0}},
+		{`RGB.daybright: `, map[string]interface{}{`rgb.daybright`: 101}},
+		{`RGB.nightbright: `, map[string]interface{}{`rgb.nightbright`: -1}},
+		{`RGB.scroll_limit: `, map[string]interface{}{`rgb.scroll_limit`: 0}},
+		{`RGB.backend: `, map[string]interface{}{`rgb.backend`: `crt`}},
+		{`RGB.colorgrad1: `, map[string]interface{}{`rgb.colorgrad1`: `red`}},
+		{`RGB.colorgrad2: `, map[string]interface{}{`rgb.colorgrad2`: `#12345`}},
+		{`RGB.fontfile: `, map[string]interface{}{`rgb.fontfile`: `font/none.ttf`}},
+		{`RGB.fontfile: `, map[string]interface{}{`rgb.fontfile`: `font`}},
+
+		{`brightness.twilight: `, map[string]interface{}{`brightness.twilight`: `dusk`}},
+		{`brightness.curve: `, map[string]interface{}{`brightness.curve`: `wobbly`}},
+		{`brightness.gamma: `, map[string]interface{}{`brightness.gamma`: 9}},
+		{`brightness.fade: `, map[string]interface{}{`brightness.fade`: `-1s`}},
+		{`brightness.override: `, map[string]interface{}{`brightness.override`: `-1m`}},
+		{`brightness.hours.25: `, map[string]interface{}{`brightness.hours`: map[string]interface{}{`25`: 50}}},
+		{`brightness.hours.6: `, map[string]interface{}{`brightness.hours`: map[string]interface{}{`6`: 150}}},
+
+		{`full.width: `, map[string]interface{}{`full.width`: 0}},
+		{`full.height: `, map[string]interface{}{`full.height`: 5000}},
+		{`full.chain: `, map[string]interface{}{`full.chain`: 0}},
+		{`full.parallel: `, map[string]interface{}{`full.parallel`: 4}},
+		{`full.clock: `, map[string]interface{}{`full.clock.width`: 500}},
+		{`full.icon.main.alpha: `, map[string]interface{}{`full.icon.main.alpha`: 2}},
+		{`full.icon.wind.scale: `, map[string]interface{}{`full.icon.wind.scale`: -1}},
+
+		{`record.format: `, map[string]interface{}{`record.format`: `avi`}},
+		{`record.duration: `, map[string]interface{}{`record.duration`: `-1s`}},
+		{`record.scale: `, map[string]interface{}{`record.scale`: 0}},
+		{`record.queue: `, map[string]interface{}{`record.queue`: 0}},
+		{`record.limit: `, map[string]interface{}{`record.limit`: 0}},
+
+		{`emulator.listen: `, map[string]interface{}{`emulator.listen`: `nowhere`}},
+		{`emulator.scale: `, map[string]interface{}{`emulator.scale`: 17}},
+
+		{`LMS.port: `, map[string]interface{}{`lms.port`: 0}},
+		{`LMS.queue: `, map[string]interface{}{`lms.queue`: 21}},
+		{`LMS.sses.port: `, map[string]interface{}{`lms.sses.active`: true, `lms.sses.port`: 0}},
+		{`LMS.sses.endpoint: `, map[string]interface{}{`lms.sses.active`: true, `lms.sses.endpoint`: ``}},
+		{`LMS.players.interval: `, map[string]interface{}{`lms.players.auto`: true, `lms.players.interval`: `10ms`}},
+		{`LMS.cli.port: `, map[string]interface{}{`lms.cli.active`: true, `lms.cli.port`: 70000}},
+		{`LMS.cli.resync: `, map[string]interface{}{`lms.cli.active`: true, `lms.cli.resync`: `1ms`}},
+		{`LMS.visualize.baseimage: `, map[string]interface{}{`lms.visualize.baseimage`: `none.png`}},
+		{`LMS.visualize.vuscale.needle.color: `, map[string]interface{}{`lms.visualize.vuscale.needle.color`: `orange`}},
+
+		{`api.listen: `, map[string]interface{}{`api.active`: true, `api.listen`: `8081`}},
+		{`metrics.listen: `, map[string]interface{}{`metrics.active`: true, `metrics.listen`: `8082`}},
+		{`metrics.listen: `, map[string]interface{}{`metrics.active`: true, `api.active`: true, `api.listen`: `127.0.0.1:8081`, `metrics.listen`: `:8081`}},
+		{`input.device: `, map[string]interface{}{`input.active`: true}},
+		{`input.keys: `, map[string]interface{}{`input.active`: true, `input.device`: `/dev/null`, `input.keys`: map[string]interface{}{`NOSUCHKEY`: `play`}}},
+		{`mqtt.broker: `, map[string]interface{}{`mqtt.active`: true, `mqtt.broker`: ``}},
+		{`mqtt.qos: `, map[string]interface{}{`mqtt.active`: true, `mqtt.broker`: `tcp://127.0.0.1:1883`, `mqtt.qos`: 3}},
+		{`mqtt.interval: `, map[string]interface{}{`mqtt.active`: true, `mqtt.broker`: `tcp://127.0.0.1:1883`, `mqtt.interval`: `-1s`}},
+
+		{`transport.offset: `, map[string]interface{}{`transport.offset`: -1}},
+		{`transport.active.days: `, map[string]interface{}{`transport.active.days`: []int{1, 7}}},
+		{`transport.active.from: `, map[string]interface{}{`transport.active.from`: `noon`}},
+		{`transport.active.until: `, map[string]interface{}{`transport.active.until`: `25:99`}},
+
+		{`feeds[0]: `, map[string]interface{}{`feeds`: []interface{}{map[string]interface{}{`title`: `BBC`}}}},
+		{`news.window.time: `, map[string]interface{}{`news.window.time`: `9am`}},
+		{`news.window.duration: `, map[string]interface{}{`news.window.time`: `09:00`, `news.window.duration`: 0, `news.window.repeat`: 60}},
+		{`news.window.repeat: `, map[string]interface{}{`news.window.time`: `09:00`, `news.window.duration`: 10, `news.window.repeat`: 0}},
+
+		{`moon.lat: `, map[string]interface{}{`moon.lat`: 91}},
+		{`moon.lng: `, map[string]interface{}{`moon.lng`: -181}},
+	} {
+		_, err := testConfig(t, tc.set)
+		ce, ok := err.(ConfigErrors)
+		if !ok {
+			t.Errorf("%v: got %v, want ConfigErrors", tc.set, err)
+			continue
+		}
+		found := false
+		for _, e := range ce {
+			found = found || strings.HasPrefix(e, tc.key)
+		}
+		if !found {
+			t.Errorf("%v: no %q problem in\n%v", tc.set, tc.key, ce)
+		}
+	}
+
+}
+
+// TestConfigLayouts every layout in the shipped config.yml passes the
+// checks check-config runs
+func TestConfigLayouts(t *testing.T) {
+
+	defer viper.Reset()
+	viper.Reset()
+	viper.SetConfigFile(`config.yml`)
+	if err := viper.ReadInConfig(); nil != err {
+		t.Fatal(err)
+	}
+	// the shipped assets live on the Pi
+	viper.Set(`lms.visualize.basefolder`, `svg/`)
+	all, err := LoadConfig()
+	if nil != err {
+		t.Fatal(err)
+	}
+	for name := range all.Layouts {
+		viper.Set(`rgb.layout`, name)
+		c, err := LoadConfig()
+		if nil == err {
+			err = checkSections(c)
+		}
+		if nil != err {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+}
@@ -43,6 +43,7 @@ type (
 		lastUsage     float64
 		lastMemFree   uint64
 		update        bool
+		tempfail      bool
 	}
 )
 
@@ -176,7 +177,7 @@ func (cs *CPUStat) getCPUStats() {
 	go cs.drawStats()
 
 	// and (re)init the repeat timer
-	cs.csTimer = time.AfterFunc(cs.refresh, func() { cs.getCPUStats() })
+	cs.csTimer = supervisor.AfterFunc(`cpu`, cs.refresh, cs.getCPUStats)
 
 }
 
@@ -185,14 +186,18 @@ func (cs *CPUStat) getCPUTemp() {
 	path := `/sys/class/thermal/thermal_zone0/temp`
 	raw, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Printf("failed to read temperature from %q: %v", path, err)
+		// every refresh off the Pi, warn once
+		if !cs.tempfail {
+			logCPU.Warn(`temperature read failed`, `path`, path, `err`, err)
+			cs.tempfail = true
+		}
 		return
 	}
 
 	cpuTempStr := strings.TrimSpace(string(raw))
 	cpuTempInt, err := strconv.Atoi(cpuTempStr)
 	if err != nil {
-		fmt.Printf("does not contain an integer: %v", path, err)
+		logCPU.Warn(`temperature is not an integer`, `path`, path, `value`, cpuTempStr, `err`, err)
 		return
 	}
 
@@ -216,7 +221,7 @@ func (cs *CPUStat) getCPUSample() (idle, total uint64) {
 			for i := 1; i < numFields; i++ {
 				val, err := strconv.ParseUint(fields[i], 10, 64)
 				if err != nil {
-					fmt.Println("Error: ", i, fields[i], err)
+					logCPU.Debug(`unreadable /proc/stat field`, `field`, i, `value`, fields[i], `err`, err)
 					continue
 				}
 				total += val // tally up all the numbers to get total ticks
@@ -256,3 +261,44 @@ func (cs *CPUStat) getMemStat() {
 
 	}
 }
+
+// Widgets adapts the stats glyphs, shown when instrumented
+func (cs *CPUStat) Widgets() map[string]Widget {
+	visible := func() bool { return instrument.Get() }
+	return map[string]Widget{
+		`cpu_temp`: &imageWidget{
+			image:   func() image.Image { return cs.CPUStatsTemp() },
+			visible: visible,
+			stop:    cs.Stop,
+		},
+		`cpu_usage`: &imageWidget{
+			image:   func() image.Image { return cs.CPUStatsUsage() },
+			visible: visible,
+		},
+		`cpu_mem`: &imageWidget{
+			image:   func() image.Image { return cs.MemStats() },
+			visible: visible,
+		},
+	}
+}
+
+// CPUStatus current stats for status reporting
+type CPUStatus struct {
+	Temperature float64 `json:"temperature"`
+	Usage       float64 `json:"usage"`
+	MemFree     float64 `json:"memfree"`
+}
+
+// Status returns the current stats
+func (cs *CPUStat) Status() CPUStatus {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	st := CPUStatus{
+		Temperature: cs.temperature,
+		Usage:       cs.publish.usage,
+	}
+	if cs.publish.memtotal > 0 {
+		st.MemFree = 100.0 * (float64(cs.publish.memfree) / float64(cs.publish.memtotal))
+	}
+	return st
+}
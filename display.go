@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// Display abstracts the panel the clock renders to
+type Display interface {
+	// Bounds returns the physical geometry of the display
+	Bounds() image.Rectangle
+	// Draw copies a completed frame into the display buffer
+	Draw(src image.Image)
+	// Render pushes the buffered frame to the device
+	Render() error
+	// SetBrightness sets the display brightness, 0..100
+	SetBrightness(b int) error
+	// Close releases the device
+	Close() error
+}
+
+// display backends
+const (
+	backendMatrix = `matrix`
+	backendPNG    = `png`
+	backendPipe   = `pipe`
+	backendHTTP   = `http`
+)
+
+// DisplayConfig setup
+type DisplayConfig struct {
+	Backend    string
+	Width      int
+	Height     int
+	Brightness int
+	Scale      int    // emulator upscale factor
+	Path       string // png file or pipe target
+	Listen     string // http emulator address
+}
+
+// NewDisplay initiates the configured display backend
+func NewDisplay(dc DisplayConfig) (Display, error) {
+	switch dc.Backend {
+	case ``, backendMatrix:
+		return newMatrixDisplay(dc)
+	case backendPNG, backendPipe, backendHTTP:
+		return newEmulatorDisplay(dc)
+	}
+	return nil, fmt.Errorf("unknown display backend %q", dc.Backend)
+}
+
+func clampBrightness(b int) int {
+	if b < 0 {
+		return 0
+	}
+	if b > 100 {
+		return 100
+	}
+	return b
+}
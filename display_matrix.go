@@ -0,0 +1,71 @@
+//go:build arm || arm64
+// +build arm arm64
+
+package main
+
+import (
+	"image"
+	"image/draw"
+
+	rgbmatrix "github.com/mcuadros/go-rpi-rgb-led-matrix"
+	//rgbmatrix "github.com/shunte88/go-rpi-rgb-led-matrix"
+)
+
+// matrixDisplay drives the hzeller panel chain via the Go binding
+type matrixDisplay struct {
+	matrix rgbmatrix.Matrix
+	canvas *rgbmatrix.Canvas
+	bounds image.Rectangle
+}
+
+func newMatrixDisplay(dc DisplayConfig) (Display, error) {
+
+	config := &rgbmatrix.DefaultConfig
+	config.Rows = rows
+	config.Cols = cols
+	config.Parallel = parallel
+	config.ChainLength = chain
+	config.Brightness = clampBrightness(dc.Brightness)
+	config.HardwareMapping = hardware
+	config.ShowRefreshRate = false
+	config.InverseColors = false
+	config.DisableHardwarePulsing = false
+
+	m, err := rgbmatrix.NewRGBLedMatrix(config)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &matrixDisplay{
+		matrix: m,
+		canvas: rgbmatrix.NewCanvas(m),
+	}
+	md.bounds = md.canvas.Bounds()
+	return md, nil
+
+}
+
+// Bounds returns the chain geometry
+func (md *matrixDisplay) Bounds() image.Rectangle {
+	return md.bounds
+}
+
+// Draw copies the frame to the LED buffer
+func (md *matrixDisplay) Draw(src image.Image) {
+	draw.Draw(md.canvas, md.bounds, src, image.ZP, draw.Over)
+}
+
+// Render updates the panels
+func (md *matrixDisplay) Render() error {
+	return md.canvas.Render()
+}
+
+// SetBrightness requires the modified binding
+func (md *matrixDisplay) SetBrightness(b int) error {
+	return md.canvas.SetBrightness(uint32(clampBrightness(b)))
+}
+
+// Close blanks and releases the matrix
+func (md *matrixDisplay) Close() error {
+	return md.canvas.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !arm && !arm64
+// +build !arm,!arm64
+
+package main
+
+import "fmt"
+
+// the hzeller library only builds on the Pi - use an emulator backend elsewhere
+func newMatrixDisplay(dc DisplayConfig) (Display, error) {
+	return nil, fmt.Errorf("matrix backend not available on this platform, select one of %s, %s or %s",
+		backendPNG, backendPipe, backendHTTP)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// emulatorDisplay is a headless display, frames are written to a png file,
+// a raw RGB pipe, or served as a self refreshing page
+type emulatorDisplay struct {
+	backend    string
+	bounds     image.Rectangle
+	frame      *image.RGBA
+	brightness int
+	scale      int
+	path       string
+	seq        int
+	pipe       io.WriteCloser
+	raw        []byte
+	server     *http.Server
+	latest     []byte // png encoded, http backend
+	mux        sync.Mutex
+}
+
+const emulatorPage = `<!DOCTYPE html>
+<html><head><title>rgbclock</title>
+<style>body{background:#111;margin:0;display:flex;height:100vh;align-items:center;justify-content:center}
+img{image-rendering:pixelated;border:1px solid #333}</style></head>
+<body><img id="f" src="frame.png">
+<script>
+var f=document.getElementById('f');
+f.onload=function(){setTimeout(function(){f.src='frame.png?'+Date.now()},%d)};
+f.onerror=f.onload;
+</script></body></html>`
+
+func newEmulatorDisplay(dc DisplayConfig) (Display, error) {
+
+	if dc.Width <= 0 || dc.Height <= 0 {
+		return nil, fmt.Errorf("invalid emulator geometry %dx%d", dc.Width, dc.Height)
+	}
+	if dc.Scale < 1 {
+		dc.Scale = 1
+	}
+
+	ed := &emulatorDisplay{
+		backend:    dc.Backend,
+		bounds:     image.Rect(0, 0, dc.Width, dc.Height),
+		brightness: clampBrightness(dc.Brightness),
+		scale:      dc.Scale,
+		path:       dc.Path,
+	}
+	ed.frame = image.NewRGBA(ed.bounds)
+
+	switch dc.Backend {
+	case backendPNG:
+		if `` == ed.path {
+			ed.path = `rgbclock.png`
+		}
+	case backendPipe:
+		ed.raw = make([]byte, 3*dc.Width*dc.Height)
+		if `` == ed.path || `-` == ed.path {
+			ed.pipe = os.Stdout
+		} else {
+			// a fifo blocks here until the reader attaches
+			fh, err := os.OpenFile(ed.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				return nil, err
+			}
+			ed.pipe = fh
+		}
+	case backendHTTP:
+		if `` == dc.Listen {
+			dc.Listen = `:8080`
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc(`/`, ed.servePage)
+		mux.HandleFunc(`/frame.png`, ed.serveFrame)
+		ed.server = &http.Server{Addr: dc.Listen, Handler: mux}
+		go func() {
+			if err := ed.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logDisplay.Error(`emulator serve failed`, `listen`, dc.Listen, `err`, err)
+			}
+		}()
+		host := dc.Listen
+		if strings.HasPrefix(host, `:`) {
+			host = `localhost` + host
+		}
+		logDisplay.Info(`emulator serving`, `url`, `http://`+host+`/`)
+	}
+
+	return ed, nil
+
+}
+
+// Bounds returns the emulated geometry
+func (ed *emulatorDisplay) Bounds() image.Rectangle {
+	return ed.bounds
+}
+
+// Draw copies the frame to the emulator buffer
+func (ed *emulatorDisplay) Draw(src image.Image) {
+	ed.mux.Lock()
+	draw.Draw(ed.frame, ed.bounds, src, image.ZP, draw.Over)
+	ed.mux.Unlock()
+}
+
+// output applies brightness and scale, as close to the panel as we can get
+func (ed *emulatorDisplay) output() image.Image {
+	var im image.Image = ed.frame
+	if ed.brightness < 100 {
+		im = imaging.AdjustFunc(ed.frame, func(c color.NRGBA) color.NRGBA {
+			c.R = uint8(int(c.R) * ed.brightness / 100)
+			c.G = uint8(int(c.G) * ed.brightness / 100)
+			c.B = uint8(int(c.B) * ed.brightness / 100)
+			return c
+		})
+	}
+	if ed.scale > 1 {
+		im = imaging.Resize(im, ed.bounds.Dx()*ed.scale, ed.bounds.Dy()*ed.scale, imaging.NearestNeighbor)
+	}
+	return im
+}
+
+// Render writes the frame to the selected sink
+func (ed *emulatorDisplay) Render() error {
+
+	ed.mux.Lock()
+	defer ed.mux.Unlock()
+
+	switch ed.backend {
+	case backendPNG:
+		fo := ed.path
+		if strings.Contains(fo, `%`) {
+			fo = fmt.Sprintf(fo, ed.seq)
+			ed.seq++
+		}
+		// write and rename so viewers never see a partial frame
+		fh, err := os.Create(fo + `.tmp`)
+		if err != nil {
+			return err
+		}
+		err = png.Encode(fh, ed.output())
+		fh.Close()
+		if err != nil {
+			return err
+		}
+		return os.Rename(fo+`.tmp`, fo)
+	case backendPipe:
+		// raw RGB24, brightness applied, unscaled - ffplay -f rawvideo -pixel_format rgb24
+		p := 0
+		for i := 0; i < len(ed.frame.Pix); i += 4 {
+			ed.raw[p] = uint8(int(ed.frame.Pix[i]) * ed.brightness / 100)
+			ed.raw[p+1] = uint8(int(ed.frame.Pix[i+1]) * ed.brightness / 100)
+			ed.raw[p+2] = uint8(int(ed.frame.Pix[i+2]) * ed.brightness / 100)
+			p += 3
+		}
+		_, err := ed.pipe.Write(ed.raw)
+		return err
+	case backendHTTP:
+		buff := new(bytes.Buffer)
+		if err := png.Encode(buff, ed.output()); err != nil {
+			return err
+		}
+		ed.latest = buff.Bytes()
+	}
+	return nil
+
+}
+
+// SetBrightness scales emulated output
+func (ed *emulatorDisplay) SetBrightness(b int) error {
+	ed.mux.Lock()
+	ed.brightness = clampBrightness(b)
+	ed.mux.Unlock()
+	return nil
+}
+
+// Close releases the sink
+func (ed *emulatorDisplay) Close() error {
+	switch ed.backend {
+	case backendPipe:
+		if ed.pipe != os.Stdout {
+			return ed.pipe.Close()
+		}
+	case backendHTTP:
+		return ed.server.Close()
+	}
+	return nil
+}
+
+func (ed *emulatorDisplay) servePage(w http.ResponseWriter, r *http.Request) {
+	if `/` != r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set(`Content-Type`, `text/html; charset=utf-8`)
+	fmt.Fprintf(w, emulatorPage, 100)
+}
+
+func (ed *emulatorDisplay) serveFrame(w http.ResponseWriter, r *http.Request) {
+	ed.mux.Lock()
+	b := ed.latest
+	ed.mux.Unlock()
+	if nil == b {
+		http.Error(w, `no frame rendered yet`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set(`Content-Type`, `image/png`)
+	w.Header().Set(`Cache-Control`, `no-cache`)
+	w.Write(b)
+}
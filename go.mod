@@ -5,13 +5,16 @@ go 1.13
 require (
 	github.com/ajstarks/svgo v0.0.0-20200725142600-7a3c8b57fecb
 	github.com/disintegration/imaging v1.6.2
+	github.com/eclipse/paho.mqtt.golang v1.2.0
 	github.com/fogleman/gg v1.3.0
 	github.com/fsnotify/fsnotify v1.4.9
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/mcuadros/go-rpi-rgb-led-matrix v0.0.0-20180401002551-b26063b3169a
 	github.com/mellena1/mbta-v3-go v0.0.0-20190730163022-d1dc7d8bc82b
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/mmcdole/gofeed v1.1.0
 	github.com/peterbourgon/diskv v2.0.1+incompatible
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/viper v1.7.1
 	github.com/srwiley/oksvg v0.0.0-20200311192757-870daf9aa564
 	github.com/srwiley/rasterx v0.0.0-20200120212402-85cb7272f5e9
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+type (
+	// SourceError a data source failure, Op names the failing step
+	SourceError struct {
+		Source string
+		Op     string
+		Err    error
+	}
+
+	// SourceHealth the last outcomes of a data source
+	SourceHealth struct {
+		LastOK    time.Time `json:"last_ok"`
+		LastError string    `json:"last_error,omitempty"`
+		ErrorAt   time.Time `json:"error_at"`
+		Failures  int       `json:"failures"` // consecutive
+		Stale     bool      `json:"stale"`
+	}
+
+	// healthRegistry tracks each source so the display keeps the last
+	// good state and marks it once stale
+	healthRegistry struct {
+		sources map[string]*SourceHealth
+		started time.Time
+		mux     sync.Mutex
+	}
+)
+
+// staleAfter per source defaults, config stale.<source> overrides
+var staleAfter = map[string]time.Duration{
+	`weather`: 10 * time.Minute,
+	`lms`:     30 * time.Second,
+	`mbta`:    3 * time.Minute,
+	`news`:    2 * time.Hour,
+}
+
+var sourceLoggers = map[string]*Logger{
+	`weather`: logWeather,
+	`lms`:     logLMS,
+	`mbta`:    logMBTA,
+	`news`:    logNews,
+}
+
+var health = &healthRegistry{
+	sources: make(map[string]*SourceHealth),
+	started: timeNow(),
+}
+
+// sourceError wraps err for the source, nil stays nil
+func sourceError(source, op string, err error) error {
+	if nil == err {
+		return nil
+	}
+	return &SourceError{Source: source, Op: op, Err: err}
+}
+
+func (se *SourceError) Error() string {
+	return fmt.Sprintf("%s %s: %v", se.Source, se.Op, se.Err)
+}
+
+// Unwrap returns the cause
+func (se *SourceError) Unwrap() error {
+	return se.Err
+}
+
+func (hr *healthRegistry) source(name string) *SourceHealth {
+	sh, ok := hr.sources[name]
+	if !ok {
+		sh = &SourceHealth{}
+		hr.sources[name] = sh
+	}
+	return sh
+}
+
+// OK records a good update
+func (hr *healthRegistry) OK(name string) {
+	hr.mux.Lock()
+	sh := hr.source(name)
+	failures := sh.Failures
+	sh.LastOK, sh.Failures = timeNow(), 0
+	hr.mux.Unlock()
+	if failures > 0 {
+		hr.logger(name).Info(`recovered`, `failures`, failures)
+	}
+}
+
+// Fail records a source error, warning on the first of a run
+func (hr *healthRegistry) Fail(err error) {
+	var se *SourceError
+	if !errors.As(err, &se) {
+		se = &SourceError{Source: `main`, Op: `unknown`, Err: err}
+	}
+	hr.mux.Lock()
+	sh := hr.source(se.Source)
+	sh.LastError, sh.ErrorAt = err.Error(), timeNow()
+	sh.Failures++
+	failures := sh.Failures
+	hr.mux.Unlock()
+
+	l := hr.logger(se.Source)
+	if 1 == failures {
+		l.Warn(se.Op+` failed, keeping last good state`, `err`, se.Err)
+	} else {
+		l.Debug(se.Op+` failed`, `err`, se.Err, `failures`, failures)
+	}
+}
+
+// Age returns the time since the last good update, since start if none
+func (hr *healthRegistry) Age(name string) time.Duration {
+	hr.mux.Lock()
+	defer hr.mux.Unlock()
+	last := hr.source(name).LastOK
+	if last.IsZero() {
+		last = hr.started
+	}
+	return timeNow().Sub(last)
+}
+
+// Stale reports the source has not updated within its stale period
+func (hr *healthRegistry) Stale(name string) bool {
+	return hr.Age(name) > staleLimit(name)
+}
+
+// Status returns a copy of every source state
+func (hr *healthRegistry) Status() map[string]SourceHealth {
+	names := []string{}
+	hr.mux.Lock()
+	for n := range hr.sources {
+		names = append(names, n)
+	}
+	hr.mux.Unlock()
+	st := make(map[string]SourceHealth)
+	for _, n := range names {
+		stale := hr.Stale(n)
+		hr.mux.Lock()
+		sh := *hr.sources[n]
+		hr.mux.Unlock()
+		sh.Stale = stale
+		st[n] = sh
+	}
+	return st
+}
+
+func (hr *healthRegistry) logger(name string) *Logger {
+	if l, ok := sourceLoggers[name]; ok {
+		return l
+	}
+	return logMain
+}
+
+func staleLimit(name string) time.Duration {
+	if c := currentConfig(); nil != c {
+		if d, ok := c.Stale[name]; ok {
+			return d
+		}
+	}
+	if d, ok := staleAfter[name]; ok {
+		return d
+	}
+	return 10 * time.Minute
+}
+
+// staleMark flags a region showing stale data, a small amber dot top right
+func staleMark(dc *gg.Context, r image.Rectangle) {
+	dc.SetHexColor(`#ffbf00cc`)
+	dc.DrawCircle(float64(r.Max.X)-3, float64(r.Min.Y)+3, 1.5)
+	dc.Fill()
+}
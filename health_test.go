@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font/basicfont"
+)
+
+// TestHealthLastGood a failed weather fetch keeps the last good payload
+// on screen, counts the failure and marks it stale once past its limit
+func TestHealthLastGood(t *testing.T) {
+
+	good, err := ioutil.ReadFile(`testdata/fixtures/weather.json`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	fgood, done := testFixtures(t, map[string]string{`weather.json`: string(good)})
+	defer done()
+	fbad, done := testFixtures(t, map[string]string{`weather.json`: `{"current": `})
+	defer done()
+
+	at := time.Date(2020, time.October, 16, 10, 23, 0, 0, time.Local)
+	clock := &stoppedTime{t: at}
+	ts, fx, hr, c, last := timeSource, fixtures, health, currentConfig(), currentWeather()
+	defer func() {
+		timeSource, fixtures, health = ts, fx, hr
+		setConfig(c)
+		weatherMux.Lock()
+		w = last
+		weatherMux.Unlock()
+	}()
+	timeSource, fixtures = clock, fgood
+	health = &healthRegistry{sources: make(map[string]*SourceHealth), started: at}
+	setConfig(nil) // the 10 minute default
+
+	// stale marks the top right corner of the detail
+	marked := func() bool {
+		dc := gg.NewContext(128, 128)
+		placeWeatherDetail(dc, &Region{Name: `weather_detail`, Rect: image.Rect(0, 0, 128, 128)}, basicfont.Face7x13)
+		_, _, b, a := dc.Image().At(125, 3).RGBA()
+		return a > 0 && 0 == b>>8
+	}
+
+	weather()
+	want := currentWeather().Current
+	if `` == want.Temperature {
+		t.Fatal("no weather from the fixture")
+	}
+	if st := health.Status()[`weather`]; 0 != st.Failures || st.Stale || marked() {
+		t.Fatalf("good fetch: %+v", st)
+	}
+
+	fixtures = fbad
+	for _, step := range []struct {
+		at       time.Duration
+		failures int
+		stale    bool
+	}{
+		{time.Minute, 1, false},
+		{5 * time.Minute, 2, false},
+		{11 * time.Minute, 3, true},
+	} {
+		clock.t = at.Add(step.at)
+		weather()
+		if got := currentWeather().Current; want.Temperature != got.Temperature || want.Icon != got.Icon {
+			t.Errorf("at %v showing %q %q, want the last good %q %q", step.at, got.Temperature, got.Icon, want.Temperature, want.Icon)
+		}
+		st := health.Status()[`weather`]
+		if step.failures != st.Failures || step.stale != st.Stale {
+			t.Errorf("at %v: %d failures stale %v, want %d stale %v", step.at, st.Failures, st.Stale, step.failures, step.stale)
+		}
+		if !strings.HasPrefix(st.LastError, `weather decode`) || !st.LastOK.Equal(at) {
+			t.Errorf("at %v: last error %q, last ok %v", step.at, st.LastError, st.LastOK)
+		}
+		if step.stale != marked() {
+			t.Errorf("at %v: stale mark %v, want %v", step.at, !step.stale, step.stale)
+		}
+	}
+
+	fixtures = fgood
+	weather()
+	if st := health.Status()[`weather`]; 0 != st.Failures || st.Stale || marked() {
+		t.Errorf("recovered: %+v", st)
+	}
+
+}
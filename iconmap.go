@@ -1,8 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"os"
+	"sync"
 )
 
 // SVG - svg path model
@@ -35,7 +35,19 @@ type (
 	}
 )
 
-var iconMap map[string]icon
+// iconMux guards iconMap, a reload rebuilds it at the new scale while the
+// face looks icons up
+var (
+	iconMap map[string]icon
+	iconMux sync.RWMutex
+)
+
+// icons the current icon map, replaced whole and never written in place
+func icons() map[string]icon {
+	iconMux.RLock()
+	defer iconMux.RUnlock()
+	return iconMap
+}
 
 func mapInit() {
 	windDegAsIs := true
@@ -188,28 +200,31 @@ func mapInit() {
 		"ram-metrics":       icon{filename: "memfree", asis: true, width: 60, height: 60, scale: 1.0, alpha: 1.0, shadow: false},
 		"bunny":             icon{filename: "first-bunny", color: "#0f344340", width: 30, height: 30, scale: 1.0, alpha: 1, shadow: true},
 	}
+	iconMux.Lock()
 	iconMap = im
+	iconMux.Unlock()
 }
 
 func getIcon(s string) icon {
 
+	im := icons()
 	var v icon
-	v, ok := iconMap[s]
+	v, ok := im[s]
 
 	if ok && (v.modal.day != `` || v.modal.night != ``) {
-		if !daymode.isdaylight {
+		if dl, _ := currentDaylight(); !dl.isdaylight {
 			if v.modal.night != `` {
-				v, _ = iconMap[v.modal.night]
+				v, _ = im[v.modal.night]
 			}
 		} else {
 			if v.modal.day != `` {
-				v, _ = iconMap[v.modal.day]
+				v, _ = im[v.modal.day]
 			}
 		}
 	}
 
 	if !ok || !fileExists(iconFile(v)) {
-		fmt.Println("icon missing?", s, v)
+		logIcons.Warn(`icon missing`, `icon`, s, `file`, v.filename)
 		v = icon{filename: "wic-alien", asis: true, color: "red", width: 60, height: 60, scale: 1, alpha: 1, shadow: false}
 	}
 	return v
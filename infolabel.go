@@ -95,7 +95,9 @@ func (il *InfoLabel) SetFace(f font.Face, x string) {
 
 // Stop the label marquee
 func (il *InfoLabel) Stop() {
-	il.marquee <- true
+	if il.active {
+		il.marquee <- true
+	}
 	il.active = false
 }
 
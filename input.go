@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InputKeys reads key presses from a Linux input device, a keyboard, an
+// IR remote or GPIO buttons through the gpio-key overlay, and sends the
+// mapped transport commands to the player shown
+type InputKeys struct {
+	device string
+	keys   map[uint16]keyCommand
+	cancel context.CancelFunc
+}
+
+type keyCommand struct {
+	command string
+	value   string
+}
+
+// input_event: a timeval of two longs, then type, code and value
+var inputEventSize = 2*strconv.IntSize/8 + 8
+
+const (
+	evKey       = 1
+	keyPressed  = 1
+	keyRepeated = 2 // held down, steps only
+)
+
+// keyCodes names for the usual media, arrow and number keys, others by
+// their code from linux/input-event-codes.h
+var keyCodes = map[string]uint16{
+	`KEY_1`: 2, `KEY_2`: 3, `KEY_3`: 4, `KEY_4`: 5, `KEY_5`: 6, `KEY_6`: 7,
+	`KEY_ENTER`: 28, `KEY_SPACE`: 57, `KEY_P`: 25, `KEY_N`: 49, `KEY_B`: 48,
+	`KEY_S`: 31, `KEY_R`: 19, `KEY_UP`: 103, `KEY_LEFT`: 105, `KEY_RIGHT`: 106,
+	`KEY_DOWN`: 108, `KEY_MUTE`: 113, `KEY_VOLUMEDOWN`: 114, `KEY_VOLUMEUP`: 115,
+	`KEY_POWER`: 116, `KEY_PAUSE`: 119, `KEY_NEXTSONG`: 163, `KEY_PLAYPAUSE`: 164,
+	`KEY_PREVIOUSSONG`: 165, `KEY_STOPCD`: 166, `KEY_REWIND`: 168,
+	`KEY_PLAYCD`: 200, `KEY_PAUSECD`: 201, `KEY_FASTFORWARD`: 208,
+	`KEY_PLAY`: 207, `KEY_SHUFFLE`: 410,
+}
+
+// parseKeys the key map of input.keys, key name or code to a command and
+// its value, volume +5 say
+func parseKeys(keys map[string]string) (map[uint16]keyCommand, error) {
+	km := make(map[uint16]keyCommand, len(keys))
+	for k, c := range keys {
+		code, ok := keyCodes[strings.ToUpper(k)]
+		if !ok {
+			n, err := strconv.ParseUint(k, 10, 16)
+			if nil != err {
+				return nil, fmt.Errorf("%s: not a key name or code", k)
+			}
+			code = uint16(n)
+		}
+		f := strings.Fields(c)
+		kc := keyCommand{}
+		if len(f) > 0 {
+			kc.command = f[0]
+		}
+		if len(f) > 1 {
+			kc.value = f[1]
+		}
+		if _, err := lmsRequest(kc.command, kc.value); nil != err {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		km[code] = kc
+	}
+	return km, nil
+}
+
+// NewInputKeys reads device, sending the commands of keys
+func NewInputKeys(device string, keys map[string]string) (*InputKeys, error) {
+	km, err := parseKeys(keys)
+	if nil != err {
+		return nil, err
+	}
+	return &InputKeys{device: device, keys: km}, nil
+}
+
+// Start reading, a device that goes away is reopened with a backoff
+func (ik *InputKeys) Start() {
+	var ctx context.Context
+	ctx, ik.cancel = context.WithCancel(supervisor.Context())
+	supervisor.GoContext(ctx, `input`, ik.read)
+}
+
+// Stop reading
+func (ik *InputKeys) Stop() {
+	if nil != ik.cancel {
+		ik.cancel()
+	}
+}
+
+func (ik *InputKeys) read(ctx context.Context) error {
+
+	f, err := os.Open(ik.device)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close() // ends the blocked read
+		case <-done:
+		}
+	}()
+	logMain.Info(`input open`, `device`, ik.device)
+
+	ev := make([]byte, inputEventSize)
+	for {
+		if _, err = io.ReadFull(f, ev); nil != err {
+			if nil != ctx.Err() {
+				return nil
+			}
+			return err
+		}
+		o := inputEventSize - 8
+		typ := binary.LittleEndian.Uint16(ev[o:])
+		code := binary.LittleEndian.Uint16(ev[o+2:])
+		value := int32(binary.LittleEndian.Uint32(ev[o+4:]))
+		kc, ok := ik.keys[code]
+		if evKey != typ || !ok {
+			continue
+		}
+		step := `volume` == kc.command || `seek` == kc.command
+		if keyPressed != value && !(keyRepeated == value && step) {
+			continue
+		}
+		lms, _, _ := currentSources()
+		if err := lms.Control(kc.command, kc.value); nil != err {
+			logMain.Warn(`input command failed`, `key`, code, `command`, kc.command, `err`, err)
+		}
+	}
+
+}
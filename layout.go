@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/spf13/cast"
+)
+
+type (
+	// Region places a widget on the canvas
+	Region struct {
+		Name   string
+		Rect   image.Rectangle
+		Z      int
+		Anchor string
+		ax     float64
+		ay     float64
+		Hidden bool
+	}
+
+	// Layout is the set of named regions for a canvas
+	Layout struct {
+		Name    string
+		Width   int
+		Height  int
+		regions map[string]*Region
+	}
+)
+
+var anchors = map[string][2]float64{
+	`top-left`:     {0, 0},
+	`top`:          {0.5, 0},
+	`top-right`:    {1, 0},
+	`left`:         {0, 0.5},
+	`center`:       {0.5, 0.5},
+	`right`:        {1, 0.5},
+	`bottom-left`:  {0, 1},
+	`bottom`:       {0.5, 1},
+	`bottom-right`: {1, 1},
+}
+
+// defaultRegions describes the original 128x128 full layout, every value is
+// scaled to the canvas so simple and jumbo keep their proportions
+var defaultRegions = []struct {
+	name       string
+	x, y, w, h int
+	z          int
+	anchor     string
+}{
+	{`background`, 0, 0, 128, 128, -1, `top-left`},
+	{`clock`, 0, 0, 128, 128, 0, `center`},
+	{`weather`, 0, 0, 128, 128, 1, `center`},
+	{`weather_detail`, 65, 0, 63, 128, 1, `top-left`},
+	{`date`, 0, 74, 128, 16, 2, `center`},
+	{`moon`, 87, 87, 22, 22, 3, `center`},
+	{`cpu_temp`, 0, 0, 128, 128, 4, `top`},
+	{`cpu_usage`, 0, 0, 128, 128, 4, `bottom`},
+	{`cpu_mem`, 0, 0, 128, 104, 4, `bottom`},
+	// lower zone, clock pinned top left when a source is active
+	{`clock_pinned`, 0, 0, 65, 65, 0, `top-left`},
+	{`weather_pinned`, 65, 0, 63, 64, 1, `top-left`},
+	{`lms_cover`, 65, 0, 64, 64, 1, `top-left`},
+	{`lms_backdrop`, 1, 66, 126, 49, 2, `top-left`},
+	{`lms_text`, 0, 66, 128, 50, 3, `top`},
+	{`lms_vu`, 0, 66, 128, 44, 3, `center`},
+	{`lms_footer`, 0, 114, 128, 14, 4, `center`},
+	{`lms_volume`, 39, 7, 50, 50, 9, `center`},
+	{`lms`, 0, 66, 128, 50, 5, `top-left`},
+	{`lms_queue`, 0, 66, 128, 48, 3, `top-left`},
+	{`mbta`, 0, 66, 128, 55, 3, `top`},
+	{`news`, 0, 66, 128, 59, 3, `top-left`},
+	{`notify`, 0, 66, 128, 59, 3, `center`},
+}
+
+// NewLayout builds the named layout for a w x h canvas, the layout's
+// regions entries from config.yml override the scaled defaults
+func NewLayout(name string, w, h int, regions map[string]interface{}) (*Layout, error) {
+
+	l := &Layout{
+		Name:    name,
+		Width:   w,
+		Height:  h,
+		regions: make(map[string]*Region),
+	}
+
+	sx := func(v int) int { return v * w / 128 }
+	sy := func(v int) int { return v * h / 128 }
+	for _, d := range defaultRegions {
+		r := &Region{
+			Name: d.name,
+			Rect: image.Rect(sx(d.x), sy(d.y), sx(d.x+d.w), sy(d.y+d.h)),
+			Z:    d.z,
+		}
+		r.SetAnchor(d.anchor)
+		l.regions[d.name] = r
+	}
+
+	// clock keeps its configured size
+	if clockw > 0 && clockh > 0 {
+		l.regions[`clock`].Rect = image.Rect(0, 0, clockw, clockh)
+		l.regions[`weather`].Rect = image.Rect(0, 0, clockw, clockh)
+	}
+
+	// only the full face has room for the extras
+	if `full` != name {
+		for _, n := range []string{`date`, `moon`, `cpu_temp`, `cpu_usage`, `cpu_mem`} {
+			l.regions[n].Hidden = true
+		}
+	}
+	// and the up next list
+	l.regions[`lms_queue`].Hidden = `full` != name && `jumbo` != name
+
+	key := name + `.regions`
+	for rn, rv := range regions {
+		spec, err := cast.ToStringMapE(rv)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", key, rn, err)
+		}
+		if err = l.apply(rn, spec); err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", key, rn, err)
+		}
+	}
+
+	return l, nil
+
+}
+
+func (l *Layout) apply(name string, spec map[string]interface{}) error {
+
+	r, ok := l.regions[name]
+	if !ok {
+		// custom panel, placed entirely by its spec
+		r = &Region{Name: name, ax: 0.5, ay: 0.5, Anchor: `center`}
+		l.regions[name] = r
+	}
+
+	x, y := r.Rect.Min.X, r.Rect.Min.Y
+	w, h := r.Rect.Dx(), r.Rect.Dy()
+	var err error
+	for k, v := range spec {
+		switch strings.ToLower(k) {
+		case `rect`:
+			x, y, w, h, err = l.rect(v)
+		case `x`, `left`:
+			x, err = l.dimension(v, l.Width)
+		case `y`, `top`:
+			y, err = l.dimension(v, l.Height)
+		case `w`, `width`:
+			w, err = l.dimension(v, l.Width)
+		case `h`, `height`:
+			h, err = l.dimension(v, l.Height)
+		case `z`:
+			r.Z, err = cast.ToIntE(v)
+		case `anchor`:
+			err = r.SetAnchor(cast.ToString(v))
+		case `hidden`:
+			r.Hidden, err = cast.ToBoolE(v)
+		default:
+			err = fmt.Errorf("unknown attribute %q", k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	r.Rect = image.Rect(x, y, x+w, y+h)
+	return nil
+
+}
+
+// rect reads [x, y, w, h], yaml reads a bare y key as true so this is
+// the preferred form
+func (l *Layout) rect(v interface{}) (x, y, w, h int, err error) {
+	vs, err := cast.ToSliceE(v)
+	if err != nil {
+		return
+	}
+	if 4 != len(vs) {
+		err = fmt.Errorf("rect expects [x, y, w, h], got %v", v)
+		return
+	}
+	if x, err = l.dimension(vs[0], l.Width); err != nil {
+		return
+	}
+	if y, err = l.dimension(vs[1], l.Height); err != nil {
+		return
+	}
+	if w, err = l.dimension(vs[2], l.Width); err != nil {
+		return
+	}
+	h, err = l.dimension(vs[3], l.Height)
+	return
+}
+
+// dimension accepts pixels or a percentage of the canvas, "50%"
+func (l *Layout) dimension(v interface{}, full int) (int, error) {
+	if s, ok := v.(string); ok && strings.HasSuffix(s, `%`) {
+		pc, err := strconv.ParseFloat(strings.TrimSuffix(s, `%`), 64)
+		if err != nil {
+			return 0, err
+		}
+		return int(float64(full) * pc / 100.00), nil
+	}
+	return cast.ToIntE(v)
+}
+
+// Region returns the named region, an unknown name yields a hidden region
+func (l *Layout) Region(name string) *Region {
+	if r, ok := l.regions[name]; ok {
+		return r
+	}
+	return &Region{Name: name, Hidden: true, ax: 0.5, ay: 0.5}
+}
+
+// Ordered returns the visible named regions in z-order, back to front
+func (l *Layout) Ordered(names ...string) []*Region {
+	var rs []*Region
+	for _, n := range names {
+		if r, ok := l.regions[n]; ok && !r.Hidden {
+			rs = append(rs, r)
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].Z < rs[j].Z })
+	return rs
+}
+
+// SetAnchor sets the anchor by name, top-left .. bottom-right
+func (r *Region) SetAnchor(a string) error {
+	if `` == a {
+		a = `center`
+	}
+	v, ok := anchors[strings.ToLower(a)]
+	if !ok {
+		return fmt.Errorf("unknown anchor %q", a)
+	}
+	r.Anchor = a
+	r.ax, r.ay = v[0], v[1]
+	return nil
+}
+
+// Point returns the anchor point within the region
+func (r *Region) Point() (float64, float64) {
+	return float64(r.Rect.Min.X) + r.ax*float64(r.Rect.Dx()),
+		float64(r.Rect.Min.Y) + r.ay*float64(r.Rect.Dy())
+}
+
+// Center returns the region center
+func (r *Region) Center() (float64, float64) {
+	return float64(r.Rect.Min.X) + float64(r.Rect.Dx())/2.00,
+		float64(r.Rect.Min.Y) + float64(r.Rect.Dy())/2.00
+}
+
+// fit sizes an empty region about its anchor point
+func (r *Region) fit(p image.Point) {
+	x, y := r.Rect.Min.X, r.Rect.Min.Y
+	x -= int(r.ax * float64(p.X))
+	y -= int(r.ay * float64(p.Y))
+	r.Rect = image.Rect(x, y, x+p.X, y+p.Y)
+}
+
+// DrawImage places the image at the region anchor
+func (r *Region) DrawImage(dc *gg.Context, im image.Image) {
+	if nil == im || r.Hidden {
+		return
+	}
+	x, y := r.Point()
+	dc.DrawImageAnchored(im, int(x), int(y), r.ax, r.ay)
+}
+
+// DrawString places text at the region anchor
+func (r *Region) DrawString(dc *gg.Context, s string) {
+	if r.Hidden {
+		return
+	}
+	x, y := r.Point()
+	dc.DrawStringAnchored(s, x, y, r.ax, r.ay)
+}
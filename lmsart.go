@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// coverArt where a track's cover comes from, as the status reports it
+type coverArt struct {
+	coverid string // local or remote track art, /music/<coverid>/cover
+	trackID string // artwork_track_id, the album art of another track
+	url     string // artwork_url, absolute or on the server
+	remote  bool   // streams, artwork_url first
+}
+
+// coverFetch a cover left for updateArt, its cache key, the url and the
+// generation it was due at
+type coverFetch struct {
+	key string
+	url string
+	gen uint64
+}
+
+// artResize a size suffix LMS already put on an artwork_url
+var artResize = regexp.MustCompile(`_\d*x\d*(_[a-zA-Z])?$`)
+
+// coverSize the cover art size for the layout, the larger of the cover
+// and its backdrop rounded up to 32 pixels, 128 on the full face
+func coverSize(l *Layout) int {
+	size := 0
+	for _, n := range []string{`lms_cover`, `lms_backdrop`} {
+		r := l.Region(n).Rect
+		if r.Dx() > size {
+			size = r.Dx()
+		}
+		if r.Dy() > size {
+			size = r.Dy()
+		}
+	}
+	if 0 == size {
+		return 128
+	}
+	return (size + 31) / 32 * 32
+}
+
+// artURL the cover at size x size pixels: the coverid or artwork track
+// art, an artwork_url on the server or through its image proxy, else the
+// player's current track
+func (ls *LMSServer) artURL(ca coverArt, player string, size int) string {
+
+	resize := fmt.Sprintf("_%dx%d_o", size, size)
+	music := func(id string) string {
+		return fmt.Sprintf("%smusic/%s/cover%s", ls.web, url.PathEscape(id), resize)
+	}
+
+	if `` != ca.url && (ca.remote || (`` == ca.coverid && `` == ca.trackID)) {
+		u := ca.url
+		if strings.HasPrefix(u, ls.web) {
+			u = strings.TrimPrefix(u, ls.web) // the server's own
+		} else if strings.HasPrefix(u, `http://`) || strings.HasPrefix(u, `https://`) {
+			return fmt.Sprintf("%simageproxy/%s/image%s", ls.web, url.PathEscape(u), resize)
+		}
+		u = strings.TrimPrefix(u, `/`)
+		if strings.HasPrefix(u, `imageproxy/`) {
+			return ls.web + u[:strings.LastIndex(u, `/`)] + `/image` + resize
+		}
+		// plugin icons, the server resizes these by name too
+		ext := path.Ext(u)
+		return ls.web + artResize.ReplaceAllString(strings.TrimSuffix(u, ext), ``) + resize + ext
+	}
+	switch {
+	case `` != ca.coverid:
+		return music(ca.coverid)
+	case `` != ca.trackID:
+		return music(ca.trackID)
+	}
+	return fmt.Sprintf("%smusic/current/cover%s?player=%s", ls.web, resize, url.QueryEscape(player))
+
+}
+
+// key names the art in the cover cache, at size, an artwork_url hashed
+// to be a file name
+func (ca coverArt) key(size int) string {
+	id := ca.coverid
+	if `` != ca.url && (ca.remote || `` == id) {
+		h := fnv.New64a()
+		h.Write([]byte(ca.url))
+		id = fmt.Sprintf("u%x", h.Sum64())
+	} else if `` == id {
+		id = ca.trackID
+	}
+	if `` == id {
+		return `` // the current track, nothing to key it by
+	}
+	return fmt.Sprintf("%s_%d", id, size)
+}
+
+// setArt the current track's cover, fetched when its key changes; caller
+// holds the lock
+func (ls *LMSServer) setArt(ca coverArt) {
+	ls.Player.coverid = ca.key(ls.coverSize)
+	ls.Player.arturl = ls.artURL(ca, ls.Player.MAC, ls.coverSize)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestArtURL(t *testing.T) {
+
+	ls := &LMSServer{web: `http://lms:9000/`}
+	const mac = `00:04:20:00:00:01`
+
+	for _, tc := range []struct {
+		name string
+		art  coverArt
+		want string
+	}{
+		{`coverid`, coverArt{coverid: `6f1c0a2b`, trackID: `12`},
+			`http://lms:9000/music/6f1c0a2b/cover_128x128_o`},
+		{`artwork track`, coverArt{trackID: `12`},
+			`http://lms:9000/music/12/cover_128x128_o`},
+		{`local url after coverid`, coverArt{coverid: `6f1c0a2b`, url: `https://cdn.example/a.jpg`},
+			`http://lms:9000/music/6f1c0a2b/cover_128x128_o`},
+		{`absolute through the proxy`, coverArt{coverid: `-1234`, url: `https://cdn.example/a b.jpg?s=1`, remote: true},
+			`http://lms:9000/imageproxy/https:%2F%2Fcdn.example%2Fa%20b.jpg%3Fs=1/image_128x128_o`},
+		{`proxied by the server`, coverArt{url: `/imageproxy/https%3A%2F%2Fcdn.example%2Fa.jpg/image.jpg`, remote: true},
+			`http://lms:9000/imageproxy/https%3A%2F%2Fcdn.example%2Fa.jpg/image_128x128_o`},
+		{`the server's own`, coverArt{url: `http://lms:9000/imageproxy/x/image_300x300_f.png`, remote: true},
+			`http://lms:9000/imageproxy/x/image_128x128_o`},
+		{`plugin icon`, coverArt{url: `plugins/TuneIn/html/images/icon_56x56_p.png`, remote: true},
+			`http://lms:9000/plugins/TuneIn/html/images/icon_128x128_o.png`},
+		{`current`, coverArt{},
+			`http://lms:9000/music/current/cover_128x128_o?player=00%3A04%3A20%3A00%3A00%3A01`},
+	} {
+		if got := ls.artURL(tc.art, mac, 128); tc.want != got {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+}
+
+func TestArtKey(t *testing.T) {
+	if k := (coverArt{coverid: `6f1c0a2b`}).key(128); `6f1c0a2b_128` != k {
+		t.Errorf("coverid key %q", k)
+	}
+	a := coverArt{coverid: `-1`, url: `https://cdn.example/a.jpg`, remote: true}.key(128)
+	b := coverArt{coverid: `-1`, url: `https://cdn.example/b.jpg`, remote: true}.key(128)
+	if a == b || `u` != a[:1] {
+		t.Errorf("url keys %q and %q", a, b)
+	}
+	if k := (coverArt{}).key(128); `` != k {
+		t.Errorf("current track key %q", k)
+	}
+}
+
+// TestArtRefetch the current track's art has no key, it is fetched again
+// as the track changes rather than once
+func TestArtRefetch(t *testing.T) {
+
+	var fetched int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, `/music/`) {
+			atomic.AddInt32(&fetched, 1)
+			png.Encode(rw, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+			return
+		}
+		fmt.Fprint(rw, `{"id":0,"method":"slim.request","result":{"mode":"stop"}}`)
+	}))
+	defer srv.Close()
+	su, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(su.Port())
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Host: su.Hostname(), Port: port, Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+
+	for _, step := range []struct {
+		id    int
+		title string
+		want  int32
+	}{
+		{1, `First`, 1},
+		{1, `First`, 1},
+		{2, `Second`, 2},
+		{3, `Second`, 3}, // same title, another track
+	} {
+		ls.mux.Lock()
+		err := ls.applyStatus([]byte(fmt.Sprintf(`{"mode": "play", "mixer volume": 40,
+			"playlist_loop": [{"id": %d, "title": %q, "playlist index": "0"}]}`, step.id, step.title)))
+		ls.mux.Unlock()
+		if nil != err {
+			t.Fatal(err)
+		}
+		ls.updateArt()
+		if got := atomic.LoadInt32(&fetched); step.want != got {
+			t.Errorf("track %d %s: %d art fetches, want %d", step.id, step.title, got, step.want)
+		}
+	}
+
+}
+
+// TestArtUnlocked a stalled art server holds back the cover alone, the
+// player stays readable meanwhile and the art is swapped in once served
+func TestArtUnlocked(t *testing.T) {
+
+	requested, release := make(chan bool, 1), make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requested <- true
+		<-release
+		png.Encode(rw, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	}))
+	defer srv.Close()
+	su, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(su.Port())
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Host: su.Hostname(), Port: port, Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+
+	ls.mux.Lock()
+	err = ls.applyStatus([]byte(`{"mode": "play", "mixer volume": 40,
+		"playlist_loop": [{"id": 1, "title": "First", "coverid": "abc", "playlist index": "0"}]}`))
+	ls.mux.Unlock()
+	if nil != err {
+		t.Fatal(err)
+	}
+	before := ls.CoverVersion()
+	done := make(chan bool)
+	go func() {
+		ls.updateArt()
+		close(done)
+	}()
+	<-requested
+
+	status := make(chan LMSStatus)
+	go func() { status <- ls.Status() }()
+	select {
+	case st := <-status:
+		if `First` != st.Title {
+			t.Errorf("showing %q, want First", st.Title)
+		}
+	case <-time.After(time.Second):
+		t.Error("player locked while the art is fetched")
+	}
+
+	close(release)
+	<-done
+	if ls.CoverVersion() == before {
+		t.Error("cover not swapped in once served")
+	}
+
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LMSCLI the Logitech Media Server command line interface, port 9090,
+// subscribed to the commands that change what is shown so track, volume,
+// mode and playlist changes are pushed rather than polled for
+type LMSCLI struct {
+	addr      string
+	player    string
+	connected int32 // atomic, 1 while subscribed
+}
+
+// lmsCLICommands the notifications subscribed to
+var lmsCLICommands = []string{`playlist`, `mixer`, `play`, `pause`, `stop`, `time`, `power`, `client`}
+
+// lmsCLIDial how long a connect may take before polling carries on
+const lmsCLIDial = 5 * time.Second
+
+// NewLMSCLI the CLI at host:port, notifications for player only, all
+// players when player is empty
+func NewLMSCLI(host string, port int, player string) *LMSCLI {
+	return &LMSCLI{
+		addr:   net.JoinHostPort(host, fmt.Sprintf("%d", port)),
+		player: player,
+	}
+}
+
+// Connected true while the subscription is up
+func (lc *LMSCLI) Connected() bool {
+	return 1 == atomic.LoadInt32(&lc.connected)
+}
+
+// Listen subscribes and hands each of the player's notifications to
+// notify, the player id and the unescaped terms, until ctx is done, nil,
+// or the connection fails
+func (lc *LMSCLI) Listen(ctx context.Context, notify func(player string, terms []string)) error {
+
+	d := net.Dialer{Timeout: lmsCLIDial}
+	conn, err := d.DialContext(ctx, `tcp`, lc.addr)
+	if nil != err {
+		return sourceError(`lms`, `cli`, err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if _, err = fmt.Fprintf(conn, "subscribe %s\n", strings.Join(lmsCLICommands, `,`)); nil != err {
+		return sourceError(`lms`, `cli`, err)
+	}
+	atomic.StoreInt32(&lc.connected, 1)
+	defer atomic.StoreInt32(&lc.connected, 0)
+	logLMS.Info(`cli subscribed`, `addr`, lc.addr)
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		terms := cliTerms(sc.Text())
+		if len(terms) < 2 || `subscribe` == terms[0] {
+			continue // the echo of the subscribe
+		}
+		if `` != lc.player && `-` != lc.player && !strings.EqualFold(terms[0], lc.player) {
+			continue
+		}
+		mLMSCLIEvents.Inc()
+		notify(terms[0], terms[1:])
+	}
+	if nil != ctx.Err() {
+		return nil
+	}
+	if err = sc.Err(); nil == err {
+		err = fmt.Errorf("%s closed the connection", lc.addr)
+	}
+	return sourceError(`lms`, `cli`, err)
+
+}
+
+// cliTerms splits a CLI line into its terms, each URL escaped
+func cliTerms(line string) []string {
+	terms := strings.Fields(line)
+	for i, t := range terms {
+		if u, err := url.PathUnescape(t); nil == err {
+			terms[i] = u
+		}
+	}
+	return terms
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const cliPlayer = `b8:27:eb:70:71:5c`
+
+// fakeCLI a scripted LMS CLI taking one connection, script gets the
+// subscribe line and writes notifications, closing ends the connection
+func fakeCLI(t *testing.T, script func(subscribe string, w *bufio.Writer)) (string, int) {
+
+	l, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		w := bufio.NewWriter(conn)
+		// the CLI echoes each command, escaped
+		fmt.Fprintf(w, "%s\n", strings.Join(escapeTerms(strings.Fields(line)), ` `))
+		script(strings.TrimSpace(line), w)
+		w.Flush()
+	}()
+	a := l.Addr().(*net.TCPAddr)
+	return a.IP.String(), a.Port
+
+}
+
+func escapeTerms(terms []string) []string {
+	for i, t := range terms {
+		terms[i] = url.PathEscape(t)
+	}
+	return terms
+}
+
+func cliNotification(w *bufio.Writer, terms ...string) {
+	fmt.Fprintf(w, "%s\n", strings.Join(escapeTerms(terms), ` `))
+	w.Flush()
+}
+
+func TestLMSCLIListen(t *testing.T) {
+
+	host, port := fakeCLI(t, func(subscribe string, w *bufio.Writer) {
+		if want := `subscribe ` + strings.Join(lmsCLICommands, `,`); want != subscribe {
+			t.Errorf("subscribed %q, want %q", subscribe, want)
+		}
+		cliNotification(w, cliPlayer, `mixer`, `volume`, `45`)
+		cliNotification(w, `00:04:20:12:34:56`, `playlist`, `newsong`, `Giant Steps`, `1`)
+		cliNotification(w, cliPlayer, `playlist`, `newsong`, `Moment's Notice`, `3`)
+		cliNotification(w, cliPlayer, `pause`, `1`)
+	})
+
+	lc := NewLMSCLI(host, port, cliPlayer)
+	var got [][]string
+	err := lc.Listen(context.Background(), func(player string, terms []string) {
+		if cliPlayer != player {
+			t.Errorf("notified for %s", player)
+		}
+		if !lc.Connected() {
+			t.Error(`notified while not connected`)
+		}
+		got = append(got, terms)
+	})
+	if nil == err {
+		t.Error(`closed connection returned no error`)
+	}
+	if lc.Connected() {
+		t.Error(`connected after the connection closed`)
+	}
+	want := [][]string{
+		{`mixer`, `volume`, `45`},
+		{`playlist`, `newsong`, `Moment's Notice`, `3`},
+		{`pause`, `1`},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+
+}
+
+func TestLMSCLIUnreachable(t *testing.T) {
+
+	l, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	lc := NewLMSCLI(`127.0.0.1`, port, cliPlayer)
+	err = lc.Listen(context.Background(), func(string, []string) {
+		t.Error(`notified without a connection`)
+	})
+	if nil == err {
+		t.Error(`no error from an unreachable CLI`)
+	}
+	if lc.Connected() {
+		t.Error(`connected to an unreachable CLI`)
+	}
+
+}
+
+// TestLMSCLITransport polls while the CLI is down, refreshes only on a
+// new song while it is up, takes the volume and play time from their
+// notifications, and polls again once it drops
+func TestLMSCLITransport(t *testing.T) {
+
+	var rpcs int32
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&rpcs, 1)
+		fmt.Fprint(w, `{"id":0,"method":"slim.request","result":{"mode":"stop","mixer volume":40}}`)
+	}))
+	defer rpc.Close()
+	ru, _ := url.Parse(rpc.URL)
+	rport, _ := strconv.Atoi(ru.Port())
+
+	notify := make(chan []string)
+	drop := make(chan bool)
+	_, port := fakeCLI(t, func(_ string, w *bufio.Writer) {
+		for terms := range notify {
+			cliNotification(w, append([]string{cliPlayer}, terms...)...)
+		}
+		<-drop
+	})
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{
+		Host:         ru.Hostname(),
+		Port:         rport,
+		Player:       cliPlayer,
+		BaseFolder:   cache,
+		SSESEndpoint: `/`,
+		CLIActive:    true,
+		CLIPort:      port,
+		CLIResync:    time.Hour,
+	})
+	defer ls.Close()
+
+	waitRPCs := func(n int32) {
+		t.Helper()
+		for end := time.Now().Add(5 * time.Second); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+			if n == atomic.LoadInt32(&rpcs) {
+				return
+			}
+		}
+		t.Fatalf("%d status requests, want %d", atomic.LoadInt32(&rpcs), n)
+	}
+	waitPlayer := func(what string, ok func(p *LMSPlayer) bool) {
+		t.Helper()
+		for end := time.Now().Add(5 * time.Second); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+			ls.mux.Lock()
+			done := ok(ls.Player)
+			ls.mux.Unlock()
+			if done {
+				return
+			}
+		}
+		t.Fatalf("no %s from the notification", what)
+	}
+
+	ls.pollPlayer()
+	waitRPCs(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- ls.cli.Listen(ctx, ls.cliEvent)
+	}()
+	for end := time.Now().Add(5 * time.Second); !ls.cli.Connected(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatal(`CLI never connected`)
+		}
+	}
+	if s := ls.Status().Transport; `cli` != s {
+		t.Errorf("transport %q, want cli", s)
+	}
+
+	for i := 0; i < 5; i++ {
+		ls.pollPlayer()
+	}
+	waitRPCs(1)
+
+	// from the payload, no status request
+	notify <- []string{`mixer`, `volume`, `45`}
+	waitPlayer(`volume`, func(p *LMSPlayer) bool { return 45 == p.Volume })
+	notify <- []string{`time`, `61.5`}
+	waitPlayer(`play time`, func(p *LMSPlayer) bool { return 61.5 == p.time })
+	waitRPCs(1)
+
+	notify <- []string{`playlist`, `newsong`, `Giant Steps`, `1`}
+	waitRPCs(2)
+
+	close(notify)
+	close(drop)
+	<-done
+	if s := ls.Status().Transport; `poll` != s {
+		t.Errorf("transport %q, want poll", s)
+	}
+	ls.pollPlayer()
+	waitRPCs(3)
+
+}
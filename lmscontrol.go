@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lmsCommands the transport commands, each the LMS request for a value;
+// an empty value toggles, cycles or steps as the command allows
+var lmsCommands = map[string]func(v string) ([]string, error){
+	`play`: noValue(`play`),
+	`stop`: noValue(`stop`),
+	`pause`: func(v string) ([]string, error) {
+		return optionalValue(v, []string{`pause`}, `0`, `1`) // 1 pauses, 0 resumes, empty toggles
+	},
+	`next`:     noValue(`playlist`, `index`, `+1`),
+	`previous`: noValue(`playlist`, `index`, `-1`),
+	`volume`: func(v string) ([]string, error) {
+		if err := stepValue(v, 0, 100); nil != err {
+			return nil, err
+		}
+		return []string{`mixer`, `volume`, v}, nil // 40 sets, +5 and -5 step
+	},
+	`seek`: func(v string) ([]string, error) {
+		if err := stepValue(v, 0, 24*60*60); nil != err {
+			return nil, err
+		}
+		return []string{`time`, v}, nil // seconds in, +10 and -10 skip
+	},
+	`shuffle`: func(v string) ([]string, error) {
+		return optionalValue(v, []string{`playlist`, `shuffle`}, `0`, `1`, `2`) // off, songs, albums
+	},
+	`repeat`: func(v string) ([]string, error) {
+		return optionalValue(v, []string{`playlist`, `repeat`}, `0`, `1`, `2`) // off, song, playlist
+	},
+	`power`: func(v string) ([]string, error) {
+		return optionalValue(v, []string{`power`}, `0`, `1`)
+	},
+	`favorite`: func(v string) ([]string, error) {
+		if !favoriteID.MatchString(v) {
+			return nil, fmt.Errorf("favorite %q is not an item id such as 3 or 2.1", v)
+		}
+		return []string{`favorites`, `playlist`, `play`, `item_id:` + v}, nil
+	},
+	`preset`: func(v string) ([]string, error) {
+		n, err := strconv.Atoi(v)
+		if nil != err || n < 1 || n > 10 {
+			return nil, fmt.Errorf("preset %q is not 1..10", v)
+		}
+		return []string{`button`, fmt.Sprintf("preset_%d.single", n)}, nil
+	},
+}
+
+// favoriteID a favorites item id, a folder's items are 2.1, 2.2, ...
+var favoriteID = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// noValue a command taking no value
+func noValue(req ...string) func(v string) ([]string, error) {
+	return func(v string) ([]string, error) {
+		if `` != v {
+			return nil, fmt.Errorf("takes no value, have %q", v)
+		}
+		return req, nil
+	}
+}
+
+// optionalValue cmd alone, which LMS toggles or cycles, or with one of the
+// allowed values
+func optionalValue(v string, cmd []string, allowed ...string) ([]string, error) {
+	if `` == v {
+		return cmd, nil
+	}
+	for _, a := range allowed {
+		if a == v {
+			return append(append([]string{}, cmd...), v), nil
+		}
+	}
+	return nil, fmt.Errorf("value %q is not one of %v", v, allowed)
+}
+
+// stepValue an absolute value min..max, or a +n or -n step
+func stepValue(v string, min, max int) error {
+	if `` == v {
+		return fmt.Errorf("needs a value, %d..%d or a +n/-n step", min, max)
+	}
+	n, err := strconv.Atoi(v)
+	if nil != err {
+		return fmt.Errorf("value %q is not a number", v)
+	}
+	if '+' != v[0] && '-' != v[0] && (n < min || n > max) {
+		return fmt.Errorf("value %d is not %d..%d", n, min, max)
+	}
+	return nil
+}
+
+// LMSCommands the transport command names
+func LMSCommands() []string {
+	names := make([]string, 0, len(lmsCommands))
+	for n := range lmsCommands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lmsRequest the LMS request for command with value
+func lmsRequest(command, value string) ([]string, error) {
+	build, ok := lmsCommands[command]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q, one of %v", command, LMSCommands())
+	}
+	req, err := build(value)
+	if nil != err {
+		return nil, fmt.Errorf("%s: %v", command, err)
+	}
+	return req, nil
+}
+
+// Control sends a transport command to the player shown, the next poll
+// reads the full status whether or not the CLI is up
+func (ls *LMSServer) Control(command, value string) error {
+
+	req, err := lmsRequest(command, value)
+	if nil != err {
+		return err
+	}
+	if nil != fixtures {
+		return fmt.Errorf("%s: replaying, no player to control", command)
+	}
+
+	ls.mux.Lock()
+	player := ls.Player.MAC
+	ls.mux.Unlock()
+	if _, err = ls.request(player, req); nil != err {
+		return sourceError(`lms`, command, err)
+	}
+	logLMS.Info(`control`, `player`, player, `command`, command, `value`, value)
+	ls.mux.Lock()
+	ls.synced = time.Time{}
+	ls.mux.Unlock()
+	return nil
+
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLMSRequest(t *testing.T) {
+
+	for _, tc := range []struct {
+		command, value string
+		want           []string // nil for an error
+	}{
+		{`play`, ``, []string{`play`}},
+		{`play`, `1`, nil},
+		{`pause`, ``, []string{`pause`}},
+		{`pause`, `1`, []string{`pause`, `1`}},
+		{`pause`, `2`, nil},
+		{`next`, ``, []string{`playlist`, `index`, `+1`}},
+		{`previous`, ``, []string{`playlist`, `index`, `-1`}},
+		{`volume`, `40`, []string{`mixer`, `volume`, `40`}},
+		{`volume`, `+5`, []string{`mixer`, `volume`, `+5`}},
+		{`volume`, `101`, nil},
+		{`volume`, ``, nil},
+		{`seek`, `-10`, []string{`time`, `-10`}},
+		{`shuffle`, ``, []string{`playlist`, `shuffle`}},
+		{`repeat`, `2`, []string{`playlist`, `repeat`, `2`}},
+		{`power`, `0`, []string{`power`, `0`}},
+		{`favorite`, `2.1`, []string{`favorites`, `playlist`, `play`, `item_id:2.1`}},
+		{`favorite`, `jazz`, nil},
+		{`preset`, `3`, []string{`button`, `preset_3.single`}},
+		{`preset`, `0`, nil},
+		{`eject`, ``, nil},
+	} {
+		got, err := lmsRequest(tc.command, tc.value)
+		if nil == tc.want {
+			if nil == err {
+				t.Errorf("%s %q: no error, request %q", tc.command, tc.value, got)
+			}
+			continue
+		}
+		if nil != err || !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s %q: request %q, %v, want %q", tc.command, tc.value, got, err, tc.want)
+		}
+	}
+
+}
+
+// TestLMSControl a command is one request, the status follows on the
+// next poll rather than from a goroutine of its own
+func TestLMSControl(t *testing.T) {
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(rw, `{"id":0,"method":"slim.request","result":{}}`)
+	}))
+	defer srv.Close()
+	su, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(su.Port())
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Host: su.Hostname(), Port: port, Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+
+	ls.synced = timeNow()
+	if err = ls.Control(`pause`, ``); nil != err {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); 1 != n {
+		t.Errorf("%d requests, want the command alone", n)
+	}
+	ls.mux.Lock()
+	due := ls.synced.IsZero()
+	ls.mux.Unlock()
+	if !due {
+		t.Error("full status not due on the next poll")
+	}
+
+}
+
+func TestParseKeys(t *testing.T) {
+
+	km, err := parseKeys(map[string]string{`key_playpause`: `pause`, `115`: `volume +5`})
+	if nil != err {
+		t.Fatal(err)
+	}
+	want := map[uint16]keyCommand{164: {`pause`, ``}, 115: {`volume`, `+5`}}
+	if !reflect.DeepEqual(want, km) {
+		t.Errorf("keys %v, want %v", km, want)
+	}
+
+	for _, bad := range []map[string]string{{`KEY_NOPE`: `pause`}, {`KEY_1`: `volume`}, {`KEY_1`: ``}} {
+		if _, err := parseKeys(bad); nil == err {
+			t.Errorf("%v: no error", bad)
+		}
+	}
+
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -14,11 +15,14 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	svg "github.com/ajstarks/svgo"
 	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
 	"golang.org/x/image/font"
@@ -78,6 +82,7 @@ type LMSDetail struct {
 		Genre       string      `json:"genre,omitempty"`
 		Bitrate     string      `json:"bitrate"`
 		Coverid     string      `json:"coverid"`
+		ArtworkURL  string      `json:"artwork_url"`
 		Duration    interface{} `json:"duration"`
 		ID          interface{} `json:"id"`
 		URL         string      `json:"url,omitempty"`
@@ -244,13 +249,17 @@ type (
 	}
 	// LMSServer limited to a single player for current usage
 	LMSServer struct {
+		coverver      uint64 // bumped on each cover redraw, first for 64-bit atomic alignment on arm
 		id            int
 		host          string
 		port          int
 		web           string
 		url           string
 		sses          SSES
-		arturl        string
+		coverSize     int         // cover art pixels, fetched at the size shown
+		artTrack      string      // the track keyless art was last fetched for
+		artDue        *coverFetch // the cover to fetch, taken by updateArt
+		artGen        uint64      // bumped per cover due, older fetches are dropped
 		coverart      draw.Image
 		defaultart    draw.Image
 		volume        draw.Image
@@ -266,6 +275,30 @@ type (
 		color         color.Color
 		cacache       *CACache
 		update        chan bool
+		ctx           context.Context    // done on Close, ends the event stream
+		cancel        context.CancelFunc
+		cli           *LMSCLI            // pushed changes, nil to poll
+		clicancel     context.CancelFunc
+		resync        time.Duration // full status refresh while the CLI is up
+		synced        time.Time
+		ticked        time.Time
+		auto          bool // follow whichever player is playing
+		pinned        string
+		priority      []string
+		showRoom      bool
+		room          string // the player shown, when there are several
+		players       []LMSPlayerInfo
+		playersEvery  time.Duration
+		playersUpdate chan bool
+		queueLen      int // tracks up next fetched
+		queue         []QueueTrack
+		queued        int32 // len(queue), read by the render side without the lock
+		queueIndex    int   // playlist index of the current track
+		queueTracks   int
+		queueStamp    float64
+		queueStale    bool                   // playlist changed, durations to read
+		queueTimes    []float64              // by playlist index
+		thumbs        map[string]image.Image // queue thumbnails by coverid
 	}
 )
 
@@ -380,6 +413,15 @@ type LMSConfig struct {
 	SSESHost     string
 	SSESPort     int
 	SSESEndpoint string
+	CLIActive    bool
+	CLIPort      int
+	CLIResync    time.Duration
+	PlayersAuto  bool
+	PlayersEvery time.Duration
+	Priority     []string // player ids or names, first shown first
+	Room         bool
+	Queue        int // tracks up next
+	CoverSize    int // cover art pixels, 500 when unset
 }
 
 // NewLMSServer initiates an LMS server instance
@@ -393,16 +435,20 @@ func NewLMSServer(lc LMSConfig) *LMSServer {
 	ls.host = lc.Host
 	ls.port = lc.Port
 	ls.web = fmt.Sprintf("http://%s:%d", lc.Host, lc.Port)
-	ls.arturl = fmt.Sprintf("%s/music/current/cover.jpg?player=%s", ls.web, lc.Player)
-	ls.coverart = imaging.New(500, 500, color.NRGBA{0, 0, 0, 0})
+	ls.coverSize = lc.CoverSize
+	if 0 == ls.coverSize {
+		ls.coverSize = 500
+	}
+	ls.coverart = imaging.New(ls.coverSize, ls.coverSize, color.NRGBA{0, 0, 0, 0})
 
 	i := getIcon(`vinyl2`)
-	i.scale = 500 / float64(i.width)
+	i.scale = float64(ls.coverSize) / float64(i.width)
 	ls.defaultart, _ = getImageIconWIP(i)
 
 	ls.url = fmt.Sprintf("%s/jsonrpc.js", ls.web)
 	ls.web += `/`
 	ls.Player = NewLMSPlayer(lc.Player)
+	ls.Player.arturl = ls.artURL(coverArt{}, lc.Player, ls.coverSize)
 	ls.volume = image.NewRGBA(image.Rect(0, 0, 24, 16))
 	ls.playmodifiers = image.NewRGBA(image.Rect(0, 0, 28, 16))
 	ls.face = basicfont.Face7x13
@@ -413,7 +459,7 @@ func NewLMSServer(lc LMSConfig) *LMSServer {
 	ls.sses.active = lc.SSESActive
 	ls.sses.host = lc.SSESHost
 	ls.sses.port = lc.SSESPort
-	if lc.SSESEndpoint[0] != '/' {
+	if !strings.HasPrefix(lc.SSESEndpoint, `/`) {
 		lc.SSESEndpoint = `/` + lc.SSESEndpoint
 	}
 	ls.sses.endpoint = lc.SSESEndpoint
@@ -437,6 +483,20 @@ func NewLMSServer(lc LMSConfig) *LMSServer {
 		ls.initVUBase()
 	}
 
+	ls.auto, ls.pinned, ls.priority = lc.PlayersAuto, lc.Player, lc.Priority
+	ls.showRoom, ls.playersEvery = lc.Room, lc.PlayersEvery
+	ls.queueLen, ls.thumbs = lc.Queue, make(map[string]image.Image)
+
+	if lc.CLIActive {
+		follow := lc.Player
+		if ls.auto {
+			follow = `` // every player, any may be the one to show
+		}
+		ls.cli = NewLMSCLI(lc.Host, lc.CLIPort, follow)
+		ls.resync = lc.CLIResync
+	}
+
+	ls.ctx, ls.cancel = context.WithCancel(supervisor.Context())
 	if ls.sses.active {
 		ls.sseclient()
 	}
@@ -447,15 +507,28 @@ func NewLMSServer(lc LMSConfig) *LMSServer {
 
 func (ls *LMSServer) sseclient() {
 	ls.sses.events = make(chan *SSEvent)
-	go ssenotifyacquire(ls.sses.url, ls.sses.events)
-	go ls.consumeEvents()
+	supervisor.GoContext(ls.ctx, `sses`, func(ctx context.Context) error {
+		// a failed connect deactivates the meters for good, no restart
+		if nil != fixtures {
+			fixtures.replaySSES(ctx, ls.sses.url, ls.sses.events)
+		} else {
+			ssenotifyacquire(ctx, ls.sses.url, ls.sses.events)
+		}
+		return nil
+	})
+	supervisor.GoContext(ls.ctx, `sses-consume`, func(ctx context.Context) error {
+		ls.consumeEvents(ctx)
+		return nil
+	})
 }
 
-func (ls *LMSServer) consumeEvents() {
+// consumeEvents draws the meters from the event stream until it goes
+// inactive or ctx is done
+func (ls *LMSServer) consumeEvents(ctx context.Context) {
 	if ls.sses.active {
 
-		wsa := lms.vulayout.baseImage.Bounds().Max.X
-		hsa := lms.vulayout.baseImage.Bounds().Max.Y
+		wsa := ls.vulayout.baseImage.Bounds().Max.X
+		hsa := ls.vulayout.baseImage.Bounds().Max.Y
 		ssecanvas := image.NewRGBA(image.Rect(0, 0, wsa, hsa))
 		govu := false
 
@@ -474,24 +547,33 @@ func (ls *LMSServer) consumeEvents() {
 		// SA scaling
 		multiSA := float64(hsa-1) / 31.00 // max input is 31 -2 to leave head-room
 
-		for event := range ls.sses.events {
+		for {
+
+			var event *SSEvent
+			select {
+			case event = <-ls.sses.events:
+			case <-ctx.Done():
+				return
+			}
 
 			// if we get an exception - review what happens here
 			if !event.Active {
 				ls.sses.active = false
-				close(ls.sses.events)
-				fmt.Println(`Inactive SSES, exit event stream`)
+				logSSES.Warn(`inactive SSES, exit event stream`, `url`, ls.sses.url)
 				return // and stop consuming...
 			}
 
+			mSSEEvents.Inc()
 			var m Meter
 			b, err := ioutil.ReadAll(event.Data)
 			if err != nil {
-				panic(err)
+				mSSEMalformed.Inc()
+				continue
 			}
 			good := true
 			if err := json.Unmarshal(b, &m); err != nil {
-				fmt.Println(err) // observed incomplete JSON - timing thread safe pointers ???
+				logSSES.Debug(`malformed event`, `err`, err) // observed incomplete JSON - timing thread safe pointers ???
+				mSSEMalformed.Inc()
 				good = false
 			}
 			dirty := false
@@ -608,12 +690,18 @@ func getRandomColor(alpha uint8) color.RGBA {
 
 // Close and clear the associated cache
 func (ls *LMSServer) Close() {
+	if nil != ls.cancel {
+		ls.cancel()
+	}
 	ls.cacache.Close()
 }
 
 // PlayerMAC sets player MAC - useful if current player changes
 func (ls *LMSServer) PlayerMAC(player string) {
-	ls.Player.MAC = player
+	ls.mux.Lock()
+	ls.switchPlayer(player)
+	ls.setRoom()
+	ls.mux.Unlock()
 }
 
 // SSESAddress returns sses server address if active
@@ -637,7 +725,14 @@ func (ls *LMSServer) requestAndKey(params interface{}, key string) (interface{},
 
 }
 
-func (ls *LMSServer) request(player string, params interface{}) (interface{}, error) {
+func (ls *LMSServer) request(player string, params interface{}) (res interface{}, err error) {
+
+	defer func(start time.Time) {
+		mLMSRPCSeconds.Since(start)
+		if nil != err {
+			mLMSRPCErrors.Inc()
+		}
+	}(time.Now())
 
 	if `` == player {
 		player = `-`
@@ -682,157 +777,335 @@ func (ls *LMSServer) request(player string, params interface{}) (interface{}, er
 
 }
 
-// Start initiates the schedule update
+// Start initiates the schedule update, and the CLI subscription when set
 func (ls *LMSServer) Start() {
-	ls.update = sched(ls.updatePlayer, 400*time.Millisecond)
+	ls.update = sched(ls.pollPlayer, 400*time.Millisecond)
+	if ls.auto && nil == fixtures {
+		ls.playersUpdate = sched(ls.updatePlayers, ls.playersEvery)
+	}
+	if nil != ls.cli && nil == fixtures {
+		ls.cliclient()
+	}
 	ls.Player.Start()
 }
 
-// Stop the schedule updates and close event channel if active
+// Stop the schedule updates and CLI, the event stream ends on shutdown
 func (ls *LMSServer) Stop() {
 	ls.update <- true
-	if ls.sses.active {
-		close(ls.sses.events)
+	if nil != ls.playersUpdate {
+		ls.playersUpdate <- true
+	}
+	if nil != ls.clicancel {
+		ls.clicancel()
 	}
 	ls.Player.Stop()
 }
 
+// cliclient keeps the CLI subscription up, a failed connect is retried
+// with the supervisor backoff while the schedule polls
+func (ls *LMSServer) cliclient() {
+	var ctx context.Context
+	ctx, ls.clicancel = context.WithCancel(supervisor.Context())
+	supervisor.GoContext(ctx, `lmscli`, func(ctx context.Context) error {
+		return ls.cli.Listen(ctx, ls.cliEvent)
+	})
+}
+
+// cliEvent a CLI notification: a new song, power or client change fetches
+// the full status, the play time and volume come from the event itself,
+// anything else waits for the next poll to resync
+func (ls *LMSServer) cliEvent(player string, terms []string) {
+
+	logLMS.Debug(`cli`, `player`, player, `event`, strings.Join(terms, ` `))
+	if ls.auto && !ls.follows(player) {
+		ls.updatePlayers() // another room started or stopped
+		return
+	}
+	arg := func(i int) string {
+		if i < len(terms) {
+			return terms[i]
+		}
+		return ``
+	}
+	switch arg(0) {
+	case `power`, `client`, `play`:
+		ls.updatePlayer()
+		return
+	case `playlist`:
+		if `newsong` == arg(1) {
+			ls.updatePlayer()
+			return
+		}
+	}
+
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	switch arg(0) {
+	case `time`:
+		if t, err := strconv.ParseFloat(arg(1), 64); nil == err {
+			ls.Player.setTime(t)
+			ls.ticked = timeNow()
+			return
+		}
+	case `mixer`:
+		// +n and -n are relative, the resync reads the result
+		v := arg(2)
+		n, err := strconv.Atoi(v)
+		if `volume` == arg(1) && nil == err && '+' != v[0] && '-' != v[0] {
+			ls.Player.Volume = n
+			if ls.Player.Volume != ls.Player.lastVol {
+				ls.setVolume()
+			}
+			ls.Player.lastVol = ls.Player.Volume
+			return
+		}
+	}
+	ls.synced = time.Time{}
+
+}
+
+// pollPlayer the scheduled update: the full status while polling, with
+// the CLI up only the play time moves on between its events and resyncs
+func (ls *LMSServer) pollPlayer() {
+	if nil != ls.cli && ls.cli.Connected() {
+		ls.mux.Lock()
+		due := timeNow().Sub(ls.synced) >= ls.resync
+		if !due {
+			ls.tickPlayer()
+		}
+		ls.mux.Unlock()
+		if !due {
+			return
+		}
+	}
+	ls.updatePlayer()
+}
+
+// tickPlayer moves the play time on by the time since the last status,
+// caller holds the lock
+func (ls *LMSServer) tickPlayer() {
+	now := timeNow()
+	p := ls.Player
+	if `play` == p.Mode && !ls.ticked.IsZero() {
+		t := p.time + now.Sub(ls.ticked).Seconds()
+		if p.duration > 0 && t > p.duration {
+			t = p.duration
+		}
+		p.setTime(t)
+	}
+	ls.ticked = now
+}
+
 func (ls *LMSServer) updatePlayer() {
 
 	defer func() {
-		if err := recover(); err != nil {
-			ls.mux.Unlock() // ensure unlock
-			fmt.Println(`exception recovered`, err)
+		if r := recover(); r != nil {
+			health.Fail(sourceError(`lms`, `status`, fmt.Errorf("%v", r)))
 		}
 	}()
 
+	if !ls.updateStatus() {
+		return
+	}
+	ls.updateArt()
+	if err := ls.refreshQueue(); nil != err {
+		logLMS.Debug(`queue durations failed`, `err`, err) // the fetched tracks only
+	}
+
+}
+
+// updateStatus reads the player status without the lock, a slow server
+// holds back neither the render loop nor the API, and applies it under
+// the lock, released on a panic too; false when the status failed
+func (ls *LMSServer) updateStatus() bool {
+
 	ls.mux.Lock()
-	vs, err := ls.request(ls.Player.MAC, []string{"status", "-", "1", "tags:cgABbehldiqtyrSuoKLNJITC"})
-	if nil != vs && err == nil {
+	player := ls.Player.MAC
+	ls.mux.Unlock()
 
-		if v, ok := vs.(map[string]interface{}); ok {
+	b, err := ls.fetchStatus(player)
 
-			b, err := json.Marshal(v)
-			if nil != err {
-				panic(err)
-			}
-			s := LMSDetail{}
-			err = json.Unmarshal(b, &s)
-			if nil != err {
-				panic(err)
-			}
-			ls.Player.Mode = s.Mode
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if player != ls.Player.MAC {
+		return false // switched meanwhile, the new player's status follows
+	}
+	if nil == err {
+		err = ls.applyStatus(b)
+	}
+	if nil != err {
+		health.Fail(err)
+		// keep the last track on screen until the player is plainly gone
+		if health.Stale(`lms`) {
+			ls.Player.Mode = `unknown`
+		}
+		return false
+	}
+	now := timeNow()
+	ls.synced, ls.ticked = now, now
+	health.OK(`lms`)
+	return true
 
-			if `play` == s.Mode {
+}
 
-				ckcd := ls.Player.coverid
-				ls.Player.Volume = s.MixerVolume
+// fetchStatus reads the player status and the tracks up next, called
+// without the lock
+func (ls *LMSServer) fetchStatus(player string) ([]byte, error) {
 
-				if ls.Player.Volume != ls.Player.lastVol {
-					ls.setVolume()
-				}
+	if nil != fixtures {
+		b, err := fixtures.Payload(`lms.json`, timeNow())
+		if nil != err {
+			return nil, sourceError(`lms`, `status`, err)
+		}
+		return b, nil
+	}
 
-				if t, ok := s.Time.(float64); ok {
-					ls.Player.setTime(t)
-				}
-				if d, ok := s.Duration.(float64); ok {
-					ls.Player.setDuration(d)
-				}
+	vs, err := ls.request(player, []string{"status", "-", strconv.Itoa(1 + ls.queueLen), "tags:cgABbehldiqtyrSuoKLNJITC"})
+	if nil != err {
+		return nil, sourceError(`lms`, `status`, err)
+	}
+	v, ok := vs.(map[string]interface{})
+	if !ok {
+		return nil, sourceError(`lms`, `status`, fmt.Errorf("unexpected result %T", vs))
+	}
+	b, err := json.Marshal(v)
+	if nil != err {
+		return nil, sourceError(`lms`, `decode`, err)
+	}
+	return b, nil
 
-				ls.Player.repeat = s.PlaylistRepeat
-				ls.Player.shuffle = s.PlaylistShuffle
+}
 
-				if ls.Player.lastRepeat != ls.Player.repeat ||
-					ls.Player.lastShuffle != ls.Player.shuffle {
-					ls.setPlayModifiers()
-				}
+// applyStatus takes a status query result, the cover art is left due for
+// updateArt; caller holds the lock
+func (ls *LMSServer) applyStatus(b []byte) error {
 
-				ls.Player.lastVol = ls.Player.Volume
-				ls.Player.lastRepeat = ls.Player.repeat
-				ls.Player.lastShuffle = ls.Player.shuffle
-
-				// remote
-				ls.Player.remote = (1 == s.Remote)
-				if ls.Player.remote {
-					ls.Player.Artist.SetText(s.RemoteMeta.Artist)
-					ls.Player.Albumartist.SetText(s.RemoteMeta.Artist)
-					title := s.RemoteMeta.RemoteTitle
-					if `` == title {
-						title = s.RemoteMeta.Title
-					}
-					ls.Player.Title.SetText(title)
-					ls.Player.Composer.SetText(``)
-					ls.Player.Conductor.SetText(``)
-					ls.Player.Album.SetText(title)
-					ls.Player.Year = s.RemoteMeta.Year
-					ls.Player.Genre = s.RemoteMeta.Genre
-					ls.Player.coverid = s.RemoteMeta.Coverid
-					ls.Player.Bitrate = s.RemoteMeta.Bitrate
-					ls.Player.Bitty = fmt.Sprintf("• %v •", ls.Player.Bitrate)
+	s := LMSDetail{}
+	err := json.Unmarshal(b, &s)
+	if nil != err {
+		return sourceError(`lms`, `decode`, err)
+	}
+	ls.Player.Mode = s.Mode
 
-				} else {
-					artist := s.PlaylistLoop[0].Artist
-					if artist == `` {
-						artist = s.PlaylistLoop[0].Trackartist
-					}
-					ls.Player.Bitrate = s.PlaylistLoop[0].Bitrate
-					f := s.PlaylistLoop[0].Samplesize
-					ls.Player.Samplesize, err = strconv.ParseFloat(f, 64)
-					if nil != err {
-						ls.Player.Samplesize = 16
-					}
-					f = s.PlaylistLoop[0].Samplerate
-					ls.Player.Samplerate, err = strconv.ParseFloat(f, 64)
-					if nil != err {
-						ls.Player.Samplerate = 44.1
-					} else {
-						ls.Player.Samplerate /= 1000
-					}
-					ls.Player.Artist.SetText(artist)
-					ls.Player.Album.SetText(s.PlaylistLoop[0].Album)
-					ls.Player.Composer.SetText(s.PlaylistLoop[0].Composer)
-					ls.Player.Conductor.SetText(s.PlaylistLoop[0].Conductor)
-					ls.Player.Title.SetText(s.PlaylistLoop[0].Title)
-					ls.Player.Compilation = s.PlaylistLoop[0].Compilation
-					if `1` == ls.Player.Compilation {
-						ls.Player.Albumartist.SetText(`Various Artists`)
-					} else {
-						ls.Player.Albumartist.SetText(s.PlaylistLoop[0].Albumartist)
-					}
-					if `` == ls.Player.Albumartist.text {
-						ls.Player.Albumartist.SetText(ls.Player.Artist.text)
-					}
-					ls.Player.Year = s.PlaylistLoop[0].Year
-					ls.Player.Genre = s.PlaylistLoop[0].Genre
-					ls.Player.coverid = s.PlaylistLoop[0].Coverid
-
-					switch ls.Player.Samplesize {
-					case 1:
-						ls.Player.Bitty = fmt.Sprintf("• DSD%v •", math.Floor(ls.Player.Samplerate/44.1))
-					default:
-						ls.Player.Bitty = fmt.Sprintf("%vb • %vkHz", ls.Player.Samplesize, ls.Player.Samplerate)
-					}
+	if `play` == s.Mode {
 
-				}
-				if "" == ls.Player.Year || "0" == ls.Player.Year {
-					ls.Player.Year = "????"
-				}
+		ckcd := ls.Player.coverid
+		ls.Player.Volume = s.MixerVolume
 
-				if ckcd != ls.Player.coverid {
-					err = ls.cacheImage()
-					checkFatal(err) // will be caught...
-				}
+		if ls.Player.Volume != ls.Player.lastVol {
+			ls.setVolume()
+		}
+
+		if t, ok := s.Time.(float64); ok {
+			ls.Player.setTime(t)
+		}
+		if d, ok := s.Duration.(float64); ok {
+			ls.Player.setDuration(d)
+		}
+
+		ls.Player.repeat = s.PlaylistRepeat
+		ls.Player.shuffle = s.PlaylistShuffle
+
+		if ls.Player.lastRepeat != ls.Player.repeat ||
+			ls.Player.lastShuffle != ls.Player.shuffle {
+			ls.setPlayModifiers()
+		}
+
+		ls.Player.lastVol = ls.Player.Volume
+		ls.Player.lastRepeat = ls.Player.repeat
+		ls.Player.lastShuffle = ls.Player.shuffle
+
+		// remote
+		ls.Player.remote = (1 == s.Remote)
+		if ls.Player.remote {
+			ls.Player.Artist.SetText(s.RemoteMeta.Artist)
+			ls.Player.Albumartist.SetText(s.RemoteMeta.Artist)
+			title := s.RemoteMeta.RemoteTitle
+			if `` == title {
+				title = s.RemoteMeta.Title
+			}
+			ls.Player.Title.SetText(title)
+			ls.Player.Composer.SetText(``)
+			ls.Player.Conductor.SetText(``)
+			ls.Player.Album.SetText(title)
+			ls.Player.Year = s.RemoteMeta.Year
+			ls.Player.Genre = s.RemoteMeta.Genre
+			art := coverArt{coverid: s.RemoteMeta.Coverid, url: s.RemoteMeta.ArtworkURL, remote: true}
+			if `` == art.url && len(s.PlaylistLoop) > 0 {
+				art.url = s.PlaylistLoop[0].ArtworkURL
+			}
+			ls.setArt(art)
+			ls.Player.Bitrate = s.RemoteMeta.Bitrate
+			ls.Player.Bitty = fmt.Sprintf("• %v •", ls.Player.Bitrate)
+
+		} else if len(s.PlaylistLoop) > 0 {
+			artist := s.PlaylistLoop[0].Artist
+			if artist == `` {
+				artist = s.PlaylistLoop[0].Trackartist
+			}
+			ls.Player.Bitrate = s.PlaylistLoop[0].Bitrate
+			f := s.PlaylistLoop[0].Samplesize
+			ls.Player.Samplesize, err = strconv.ParseFloat(f, 64)
+			if nil != err {
+				ls.Player.Samplesize = 16
+			}
+			f = s.PlaylistLoop[0].Samplerate
+			ls.Player.Samplerate, err = strconv.ParseFloat(f, 64)
+			if nil != err {
+				ls.Player.Samplerate = 44.1
+			} else {
+				ls.Player.Samplerate /= 1000
+			}
+			ls.Player.Artist.SetText(artist)
+			ls.Player.Album.SetText(s.PlaylistLoop[0].Album)
+			ls.Player.Composer.SetText(s.PlaylistLoop[0].Composer)
+			ls.Player.Conductor.SetText(s.PlaylistLoop[0].Conductor)
+			ls.Player.Title.SetText(s.PlaylistLoop[0].Title)
+			ls.Player.Compilation = s.PlaylistLoop[0].Compilation
+			if `1` == ls.Player.Compilation {
+				ls.Player.Albumartist.SetText(`Various Artists`)
 			} else {
-				ls.volinit = false
+				ls.Player.Albumartist.SetText(s.PlaylistLoop[0].Albumartist)
+			}
+			if `` == ls.Player.Albumartist.text {
+				ls.Player.Albumartist.SetText(ls.Player.Artist.text)
+			}
+			ls.Player.Year = s.PlaylistLoop[0].Year
+			ls.Player.Genre = s.PlaylistLoop[0].Genre
+			ls.setArt(coverArt{
+				coverid: s.PlaylistLoop[0].Coverid,
+				trackID: s.PlaylistLoop[0].ArtworkTrackID,
+				url:     s.PlaylistLoop[0].ArtworkURL,
+			})
+
+			switch ls.Player.Samplesize {
+			case 1:
+				ls.Player.Bitty = fmt.Sprintf("• DSD%v •", math.Floor(ls.Player.Samplerate/44.1))
+			default:
+				ls.Player.Bitty = fmt.Sprintf("%vb • %vkHz", ls.Player.Samplesize, ls.Player.Samplerate)
 			}
 
-		} else {
-			ls.Player.Mode = `unknown`
+		}
+		ls.setQueue(&s)
+		if "" == ls.Player.Year || "0" == ls.Player.Year {
+			ls.Player.Year = "????"
+		}
+
+		// keyless art is the current track's, refetched as the track changes
+		track := ls.Player.Title.text
+		if len(s.PlaylistLoop) > 0 {
+			track = fmt.Sprint(s.PlaylistLoop[0].ID, ` `, track)
+		}
+		refetch := ckcd != ls.Player.coverid || (`` == ls.Player.coverid && track != ls.artTrack)
+		ls.artTrack = track
+		if refetch {
+			ls.artGen++
+			ls.artDue = &coverFetch{key: ls.Player.coverid, url: ls.Player.arturl, gen: ls.artGen}
 		}
 	} else {
-		ls.Player.Mode = `unknown`
+		ls.volinit = false
 	}
-	ls.mux.Unlock()
+	return nil
 
 }
 
@@ -841,6 +1114,11 @@ func (ls *LMSServer) Coverart() draw.Image {
 	return ls.coverart
 }
 
+// CoverVersion changes whenever the cover image is redrawn
+func (ls *LMSServer) CoverVersion() uint64 {
+	return atomic.LoadUint64(&ls.coverver)
+}
+
 // VUActive meter is active
 func (ls *LMSServer) VUActive() bool {
 	return (ls.sses.active && `` != ls.vulayout.meter)
@@ -969,11 +1247,9 @@ func (ls *LMSServer) getImage(r io.Reader) (image.Image, error) {
 	// need to make this buffered and cancel-able!
 	im, err := imaging.Decode(r, imaging.AutoOrientation(true))
 	if err != nil {
-		ls.drawBase(true)
-		//im = ls.defaultart // ????????
 		return im, err
 	}
-	im = imaging.Resize(im, 500, 500, imaging.Lanczos)
+	im = imaging.Resize(im, ls.coverSize, ls.coverSize, imaging.Lanczos)
 	return im, nil
 
 }
@@ -984,28 +1260,72 @@ func (ls *LMSServer) drawBase(defart bool) {
 	} else {
 		draw.Draw(ls.coverart, ls.coverart.Bounds(), &image.Uniform{color.Black}, image.ZP, draw.Src)
 	}
+	atomic.AddUint64(&ls.coverver, 1)
 }
 
-func (ls *LMSServer) cacheImageBackground() {
-	err := ls.cacheImage()
-	checkFatal(err)
+// artClient fetches cover art, a stalled server must not hold the art
+// back for good
+var artClient = &http.Client{Timeout: 10 * time.Second}
+
+// getArt requests art from u, any status but 200 is an error
+func getArt(u string) (*http.Response, error) {
+	resp, err := artClient.Get(u)
+	if nil != err {
+		return nil, err
+	}
+	if http.StatusOK != resp.StatusCode {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return resp, nil
 }
 
-func (ls *LMSServer) cacheImage() error {
+// updateArt fetches the cover applyStatus left due without the lock, a
+// slow art server holds back the art alone, and swaps it in unless a
+// later track's is due meanwhile
+func (ls *LMSServer) updateArt() {
 
-	// check if we have the cover cached
-	im, ok := ls.cacache.GetImage(ls.Player.coverid)
-	if ok {
+	ls.mux.Lock()
+	due := ls.artDue
+	ls.artDue = nil
+	ls.mux.Unlock()
+	if nil == due {
+		return
+	}
 
-		ls.drawBase(false)
-		draw.Draw(ls.coverart, ls.coverart.Bounds(), im, image.ZP, draw.Src)
-		return nil
+	im, err := ls.loadArt(due)
+
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if due.gen != ls.artGen {
+		return // the track moved on
+	}
+	if nil != err {
+		// the default art rather than the last track's
+		ls.drawBase(true)
+		logLMS.Warn(`cover art failed, showing default`, `err`, err)
+		return
+	}
+	ls.drawBase(false)
+	draw.Draw(ls.coverart, ls.coverart.Bounds(), im, image.ZP, draw.Src)
+	atomic.AddUint64(&ls.coverver, 1)
 
+}
+
+// loadArt reads a cover from the cover art cache or the server, caching
+// what is fetched; called without the lock
+func (ls *LMSServer) loadArt(due *coverFetch) (image.Image, error) {
+
+	// check if we have the cover cached, the current track has no key
+	if `` != due.key {
+		if im, ok := ls.cacache.GetImage(due.key); ok {
+			return im, nil
+		}
 	}
 
-	resp, err := http.Get(ls.arturl)
+	resp, err := getArt(due.url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -1013,36 +1333,38 @@ func (ls *LMSServer) cacheImage() error {
 	//slow load on init but we cache the thumbnail
 	cl, _ := strconv.ParseInt(resp.Header.Get(`content-length`), 10, 64)
 	if cl == -1 || cl > 1000000 {
-		ls.drawBase(true)
+		ls.mux.Lock()
+		if due.gen == ls.artGen {
+			ls.drawBase(true)
+		}
+		ls.mux.Unlock()
 	}
 
-	im, err = ls.getImage(resp.Body)
+	im, err := ls.getImage(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if im == nil {
 
 		resp.Body.Close()
-		resp, err = http.Get(fmt.Sprintf("http://%v:%v/music/0/cover_500x500_o", ls.host, ls.port))
+		resp, err = getArt(fmt.Sprintf("%smusic/0/cover_%dx%d_o", ls.web, ls.coverSize, ls.coverSize))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer resp.Body.Close()
 
 		im, err = ls.getImage(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 	}
 
-	if im != nil {
-		draw.Draw(ls.coverart, ls.coverart.Bounds(), im, image.ZP, draw.Src)
-		ls.cacache.SetImage(ls.Player.coverid, im)
+	if `` != due.key {
+		ls.cacache.SetImage(due.key, im)
 	}
-
-	return nil
+	return im, nil
 
 }
 
@@ -1132,3 +1454,191 @@ func (ls *LMSServer) VolumePopup(sw, sh int) (img draw.Image) {
 	return img
 
 }
+
+// lmsWidget draws one part of the player zone, the lms frame owns the
+// update lifecycle
+type lmsWidget struct {
+	ls      *LMSServer
+	render  func(dc *gg.Context, rg *Region)
+	visible func() bool
+	owner   bool
+	static  bool // drawn once
+	cover   bool // redrawn on cover change
+	art     uint64
+	drawn   bool
+}
+
+// Widgets adapts the player zone, face for the track details and lw the
+// frame line width
+func (ls *LMSServer) Widgets(face font.Face, lw float64) map[string]Widget {
+
+	playing := func() bool { return `play` == ls.Player.Mode }
+	vu := func() bool { return ls.VUActive() && !mode }
+	text := func() bool { return !vu() }
+	cover := func() bool { return !mode }
+	queued := func() bool { return playing() && atomic.LoadInt32(&ls.queued) > 0 }
+
+	return map[string]Widget{
+		`lms_cover`: &lmsWidget{ls: ls, visible: cover, cover: true, render: func(dc *gg.Context, rg *Region) {
+			rg.DrawImage(dc, imaging.Resize(ls.Coverart(), rg.Rect.Dx(), rg.Rect.Dy(), imaging.Lanczos))
+		}},
+		`lms_backdrop`: &lmsWidget{ls: ls, cover: true, render: func(dc *gg.Context, rg *Region) {
+			dst := imaging.Resize(ls.Coverart(), rg.Rect.Dx(), rg.Rect.Dy(), imaging.Lanczos)
+			rg.DrawImage(dc, imaging.Blur(imaging.AdjustBrightness(dst, -40), 6.5))
+		}},
+		`lms_vu`: &lmsWidget{ls: ls, visible: vu, render: func(dc *gg.Context, rg *Region) {
+			rg.DrawImage(dc, ls.VU())
+		}},
+		`lms_text`: &lmsWidget{ls: ls, visible: text, render: func(dc *gg.Context, rg *Region) {
+			dc.SetHexColor("#ff9900cc")
+			dc.SetFontFace(face)
+			tx, _ := rg.Center()
+			pos := rg.Rect.Min.Y + 9
+			for _, il := range []*InfoLabel{ls.Player.Albumartist, ls.Player.Album, ls.Player.Title, ls.Player.Artist} {
+				dc.DrawImageAnchored(il.Image(), int(tx), pos, 0.5, 0.5)
+				pos += 9
+			}
+			tag := fmt.Sprintf("• %v •", ls.Player.Year)
+			if `` != ls.room {
+				tag = fmt.Sprintf("%v • %v", ls.room, ls.Player.Year)
+			}
+			dc.DrawStringAnchored(tag, tx, float64(rg.Rect.Min.Y+42), 0.5, 0.5)
+			dc.DrawImageAnchored(ls.PlayModifiers(), rg.Rect.Min.X+1, pos, 0, 0.5)
+			vol := ls.Volume()
+			dc.DrawImageAnchored(vol, rg.Rect.Max.X-(vol.Bounds().Max.X+2), pos, 0, 0.5)
+		}},
+		`lms`: &lmsWidget{ls: ls, owner: true, visible: playing, static: true, render: func(dc *gg.Context, rg *Region) {
+			placeBorderZone(dc, lw, rg.Rect, H-rg.Rect.Min.Y+6)
+		}},
+		`lms_footer`: &lmsWidget{ls: ls, render: func(dc *gg.Context, rg *Region) {
+			fx := float64(rg.Rect.Min.X)
+			drawHorizontalBar(dc, fx+10, float64(rg.Rect.Min.Y+1), float64(rg.Rect.Dx()-20), ls.Player.Percent)
+			base := float64(rg.Rect.Max.Y - 5)
+			dc.SetFontFace(face)
+			dc.SetHexColor("#ff9900")
+			dc.DrawStringAnchored(ls.Player.TimeStr, fx+2, base, 0, 0.5)
+			if remaining {
+				dc.DrawStringAnchored(ls.Player.RemStr, float64(rg.Rect.Max.X-2), base, 1, 0.5)
+			} else {
+				dc.DrawStringAnchored(ls.Player.DurStr, float64(rg.Rect.Max.X-2), base, 1, 0.5)
+			}
+			dc.SetHexColor("#0099ffcc")
+			tx, _ := rg.Center()
+			dc.DrawStringAnchored(ls.Player.Bitty, tx, base, 0.5, 0.5)
+		}},
+		`lms_volume`: &lmsWidget{ls: ls, render: func(dc *gg.Context, rg *Region) {
+			rg.DrawImage(dc, ls.VolumePopup(rg.Rect.Dx(), rg.Rect.Dy()))
+		}},
+		`lms_queue`: ls.queueWidget(face, queued),
+	}
+
+}
+
+// Start updates, frame only
+func (lwg *lmsWidget) Start() {
+	if lwg.owner {
+		lwg.ls.Start()
+	}
+}
+
+// Stop updates, frame only
+func (lwg *lmsWidget) Stop() {
+	if lwg.owner {
+		lwg.ls.Stop()
+	}
+}
+
+// PreferredSize fills the region
+func (lwg *lmsWidget) PreferredSize() image.Point {
+	return image.ZP
+}
+
+// Dirty always for the moving parts, time and meters
+func (lwg *lmsWidget) Dirty() bool {
+	switch {
+	case lwg.cover:
+		return !lwg.drawn || lwg.ls.CoverVersion() != lwg.art
+	case lwg.static:
+		return !lwg.drawn
+	}
+	return true
+}
+
+// Visible per part
+func (lwg *lmsWidget) Visible() bool {
+	return nil == lwg.visible || lwg.visible()
+}
+
+// Render the part
+func (lwg *lmsWidget) Render(dc *gg.Context, rg *Region) {
+	lwg.art = lwg.ls.CoverVersion()
+	lwg.drawn = true
+	lwg.render(dc, rg)
+}
+
+// LMSStatus the player state for status reporting
+type LMSStatus struct {
+	Player    string          `json:"player"`
+	Mode      string          `json:"mode"`
+	Artist    string          `json:"artist"`
+	Album     string          `json:"album"`
+	Title     string          `json:"title"`
+	Year      string          `json:"year"`
+	Genre     string          `json:"genre"`
+	Time      string          `json:"time"`
+	Duration  string          `json:"duration"`
+	Remaining string          `json:"remaining"`
+	Percent   float64         `json:"percent"`
+	Volume    int             `json:"volume"`
+	Bitrate   string          `json:"bitrate"`
+	Format    string          `json:"format"`
+	Shuffle   int             `json:"shuffle"`
+	Repeat    int             `json:"repeat"`
+	Remote    bool            `json:"remote"`
+	Transport string          `json:"transport"` // cli while subscribed, else poll
+	Name      string          `json:"name,omitempty"`
+	Room      string          `json:"room,omitempty"`
+	Players   []LMSPlayerInfo `json:"players,omitempty"`
+	Queue     []QueueTrack    `json:"queue,omitempty"` // up next
+	QueueLeft string          `json:"queue_left,omitempty"`
+}
+
+// Status returns the current player state
+func (ls *LMSServer) Status() LMSStatus {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	p := ls.Player
+	transport := `poll`
+	if nil != ls.cli && ls.cli.Connected() {
+		transport = `cli`
+	}
+	queueLeft := ``
+	if len(ls.queue) > 0 {
+		queueLeft = p.displayTime(ls.queueLeft())
+	}
+	return LMSStatus{
+		Player:    p.MAC,
+		Mode:      p.Mode,
+		Artist:    p.Artist.GetText(),
+		Album:     p.Album.GetText(),
+		Title:     p.Title.GetText(),
+		Year:      p.Year,
+		Genre:     p.Genre,
+		Time:      p.TimeStr,
+		Duration:  p.DurStr,
+		Remaining: p.RemStr,
+		Percent:   p.Percent,
+		Volume:    p.Volume,
+		Bitrate:   p.Bitrate,
+		Format:    p.Bitty,
+		Shuffle:   p.shuffle,
+		Repeat:    p.repeat,
+		Remote:    p.remote,
+		Transport: transport,
+		Name:      p.Playername,
+		Room:      ls.room,
+		Players:   ls.players,
+		Queue:     ls.queue,
+		QueueLeft: queueLeft,
+	}
+}
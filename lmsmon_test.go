@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+// TestUpdatePlayerPanic a status that panics part way through is
+// recovered and the player lock let go
+func TestUpdatePlayerPanic(t *testing.T) {
+
+	// no volume glyph past 100, the volume draw panics
+	fx, done := testFixtures(t, map[string]string{`lms.json`: `{"mode": "play", "mixer volume": 150}`})
+	defer done()
+	f, hr := fixtures, health
+	defer func() { fixtures, health = f, hr }()
+	fixtures = fx
+	health = &healthRegistry{sources: make(map[string]*SourceHealth), started: timeNow()}
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+	ls.updatePlayer()
+
+	status := make(chan LMSStatus)
+	go func() { status <- ls.Status() }()
+	select {
+	case <-status:
+	case <-time.After(time.Second):
+		t.Fatal("player lock still held after the panic")
+	}
+	if st := health.Status()[`lms`]; 1 != st.Failures {
+		t.Errorf("%d failures, want the panic counted", st.Failures)
+	}
+
+}
+
+// TestLMSLastGood a recovered panic counts as a failed status, the last
+// good track stays on show and goes stale past the limit
+func TestLMSLastGood(t *testing.T) {
+
+	fgood, done := testFixtures(t, map[string]string{`lms.json`: `{"mode": "play", "mixer volume": 40,
+		"playlist_loop": [{"id": 1, "title": "First", "artist": "Band", "playlist index": "0"}]}`})
+	defer done()
+	fbad, done := testFixtures(t, map[string]string{`lms.json`: `{"mode": "play", "mixer volume": 150}`})
+	defer done()
+
+	at := time.Date(2020, time.October, 16, 10, 23, 0, 0, time.Local)
+	clock := &stoppedTime{t: at}
+	ts, f, hr := timeSource, fixtures, health
+	defer func() { timeSource, fixtures, health = ts, f, hr }()
+	timeSource, fixtures = clock, fgood
+	health = &healthRegistry{sources: make(map[string]*SourceHealth), started: at}
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+
+	ls.updatePlayer()
+	if st := ls.Status(); `First` != st.Title || `Band` != st.Artist {
+		t.Fatalf("good status showing %q by %q", st.Title, st.Artist)
+	}
+
+	fixtures = fbad
+	for _, step := range []struct {
+		at       time.Duration
+		failures int
+		stale    bool
+	}{
+		{time.Second, 1, false},
+		{staleLimit(`lms`) + time.Second, 2, true},
+	} {
+		clock.t = at.Add(step.at)
+		ls.updatePlayer()
+		if st := ls.Status(); `First` != st.Title || `Band` != st.Artist || `play` != st.Mode {
+			t.Errorf("at %v showing %q by %q %s, want the last good track", step.at, st.Title, st.Artist, st.Mode)
+		}
+		st := health.Status()[`lms`]
+		if step.failures != st.Failures || step.stale != st.Stale {
+			t.Errorf("at %v: %d failures stale %v, want %d stale %v", step.at, st.Failures, st.Stale, step.failures, step.stale)
+		}
+		if !strings.HasPrefix(st.LastError, `lms status`) || !st.LastOK.Equal(at) {
+			t.Errorf("at %v: last error %q, last ok %v", step.at, st.LastError, st.LastOK)
+		}
+	}
+
+}
+
+// TestLMSEndpoint the event stream path gets its leading slash, an empty
+// one too rather than taking the clock down
+func TestLMSEndpoint(t *testing.T) {
+
+	for _, tc := range []struct {
+		endpoint string
+		want     string
+	}{
+		{`/visionon?subscribe=VU-SA`, `/visionon?subscribe=VU-SA`},
+		{`visionon`, `/visionon`},
+		{``, `/`},
+	} {
+		ls := NewLMSServer(LMSConfig{Player: cliPlayer, SSESEndpoint: tc.endpoint})
+		if tc.want != ls.sses.endpoint {
+			t.Errorf("%q: endpoint %q, want %q", tc.endpoint, ls.sses.endpoint, tc.want)
+		}
+		ls.Close()
+	}
+
+}
+
+// TestStatusUnlocked a slow status request holds the lock neither for
+// the status report nor for the queue scene's visibility
+func TestStatusUnlocked(t *testing.T) {
+
+	requested, release := make(chan bool, 1), make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requested <- true
+		<-release
+		fmt.Fprint(rw, `{"id":0,"method":"slim.request","result":{"mode":"stop"}}`)
+	}))
+	defer srv.Close()
+	su, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(su.Port())
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	ls := NewLMSServer(LMSConfig{Host: su.Hostname(), Port: port, Player: cliPlayer, BaseFolder: cache, SSESEndpoint: `/`})
+	defer ls.Close()
+	queue := ls.Widgets(basicfont.Face7x13, 1)[`lms_queue`]
+
+	done := make(chan bool)
+	go func() {
+		ls.updatePlayer()
+		close(done)
+	}()
+	<-requested
+
+	answered := make(chan bool)
+	go func() {
+		ls.Status()
+		queue.Visible()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Error("player locked while the status is requested")
+	}
+
+	close(release)
+	<-done
+	if st := ls.Status(); `stop` != st.Mode {
+		t.Errorf("mode %q, want the status applied once answered", st.Mode)
+	}
+
+}
+
+// TestConsumeEventsCancel the meter consumer ends with its context, not
+// only on an inactive event nobody may be left to send
+func TestConsumeEventsCancel(t *testing.T) {
+
+	ls := &LMSServer{}
+	ls.sses.active, ls.sses.events = true, make(chan *SSEvent)
+	ls.vulayout.baseImage = image.NewRGBA(image.Rect(0, 0, 64, 32))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		ls.consumeEvents(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("consumer still waiting on events after cancel")
+	}
+
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// LMSPlayerInfo one player known to the server
+type LMSPlayerInfo struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Connected bool     `json:"connected"`
+	Playing   bool     `json:"playing"`
+	Sync      []string `json:"sync,omitempty"` // the others in its sync group
+}
+
+// playersResult the players and syncgroups query results
+type playersResult struct {
+	Players []struct {
+		ID        string `json:"playerid"`
+		Name      string `json:"name"`
+		Connected int    `json:"connected"`
+		Playing   int    `json:"isplaying"`
+	} `json:"players_loop"`
+	Groups []struct {
+		Members string `json:"sync_members"` // comma separated player ids
+	} `json:"syncgroups_loop"`
+}
+
+// queryPlayers enumerates the players and their sync groups
+func (ls *LMSServer) queryPlayers() ([]LMSPlayerInfo, error) {
+
+	var pr playersResult
+	for _, q := range [][]interface{}{{`players`, 0, 99}, {`syncgroups`, `?`}} {
+		v, err := ls.request(``, q)
+		if nil != err {
+			return nil, sourceError(`lms`, `players`, err)
+		}
+		b, err := json.Marshal(v)
+		if nil == err {
+			err = json.Unmarshal(b, &pr)
+		}
+		if nil != err {
+			return nil, sourceError(`lms`, `players`, err)
+		}
+	}
+
+	group := make(map[string][]string)
+	for _, g := range pr.Groups {
+		members := strings.Split(g.Members, `,`)
+		for _, m := range members {
+			for _, o := range members {
+				if o != m {
+					group[m] = append(group[m], o)
+				}
+			}
+		}
+	}
+	players := make([]LMSPlayerInfo, 0, len(pr.Players))
+	for _, p := range pr.Players {
+		players = append(players, LMSPlayerInfo{
+			ID:        p.ID,
+			Name:      p.Name,
+			Connected: 1 == p.Connected,
+			Playing:   1 == p.Playing,
+			Sync:      group[p.ID],
+		})
+	}
+	return players, nil
+
+}
+
+// choosePlayer the id of the player to show: of those playing the first
+// in priority, by id or name, a sync group ranking as its best member and
+// staying on current when it is in the group; with none playing current
+// while it is connected, else pinned
+func choosePlayer(players []LMSPlayerInfo, current, pinned string, priority []string) string {
+
+	byID := make(map[string]LMSPlayerInfo, len(players))
+	for _, p := range players {
+		byID[strings.ToLower(p.ID)] = p
+	}
+	rank := func(p LMSPlayerInfo) int {
+		for i, pr := range priority {
+			if strings.EqualFold(pr, p.ID) || strings.EqualFold(pr, p.Name) {
+				return i
+			}
+		}
+		return len(priority)
+	}
+	groupRank := func(p LMSPlayerInfo) int {
+		r := rank(p)
+		for _, id := range p.Sync {
+			if m, ok := byID[strings.ToLower(id)]; ok && rank(m) < r {
+				r = rank(m)
+			}
+		}
+		return r
+	}
+	holds := func(p LMSPlayerInfo, id string) bool {
+		if strings.EqualFold(p.ID, id) {
+			return true
+		}
+		for _, s := range p.Sync {
+			if strings.EqualFold(s, id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	best, bestRank := -1, 0
+	for i, p := range players {
+		if !p.Playing || !p.Connected {
+			continue
+		}
+		r := groupRank(p)
+		if best < 0 || r < bestRank || (r == bestRank && holds(p, current) && !holds(players[best], current)) {
+			best, bestRank = i, r
+		}
+	}
+	if best >= 0 {
+		p := players[best]
+		if holds(p, current) {
+			return current
+		}
+		// the group's member first in priority
+		for _, id := range p.Sync {
+			if m, ok := byID[strings.ToLower(id)]; ok && rank(m) < rank(p) {
+				p = m
+			}
+		}
+		return p.ID
+	}
+
+	if p, ok := byID[strings.ToLower(current)]; ok && p.Connected {
+		return current
+	}
+	if p, ok := byID[strings.ToLower(pinned)]; ok && p.Connected {
+		return pinned
+	}
+	return current
+
+}
+
+// updatePlayers enumerates the players and follows the one to show
+func (ls *LMSServer) updatePlayers() {
+
+	players, err := ls.queryPlayers()
+	if nil != err {
+		logLMS.Debug(`players failed`, `err`, err)
+		return
+	}
+
+	ls.mux.Lock()
+	ls.players = players
+	id := choosePlayer(players, ls.Player.MAC, ls.pinned, ls.priority)
+	switched := !strings.EqualFold(id, ls.Player.MAC)
+	if switched {
+		logLMS.Info(`following player`, `player`, id, `from`, ls.Player.MAC)
+		ls.switchPlayer(id)
+	}
+	ls.setRoom()
+	ls.mux.Unlock()
+
+	if switched {
+		ls.updatePlayer()
+	}
+
+}
+
+// follows true for the player shown or one synced with it
+func (ls *LMSServer) follows(player string) bool {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if strings.EqualFold(player, ls.Player.MAC) {
+		return true
+	}
+	for _, p := range ls.players {
+		if strings.EqualFold(p.ID, ls.Player.MAC) {
+			for _, s := range p.Sync {
+				if strings.EqualFold(s, player) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// switchPlayer shows player id from the next status, caller holds the lock
+func (ls *LMSServer) switchPlayer(id string) {
+	ls.Player.MAC = id
+	ls.Player.coverid = `` // fetch the new player's art
+	ls.Player.arturl = ls.artURL(coverArt{}, id, ls.coverSize)
+	ls.Player.lastVol = -1
+	ls.queue, ls.queueStamp = nil, 0 // and its playlist
+	atomic.StoreInt32(&ls.queued, 0)
+}
+
+// setRoom names the player shown, with how many others are synced to it,
+// when more than one is connected; caller holds the lock
+func (ls *LMSServer) setRoom() {
+	ls.Player.Playername, ls.room = ``, ``
+	connected := 0
+	for _, p := range ls.players {
+		if p.Connected {
+			connected++
+		}
+	}
+	for _, p := range ls.players {
+		if !strings.EqualFold(p.ID, ls.Player.MAC) {
+			continue
+		}
+		ls.Player.Playername = p.Name
+		if ls.showRoom && connected > 1 {
+			ls.room = p.Name
+			if n := len(p.Sync); n > 0 {
+				ls.room += fmt.Sprintf(" +%d", n)
+			}
+		}
+	}
+}
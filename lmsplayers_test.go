@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestChoosePlayer(t *testing.T) {
+
+	const (
+		den     = `00:04:20:00:00:01`
+		kitchen = `00:04:20:00:00:02`
+		office  = `00:04:20:00:00:03`
+	)
+	player := func(id, name string, playing bool, sync ...string) LMSPlayerInfo {
+		return LMSPlayerInfo{ID: id, Name: name, Connected: true, Playing: playing, Sync: sync}
+	}
+	priority := []string{`Kitchen`, office}
+
+	for _, tc := range []struct {
+		name    string
+		players []LMSPlayerInfo
+		current string
+		want    string
+	}{
+		{`one playing`, []LMSPlayerInfo{
+			player(den, `Den`, true), player(kitchen, `Kitchen`, false),
+		}, kitchen, den},
+		{`first in priority`, []LMSPlayerInfo{
+			player(den, `Den`, true), player(kitchen, `Kitchen`, true), player(office, `Office`, true),
+		}, den, kitchen},
+		{`unlisted stays on current`, []LMSPlayerInfo{
+			player(den, `Den`, true), player(`00:04:20:00:00:04`, `Attic`, true),
+		}, `00:04:20:00:00:04`, `00:04:20:00:00:04`},
+		{`group ranks as its best member`, []LMSPlayerInfo{
+			player(den, `Den`, true, kitchen), player(kitchen, `Kitchen`, true, den), player(office, `Office`, true),
+		}, office, kitchen},
+		{`group keeps current member`, []LMSPlayerInfo{
+			player(den, `Den`, true, kitchen), player(kitchen, `Kitchen`, true, den), player(office, `Office`, true),
+		}, den, den},
+		{`none playing keeps current`, []LMSPlayerInfo{
+			player(den, `Den`, false), player(kitchen, `Kitchen`, false),
+		}, den, den},
+		{`none playing, current gone, pinned`, []LMSPlayerInfo{
+			player(kitchen, `Kitchen`, false), player(office, `Office`, false),
+		}, den, office},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := choosePlayer(tc.players, tc.current, office, priority); got != tc.want {
+				t.Errorf("chose %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+}
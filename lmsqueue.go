@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/spf13/cast"
+	"golang.org/x/image/font"
+)
+
+// QueueTrack one of the tracks up next
+type QueueTrack struct {
+	Title    string  `json:"title"`
+	Artist   string  `json:"artist"`
+	Duration string  `json:"duration"`
+	Seconds  float64 `json:"-"`
+	art      coverArt
+	thumb    string // the thumbnail's key
+}
+
+// queueScroll time per pixel of the scrolling queue
+const queueScroll = 80 * time.Millisecond
+
+// queueThumb the size the thumbnails are fetched at
+const queueThumb = 64
+
+// setQueue takes the tracks after the current one from a status result,
+// caller holds the lock
+func (ls *LMSServer) setQueue(s *LMSDetail) {
+
+	q := make([]QueueTrack, 0, ls.queueLen)
+	for i := 1; i < len(s.PlaylistLoop); i++ {
+		t := s.PlaylistLoop[i]
+		artist := t.Artist
+		if `` == artist {
+			artist = t.Trackartist
+		}
+		d := cast.ToFloat64(t.Duration)
+		art := coverArt{coverid: t.Coverid, trackID: t.ArtworkTrackID, url: t.ArtworkURL, remote: `` != t.Remote && `0` != t.Remote}
+		q = append(q, QueueTrack{
+			Title:    t.Title,
+			Artist:   artist,
+			Duration: ls.Player.displayTime(d),
+			Seconds:  d,
+			art:      art,
+			thumb:    art.key(queueThumb),
+		})
+	}
+	ls.queue = q
+	atomic.StoreInt32(&ls.queued, int32(len(q)))
+	ls.queueIndex = 0
+	ls.queueTracks = s.PlaylistTracks
+	if len(s.PlaylistLoop) > 0 {
+		ls.queueIndex = cast.ToInt(s.PlaylistLoop[0].PlaylistIndex)
+	}
+	if ls.queueStamp != s.PlaylistTimestamp {
+		ls.queueStale = true
+		ls.queueStamp = s.PlaylistTimestamp
+	}
+
+	shown := make(map[string]bool, len(q))
+	for _, t := range q {
+		shown[t.thumb] = true
+	}
+	for id := range ls.thumbs {
+		if !shown[id] {
+			delete(ls.thumbs, id) // played or skipped
+		}
+	}
+	var missing []coverArt
+	for _, t := range q {
+		if _, ok := ls.thumbs[t.thumb]; !ok && `` != t.thumb {
+			missing = append(missing, t.art)
+			ls.thumbs[t.thumb] = nil // one fetch each
+		}
+	}
+	if len(missing) > 0 {
+		supervisor.GoContext(ls.ctx, `lms-thumbs`, func(ctx context.Context) error {
+			ls.fetchThumbs(ctx, missing)
+			return nil
+		})
+	}
+
+}
+
+// refreshQueue reads the durations of the whole playlist when it has
+// changed, for the time left; the request is made without the lock and
+// dropped if the playlist moved on meanwhile
+func (ls *LMSServer) refreshQueue() error {
+
+	ls.mux.Lock()
+	mac, tracks, stamp := ls.Player.MAC, ls.queueTracks, ls.queueStamp
+	due := 0 != ls.queueLen && ls.queueStale && nil == fixtures
+	ls.mux.Unlock()
+	if !due {
+		return nil
+	}
+	vs, err := ls.request(mac, []string{`status`, `0`, strconv.Itoa(tracks), `tags:d`})
+	if nil != err {
+		return sourceError(`lms`, `queue`, err)
+	}
+	var s struct {
+		Loop []struct {
+			Duration interface{} `json:"duration"`
+			Index    interface{} `json:"playlist index"`
+		} `json:"playlist_loop"`
+	}
+	b, err := json.Marshal(vs)
+	if nil == err {
+		err = json.Unmarshal(b, &s)
+	}
+	if nil != err {
+		return sourceError(`lms`, `queue`, err)
+	}
+	durations := make([]float64, tracks)
+	for _, t := range s.Loop {
+		if i := cast.ToInt(t.Index); i >= 0 && i < len(durations) {
+			durations[i] = cast.ToFloat64(t.Duration)
+		}
+	}
+
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if mac == ls.Player.MAC && tracks == ls.queueTracks && stamp == ls.queueStamp {
+		ls.queueTimes = durations
+		ls.queueStale = false
+	}
+	return nil
+
+}
+
+// queueLeft seconds to the end of the playlist, the current track's
+// remaining time and every track after it; caller holds the lock
+func (ls *LMSServer) queueLeft() float64 {
+	left := ls.Player.remaining
+	if len(ls.queueTimes) == ls.queueTracks && !ls.queueStale {
+		for i := ls.queueIndex + 1; i < len(ls.queueTimes); i++ {
+			left += ls.queueTimes[i]
+		}
+		return left
+	}
+	for _, t := range ls.queue {
+		left += t.Seconds // only what is fetched
+	}
+	return left
+}
+
+// fetchThumbs reads the cover thumbnails for the queue, from the cover art
+// cache or the server at thumbnail size, until ctx is done
+func (ls *LMSServer) fetchThumbs(ctx context.Context, arts []coverArt) {
+	for _, ca := range arts {
+		if nil != ctx.Err() {
+			return
+		}
+		im, ok := ls.cacache.GetImage(ca.key(ls.coverSize))
+		if !ok && nil == fixtures {
+			u := ls.artURL(ca, ``, queueThumb)
+			resp, err := getArt(u)
+			if nil == err {
+				im, err = imaging.Decode(resp.Body)
+				resp.Body.Close()
+			}
+			if nil != err {
+				logLMS.Debug(`queue thumbnail failed`, `url`, u, `err`, err)
+			}
+		}
+		if nil == im {
+			continue // the default art
+		}
+		thumb := imaging.Fit(im, queueThumb, queueThumb, imaging.Lanczos)
+		ls.mux.Lock()
+		if nil == ctx.Err() {
+			ls.thumbs[ca.key(queueThumb)] = thumb // not after Close
+		}
+		ls.mux.Unlock()
+	}
+}
+
+// Queue the tracks up next and the time to the end of the playlist
+func (ls *LMSServer) Queue() ([]QueueTrack, float64) {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	return append([]QueueTrack(nil), ls.queue...), ls.queueLeft()
+}
+
+// pruneThumbs drops the sized thumbnails of tracks no longer queued, the
+// default art stays
+func pruneThumbs(sized map[string]image.Image, q []QueueTrack) {
+	queued := map[string]bool{``: true}
+	for _, t := range q {
+		queued[t.thumb] = true
+	}
+	for key := range sized {
+		if !queued[key] {
+			delete(sized, key) // played or skipped
+		}
+	}
+}
+
+// queueWidget the up next list: a header with the playlist time left and
+// a row per track, thumbnail, title and artist with duration, scrolling
+// when there are more than fit
+func (ls *LMSServer) queueWidget(face font.Face, visible func() bool) Widget {
+
+	sized := make(map[string]image.Image) // thumbnails at the row size
+	size := 0
+	var shown time.Time
+
+	return &lmsWidget{ls: ls, visible: visible, render: func(dc *gg.Context, rg *Region) {
+
+		q, left := ls.Queue()
+		lh := float64(face.Metrics().Height.Ceil())
+		x, y := float64(rg.Rect.Min.X), float64(rg.Rect.Min.Y)
+		w := float64(rg.Rect.Dx())
+
+		dc.SetFontFace(face)
+		dc.SetHexColor("#0099ffcc")
+		dc.DrawStringAnchored(`UP NEXT`, x+2, y+lh/2, 0, 0.5)
+		dc.SetHexColor("#ff9900")
+		dc.DrawStringAnchored(`-`+ls.Player.displayTime(left), x+w-2, y+lh/2, 1, 0.5)
+
+		row := int(2 * lh)
+		if row != size {
+			sized, size = make(map[string]image.Image), row
+		}
+		pruneThumbs(sized, q)
+		top := y + lh + 1
+		height := float64(rg.Rect.Max.Y) - top
+		span := float64(len(q) * row)
+
+		offset := 0.0
+		if span > height {
+			now := frameTime()
+			if shown.IsZero() || now.Sub(shown) > time.Minute {
+				shown = now
+			}
+			offset = float64(int(now.Sub(shown)/queueScroll) % int(span+lh))
+		} else {
+			shown = time.Time{}
+		}
+
+		dc.Push()
+		dc.DrawRectangle(x, top, w, height)
+		dc.Clip()
+		for pass := 0.0; pass < 2; pass++ {
+			for i, t := range q {
+				ry := top + float64(i*row) - offset + pass*(span+lh)
+				if ry+float64(row) < top || ry > top+height {
+					continue
+				}
+				thumb, ok := sized[t.thumb]
+				if !ok {
+					ls.mux.Lock()
+					src := ls.thumbs[t.thumb]
+					ls.mux.Unlock()
+					key := t.thumb
+					if nil == src {
+						src, key = ls.defaultart, `` // until it is fetched
+					}
+					if thumb, ok = sized[key]; !ok {
+						thumb = imaging.Fit(src, row-2, row-2, imaging.Lanczos)
+						sized[key] = thumb
+					}
+				}
+				dc.DrawImage(thumb, int(x)+1, int(ry)+1)
+				tx := x + float64(row) + 2
+				dc.SetHexColor("#ff9900")
+				dc.DrawStringAnchored(t.Title, tx, ry+lh/2, 0, 0.5)
+				dc.SetHexColor("#ffffffaa")
+				dc.DrawStringAnchored(t.Artist, tx, ry+lh*1.5, 0, 0.5)
+				dc.SetHexColor("#0099ffcc")
+				dc.DrawStringAnchored(t.Duration, x+w-2, ry+lh*1.5, 1, 0.5)
+			}
+			if 0 == offset {
+				break
+			}
+		}
+		dc.ResetClip()
+		dc.Pop()
+
+	}}
+
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQueueLeft(t *testing.T) {
+
+	ls := &LMSServer{Player: NewLMSPlayer(``), queueLen: 2, thumbs: make(map[string]image.Image)}
+	ls.Player.setDuration(200)
+	ls.Player.setTime(50)
+
+	var s LMSDetail
+	if err := json.Unmarshal([]byte(`{"playlist_timestamp": 12.5, "playlist_tracks": 5, "playlist_loop": [
+		{"title": "Now", "playlist index": "1", "duration": 200},
+		{"title": "Next", "artist": "", "trackartist": "Band", "playlist index": "2", "duration": 61},
+		{"title": "Then", "artist": "Singer", "playlist index": "3", "duration": 3600}
+	]}`), &s); nil != err {
+		t.Fatal(err)
+	}
+	ls.setQueue(&s)
+
+	if 2 != len(ls.queue) || `Next` != ls.queue[0].Title || `Band` != ls.queue[0].Artist || `01:01` != ls.queue[0].Duration {
+		t.Fatalf("queue %+v", ls.queue)
+	}
+	if `01:00:00` != ls.queue[1].Duration {
+		t.Errorf("duration %q, want 01:00:00", ls.queue[1].Duration)
+	}
+	if !ls.queueStale || 1 != ls.queueIndex {
+		t.Errorf("stale %v index %d, want a new playlist at 1", ls.queueStale, ls.queueIndex)
+	}
+
+	// the fetched tracks until the playlist durations are read
+	if left := ls.queueLeft(); 150+61+3600 != left {
+		t.Errorf("left %v before durations", left)
+	}
+	ls.queueTimes, ls.queueStale = []float64{100, 200, 61, 3600, 30}, false
+	if left := ls.queueLeft(); 150+61+3600+30 != left {
+		t.Errorf("left %v with durations", left)
+	}
+
+	// same playlist, the durations stand
+	ls.setQueue(&s)
+	if ls.queueStale {
+		t.Error("stale with the playlist unchanged")
+	}
+
+}
+
+func TestQueueThumbs(t *testing.T) {
+
+	// the server's default art comes back with a 404, not a thumbnail
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, `/music/good/`) {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+		png.Encode(rw, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	}))
+	defer ts.Close()
+
+	cache, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+
+	ls := &LMSServer{web: ts.URL + `/`, coverSize: 128, cacache: InitImageCache(cache, false), thumbs: make(map[string]image.Image)}
+	good, gone := coverArt{coverid: `good`}, coverArt{coverid: `gone`}
+	ls.fetchThumbs(context.Background(), []coverArt{good, gone})
+
+	if _, ok := ls.thumbs[good.key(queueThumb)]; !ok {
+		t.Error("no thumbnail for the art served")
+	}
+	if _, ok := ls.thumbs[gone.key(queueThumb)]; ok {
+		t.Error("thumbnail from a 404")
+	}
+
+	// closed meanwhile, nothing is stored
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ls.thumbs = make(map[string]image.Image)
+	ls.fetchThumbs(ctx, []coverArt{good})
+	if 0 != len(ls.thumbs) {
+		t.Errorf("thumbnails %v stored after close", ls.thumbs)
+	}
+
+}
+
+func TestPruneThumbs(t *testing.T) {
+
+	im := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	sized := map[string]image.Image{``: im, `a_64`: im, `b_64`: im, `c_64`: im}
+	pruneThumbs(sized, []QueueTrack{{thumb: `b_64`}, {thumb: `d_64`}})
+
+	if 2 != len(sized) || nil == sized[``] || nil == sized[`b_64`] {
+		t.Errorf("kept %v, want the default and b_64", sized)
+	}
+
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// LogLevel orders the log severities
+	LogLevel int
+
+	// Logger writes leveled, structured entries for one component
+	Logger struct {
+		component string
+	}
+
+	// LogConfig setup, config log
+	LogConfig struct {
+		Level      string
+		Format     string            // text or json
+		File       string            // empty for stdout
+		MaxSize    int64             // megabytes before rotation, 0 never
+		Backups    int               // rotated files kept
+		Components map[string]string // per component level
+	}
+
+	// logSink the shared output, levels and format
+	logSink struct {
+		out        io.Writer
+		level      LogLevel
+		components map[string]LogLevel
+		json       bool
+		mux        sync.Mutex
+	}
+
+	// rotatingFile rolls path to path.1 .. path.n once past max bytes
+	rotatingFile struct {
+		path    string
+		max     int64
+		backups int
+		size    int64
+		f       *os.File
+	}
+)
+
+// log levels, lowest first
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{`debug`, `info`, `warn`, `error`}
+
+var sink = &logSink{out: os.Stdout, level: LevelInfo}
+
+// component loggers
+var (
+	logMain    = NewLogger(`main`)
+	logLMS     = NewLogger(`lms`)
+	logSSES    = NewLogger(`sses`)
+	logMBTA    = NewLogger(`mbta`)
+	logWeather = NewLogger(`weather`)
+	logNews    = NewLogger(`news`)
+	logCache   = NewLogger(`cache`)
+	logCPU     = NewLogger(`cpu`)
+	logIcons   = NewLogger(`icons`)
+	logDisplay = NewLogger(`display`)
+	logAPI     = NewLogger(`api`)
+	logMQTT    = NewLogger(`mqtt`)
+)
+
+// NewLogger creates a logger for the component
+func NewLogger(component string) *Logger {
+	return &Logger{component: component}
+}
+
+// ParseLogLevel reads debug, info, warn or error
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, strings.TrimSpace(s)) {
+			return LogLevel(i), nil
+		}
+	}
+	if strings.EqualFold(`warning`, s) {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l LogLevel) String() string {
+	if l >= 0 && int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ConfigureLogging applies the config, an error leaves the current setup
+func ConfigureLogging(lc LogConfig) error {
+
+	level := LevelInfo
+	var err error
+	if `` != lc.Level {
+		if level, err = ParseLogLevel(lc.Level); nil != err {
+			return err
+		}
+	}
+	components := make(map[string]LogLevel)
+	for c, l := range lc.Components {
+		if components[c], err = ParseLogLevel(l); nil != err {
+			return fmt.Errorf("%s: %v", c, err)
+		}
+	}
+	asJSON := false
+	switch strings.ToLower(lc.Format) {
+	case ``, `text`:
+	case `json`:
+		asJSON = true
+	default:
+		return fmt.Errorf("unknown log format %q", lc.Format)
+	}
+	var out io.Writer = os.Stdout
+	if `` != lc.File {
+		if out, err = openRotatingFile(lc.File, lc.MaxSize*1024*1024, lc.Backups); nil != err {
+			return err
+		}
+	}
+
+	sink.mux.Lock()
+	if rf, ok := sink.out.(*rotatingFile); ok {
+		rf.Close()
+	}
+	sink.out, sink.level, sink.components, sink.json = out, level, components, asJSON
+	sink.mux.Unlock()
+	return nil
+
+}
+
+// Enabled reports entries at level are written
+func (l *Logger) Enabled(level LogLevel) bool {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	return sink.enabled(l.component, level)
+}
+
+// Debug logs msg with key value pairs
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+
+// Info logs msg with key value pairs
+func (l *Logger) Info(msg string, kv ...interface{}) { l.log(LevelInfo, msg, kv) }
+
+// Warn logs msg with key value pairs
+func (l *Logger) Warn(msg string, kv ...interface{}) { l.log(LevelWarn, msg, kv) }
+
+// Error logs msg with key value pairs
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l *Logger) log(level LogLevel, msg string, kv []interface{}) {
+
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	if !sink.enabled(l.component, level) {
+		return
+	}
+
+	now := time.Now()
+	if sink.json {
+		e := map[string]interface{}{
+			`time`:      now.Format(time.RFC3339Nano),
+			`level`:     level.String(),
+			`component`: l.component,
+			`msg`:       msg,
+		}
+		for i := 0; i < len(kv); i += 2 {
+			k, v := pair(kv, i)
+			switch vv := v.(type) {
+			case error:
+				v = vv.Error()
+			case fmt.Stringer:
+				v = vv.String()
+			}
+			e[k] = v
+		}
+		b, err := json.Marshal(e)
+		if nil != err {
+			b, _ = json.Marshal(map[string]string{`msg`: msg, `error`: err.Error()})
+		}
+		sink.out.Write(append(b, '\n'))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %-5s %-7s %s", now.Format(`2006-01-02T15:04:05.000`), strings.ToUpper(level.String()), l.component, msg)
+	for i := 0; i < len(kv); i += 2 {
+		k, v := pair(kv, i)
+		s := fmt.Sprint(v)
+		if strings.ContainsAny(s, " \t\n\"=") || `` == s {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(&sb, " %s=%s", k, s)
+	}
+	sb.WriteByte('\n')
+	io.WriteString(sink.out, sb.String())
+
+}
+
+// pair returns key and value at i, a trailing odd value is keyed !extra
+func pair(kv []interface{}, i int) (string, interface{}) {
+	if i+1 >= len(kv) {
+		return `!extra`, kv[i]
+	}
+	return fmt.Sprint(kv[i]), kv[i+1]
+}
+
+func (ls *logSink) enabled(component string, level LogLevel) bool {
+	if cl, ok := ls.components[component]; ok {
+		return level >= cl
+	}
+	return level >= ls.level
+}
+
+func openRotatingFile(path string, max int64, backups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, max: max, backups: backups}
+	if err := rf.open(); nil != err {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return err
+	}
+	fi, err := f.Stat()
+	if nil != err {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, fi.Size()
+	return nil
+}
+
+// Write appends, rotating first when the entry would pass the limit
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.max > 0 && rf.size+int64(len(p)) > rf.max && rf.size > 0 {
+		if err := rf.rotate(); nil != err {
+			fmt.Fprintln(os.Stderr, `log rotation failed`, err)
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	rf.f.Close()
+	if rf.backups < 1 {
+		os.Remove(rf.path)
+	} else {
+		for i := rf.backups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		os.Rename(rf.path, rf.path+`.1`)
+	}
+	return rf.open()
+}
+
+// Close the file
+func (rf *rotatingFile) Close() error {
+	return rf.f.Close()
+}
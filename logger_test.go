@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// keepSink restores the shared sink when the test is done
+func keepSink() func() {
+	sink.mux.Lock()
+	out, level, components, asJSON := sink.out, sink.level, sink.components, sink.json
+	sink.mux.Unlock()
+	return func() {
+		sink.mux.Lock()
+		sink.out, sink.level, sink.components, sink.json = out, level, components, asJSON
+		sink.mux.Unlock()
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+
+	defer keepSink()()
+
+	for _, tc := range []struct {
+		name       string
+		level      string
+		components map[string]string
+		component  string
+		enabled    []LogLevel
+		disabled   []LogLevel
+	}{
+		{`default info`, ``, nil, `lms`, []LogLevel{LevelInfo, LevelError}, []LogLevel{LevelDebug}},
+		{`global warn`, `warn`, nil, `lms`, []LogLevel{LevelWarn, LevelError}, []LogLevel{LevelDebug, LevelInfo}},
+		{`warning spelt out`, `warning`, nil, `mqtt`, []LogLevel{LevelWarn}, []LogLevel{LevelInfo}},
+		{`component debug`, `error`, map[string]string{`lms`: `debug`}, `lms`, []LogLevel{LevelDebug, LevelInfo}, nil},
+		{`other components keep the global`, `error`, map[string]string{`lms`: `debug`}, `weather`, []LogLevel{LevelError}, []LogLevel{LevelWarn}},
+		{`component quieter`, `debug`, map[string]string{`sses`: `error`}, `sses`, []LogLevel{LevelError}, []LogLevel{LevelDebug, LevelWarn}},
+		{`case insensitive`, `INFO`, map[string]string{`mbta`: `Debug`}, `mbta`, []LogLevel{LevelDebug}, nil},
+	} {
+		if err := ConfigureLogging(LogConfig{Level: tc.level, Components: tc.components}); nil != err {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		l := NewLogger(tc.component)
+		for _, level := range tc.enabled {
+			if !l.Enabled(level) {
+				t.Errorf("%s: %s %s disabled", tc.name, tc.component, level)
+			}
+		}
+		for _, level := range tc.disabled {
+			if l.Enabled(level) {
+				t.Errorf("%s: %s %s enabled", tc.name, tc.component, level)
+			}
+		}
+	}
+
+	// a bad config leaves the last good one
+	for _, lc := range []LogConfig{
+		{Level: `loud`},
+		{Components: map[string]string{`lms`: `chatty`}},
+		{Format: `xml`},
+	} {
+		if err := ConfigureLogging(lc); nil == err {
+			t.Errorf("%+v accepted", lc)
+		}
+	}
+	if l := NewLogger(`mbta`); !l.Enabled(LevelDebug) {
+		t.Error("a rejected config changed the levels")
+	}
+
+	var buf bytes.Buffer
+	sink.mux.Lock()
+	sink.out = &buf
+	sink.mux.Unlock()
+	NewLogger(`mbta`).Debug(`polled`, `stop`, `place-sstat`)
+	NewLogger(`news`).Debug(`fetched`)
+	if got := buf.String(); !strings.Contains(got, `mbta`) || !strings.Contains(got, `stop=place-sstat`) || strings.Contains(got, `news`) {
+		t.Errorf("wrote %q, want the mbta entry alone", got)
+	}
+
+}
+
+func TestLogRotation(t *testing.T) {
+
+	tmp, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	path := filepath.Join(tmp, `rgbclock.log`)
+	rf, err := openRotatingFile(path, 100, 2)
+	if nil != err {
+		t.Fatal(err)
+	}
+	// 40 byte entries, two fit before each roll
+	for i := 0; i < 9; i++ {
+		if _, err = fmt.Fprintf(rf, "%-39d\n", i); nil != err {
+			t.Fatal(err)
+		}
+	}
+	rf.Close()
+
+	for _, tc := range []struct {
+		file  string
+		first int // first entry held, -1 for no file
+	}{
+		{path, 8},
+		{path + `.1`, 6},
+		{path + `.2`, 4},
+		{path + `.3`, -1},
+	} {
+		b, err := ioutil.ReadFile(tc.file)
+		if tc.first < 0 {
+			if nil == err {
+				t.Errorf("%s kept past the backups", filepath.Base(tc.file))
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("%s: %v", filepath.Base(tc.file), err)
+			continue
+		}
+		if len(b) > 100 {
+			t.Errorf("%s is %d bytes, past the limit", filepath.Base(tc.file), len(b))
+		}
+		if want := fmt.Sprintf("%-39d\n", tc.first); !strings.HasPrefix(string(b), want) {
+			t.Errorf("%s starts %q, want %q", filepath.Base(tc.file), b, want)
+		}
+	}
+
+	// no backups, the file starts over
+	path = filepath.Join(tmp, `bare.log`)
+	if rf, err = openRotatingFile(path, 100, 0); nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(rf, "%-39d\n", i)
+	}
+	rf.Close()
+	if b, _ := ioutil.ReadFile(path); fmt.Sprintf("%-39d\n", 2) != string(b) {
+		t.Errorf("bare log holds %q, want the last entry", b)
+	}
+	if _, err = os.Stat(path + `.1`); nil == err {
+		t.Error("backup written with none asked for")
+	}
+
+	// reopening appends to the size on disk
+	if rf, err = openRotatingFile(path, 100, 0); nil != err {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	if 40 != rf.size {
+		t.Errorf("reopened at %d bytes, want 40", rf.size)
+	}
+
+}
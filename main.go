@@ -3,14 +3,10 @@ package main
 import (
 	"fmt"
 	"image"
-	"image/color"
 	"image/draw"
-	"io/ioutil"
-	"math"
 	"os"
 	"os/signal"
-	"path"
-	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -20,691 +16,595 @@ import (
 	"github.com/disintegration/imaging"
 	"github.com/fogleman/gg"
 	"github.com/fsnotify/fsnotify"
-	"github.com/golang/freetype/truetype"
-	rgbmatrix "github.com/mcuadros/go-rpi-rgb-led-matrix"
 	"github.com/spf13/viper"
-
-	//rgbmatrix "github.com/shunte88/go-rpi-rgb-led-matrix"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/gomonobold"
 )
 
 var idx = []int{0, 1, 2, 3}
-var capture = false
-var base string
+var capture switchFlag
 var lat float64
 var lng float64
 
-func init() {
-
-	base, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	checkFatal(err)
+// reloads carries validated configs to the render loop, only the latest
+// waiting config is kept
+var reloads = make(chan *Config, 1)
 
+func init() {
 	cpu := runtime.NumCPU()
 	if cpu > 1 {
 		runtime.GOMAXPROCS(cpu - 1)
 	}
+}
 
-	weatherserveruri = os.Getenv(weatherServerURI)
-	if "" == weatherserveruri {
-		weatherserveruri = "http://192.168.1.249:5000/weather/current"
-	}
-
-	viper.SetConfigName("config")
-	viper.AddConfigPath("$HOME/rgbclock")
-	viper.AddConfigPath(".")
-	err = viper.ReadInConfig()
-	checkFatal(err)
-
-	fontfile = viper.GetString("RGB.fontfile")
-
-	layout = viper.GetString("RGB.layout")
-	W = viper.GetInt(layout + ".width")
-	H = viper.GetInt(layout + ".height")
-
-	rows = viper.GetInt("RGB.rows")
-	cols = viper.GetInt("RGB.cols")
-	folding = viper.GetBool("RGB.folding")
+// readConfig reads the --config file, else config.yml from $HOME/rgbclock
+// or the working folder, the command line overrides sit on top
+func readConfig() error {
 
-	daybright = viper.GetInt("RGB.daybright")
-	if daybright < 0 || daybright > 100 {
-		daybright = 20
+	if `` != *configPath {
+		viper.SetConfigFile(*configPath)
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath("$HOME/rgbclock")
+		viper.AddConfigPath(".")
 	}
-	nightbright = viper.GetInt("RGB.nightbright")
-	if nightbright < 0 || nightbright > 100 {
-		nightbright = daybright
+	if err := viper.ReadInConfig(); nil != err {
+		return err
 	}
 
-	scroll = viper.GetInt("RGB.scroll_limit")
-	hardware = viper.GetString("RGB.hardware")
-
-	showbright = viper.GetBool("RGB.showbright")
-	instrument = viper.GetBool("RGB.instrument")
-	experiment = viper.GetBool("RGB.experiment")
-
-	colorgrad1 = viper.GetString("RGB.colorgrad1")
-	colorgrad2 = viper.GetString("RGB.colorgrad2")
-
-	detail = viper.GetBool(layout + ".detail")
-	parallel = viper.GetInt(layout + ".parallel")
-	chain = viper.GetInt(layout + ".chain")
-	clockw = viper.GetInt(layout + ".clock.width")
-	clockh = viper.GetInt(layout + ".clock.height")
-
-	miAlpha = viper.GetFloat64(layout + ".icon.main.alpha")
-	miW = viper.GetInt(layout + ".icon.main.width")
-	miScale = viper.GetFloat64(layout + ".icon.main.scale")
-
-	wiAlpha = viper.GetFloat64(layout + ".icon.wind.alpha")
-	wiW = viper.GetInt(layout + ".icon.wind.width")
-	wiScale = viper.GetFloat64(layout + ".icon.wind.scale")
-
-	iconStyle = viper.GetString(layout + ".style")
+	// viper overrides outlast a reload of the file
+	if `` != *layoutFlag {
+		viper.Set(`rgb.layout`, *layoutFlag)
+	}
+	if `` != *backendFlag {
+		viper.Set(`rgb.backend`, *backendFlag)
+	}
+	if `` != *logLevel {
+		viper.Set(`log.level`, *logLevel)
+	}
+	return nil
 
-	lat = viper.GetFloat64("moon.lat")
-	lng = viper.GetFloat64("moon.lng")
+}
 
-	feeds := viper.Get("feeds").([]interface{})
-	newsDetail := viper.GetBool("news.detail")
-	seedTime := viper.GetString("news.window.time")  // time to display, seed - repeat duration affects actual time
-	newsDur := viper.GetInt("news.window.duration")  // minutes to display
-	newsRepeat := viper.GetInt("news.window.repeat") // minutes until next display
+// loadConfig reads and checks the config then sets up logging, the
+// layout and the icon map from it
+func loadConfig() (*Config, error) {
 
-	if `` != seedTime {
-		news = InitNews(News{
-			Feeds:    feeds,
-			Detail:   newsDetail,
-			SeedTime: seedTime,
-			Width:    126,
-			Limit:    62,
-			Velocity: 1,
-			Duration: (time.Duration(newsDur) * time.Minute),
-			Repeat:   (time.Duration(newsRepeat) * time.Minute),
-		})
+	weatherserveruri = *weatherURI
+	if `` == weatherserveruri {
+		weatherserveruri = "http://192.168.1.249:5000/weather/current"
 	}
-	// init icon map (dynamic scaling)
-	mapInit()
-
-	remaining = viper.GetBool("LMS.remaining")
-	baseimage := viper.GetString("LMS.visualize.baseimage")
-	meterbase := path.Join(viper.GetString("LMS.visualize.basefolder"), baseimage)
-	baseimage = `LMS.visualize.` +
-		strings.Replace(strings.Replace(baseimage, `.svg`, `.needle`, -1), `.png`, `.needle`, -1)
-
-	lms = NewLMSServer(LMSConfig{
-		Host:         viper.GetString("LMS.IP"),
-		Port:         viper.GetInt("LMS.port"),
-		Player:       viper.GetString("LMS.player"),
-		BaseFolder:   path.Join(base, `/cache/`),
-		Meter:        viper.GetString("LMS.visualize.meter"),
-		MeterMode:    viper.GetString("LMS.visualize.metermode"),
-		MeterLayout:  viper.GetString("LMS.visualize.layout"),
-		MeterBase:    meterbase,
-		NeedleColor:  viper.GetString(baseimage + `.color`),
-		NeedleWidth:  viper.GetFloat64(baseimage + `.width`),
-		NeedleLength: viper.GetFloat64(baseimage + `.length`),
-		NeedleWell:   viper.GetBool(baseimage + `.well`),
-		SSESActive:   viper.GetBool("LMS.sses.active"),
-		SSESHost:     viper.GetString("LMS.sses.IP"),
-		SSESPort:     viper.GetInt("LMS.sses.port"),
-		SSESEndpoint: viper.GetString("LMS.sses.endpoint"),
-	})
 
-	offset := viper.GetInt("transport.offset")
-	route := viper.GetString("transport.route")
-	stop := viper.GetString("transport.stop")
-	activeDays := viper.GetIntSlice("transport.active.days") // 0=Sunday
-	activeFrom, err := parseTime(viper.GetString("transport.active.from"))
-	if nil != err {
-		activeFrom, _ = parseTime(`04:30 AM`)
+	if err := readConfig(); nil != err {
+		return nil, err
 	}
-	activeUntil, err := parseTime(viper.GetString("transport.active.until"))
+	c, err := LoadConfig()
 	if nil != err {
-		activeFrom, _ = parseTime(`09:30 AM`)
+		return nil, err
 	}
-	api := os.Getenv(viper.GetString("transport.ApiEnv"))
+	setConfig(c)
 
-	transit = NewMBTAClient(api, route, stop, activeFrom, activeUntil, activeDays, time.Duration(offset)*time.Minute)
+	if err = ConfigureLogging(c.Log); nil != err {
+		return nil, err
+	}
 
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		daybright = viper.GetInt("RGB.daybright")
-		if daybright < 0 || daybright > 100 {
-			daybright = 20
-		}
-		nightbright = viper.GetInt("RGB.nightbright")
-		if nightbright < 0 || nightbright > 100 {
-			nightbright = daybright
-		}
+	applyLayout(c)
+	applyConfig(c)
 
-		capture = viper.GetBool("capture")
+	// init icon map (dynamic scaling)
+	mapInit()
+	return c, nil
 
-		showbright = viper.GetBool("RGB.showbright")
-		instrument = viper.GetBool("RGB.instrument")
-		experiment = viper.GetBool("RGB.experiment")
+}
 
-		activeFrom, err = parseTime(viper.GetString("transport.active.from"))
+// watchConfig queues each good config change for the render loop
+func watchConfig() {
+	viper.WatchConfig()
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		c, err := LoadConfig()
 		if nil != err {
-			activeFrom, _ = parseTime(`04:30 AM`)
+			logMain.Error(`config change rejected, keeping the running config`, `err`, err)
+			return
 		}
-		activeUntil, err = parseTime(viper.GetString("transport.active.until"))
-		if nil != err {
-			activeFrom, _ = parseTime(`09:30 AM`)
+		// the render loop rebuilds, a newer change replaces one not yet applied
+		select {
+		case <-reloads:
+		default:
 		}
-		activeDays = viper.GetIntSlice("transport.days") // 0=Sunday
+		reloads <- c
+		logMain.Info(`config change queued`, `file`, e.Name)
+	})
+}
 
-		transit.SetActiveHours(activeFrom, activeUntil, activeDays)
+// applyLayout sets the face geometry, the clock is rebuilt after
+func applyLayout(c *Config) {
 
-		lat = viper.GetFloat64("moon.lat")
-		lng = viper.GetFloat64("moon.lng")
+	fontfile = c.RGB.FontFile
 
-	})
+	layout = strings.ToLower(c.RGB.Layout)
+	lc := c.Layout()
+	W = lc.Width
+	H = lc.Height
 
-}
+	rows = c.RGB.Rows
+	cols = c.RGB.Cols
+	folding = c.RGB.Folding
+	mapper = c.Mapper()
 
-func main() {
+	scroll = c.RGB.ScrollLimit
+	hardware = c.RGB.Hardware
+	backend = c.RGB.Backend
 
-	/*
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Println(err)
-			}
-		}()
-	*/
+	colorgrad1 = c.RGB.ColorGrad1
+	colorgrad2 = c.RGB.ColorGrad2
 
-	defer lms.Close()
+	detail = lc.Detail
+	parallel = lc.Parallel
+	chain = lc.Chain
+	clockw = lc.Clock.Width
+	clockh = lc.Clock.Height
 
-	weather()
+	miAlpha = lc.Icon.Main.Alpha
+	miW = lc.Icon.Main.Width
+	miScale = lc.Icon.Main.Scale
 
-	mode = true
+	wiAlpha = lc.Icon.Wind.Alpha
+	wiW = lc.Icon.Wind.Width
+	wiScale = lc.Icon.Wind.Scale
 
-	config := &rgbmatrix.DefaultConfig
-	config.Rows = rows
-	config.Cols = cols
-	config.Parallel = parallel
-	config.ChainLength = chain
-	config.Brightness = daymode.brightness
-	config.HardwareMapping = hardware
-	config.ShowRefreshRate = false
-	config.InverseColors = false
-	config.DisableHardwarePulsing = false
-
-	// fixed assets
-	imPrecip, _ = cacheImage(`brolly`, imPrecip, 0.00, ``)
-	imHumid, _ = cacheImage(`humidity`, imHumid, 0.00, ``)
+	iconStyle = lc.Style
 
-	channelIdent(`R`, 20, 20)
-	channelIdent(`L`, 20, 20)
+}
 
-	togweather := false
+// applyConfig sets the settings a reload may change
+func applyConfig(c *Config) {
 
-	// concurrent updates
-	stop := sched(weather, 30*time.Second)
-	toggle := sched(toggleMode, 15*time.Second)
-	rotator := sched(rotator, 3*time.Second)
+	daybright = c.RGB.DayBright
+	nightbright = c.RGB.NightBright
+	fps = c.RGB.FPS
 
-	wf := float64(clockw)
-	hf := float64(clockh)
-	r := wf * 0.48
-	var cx float64 = wf / 2.00
-	var cy float64 = hf / 2.00
-	length := wf * 0.07
-	lw := wf * 0.04
+	showbright = c.RGB.ShowBright
+	instrument.Set(c.RGB.Instrument)
+	experiment.Set(c.RGB.Experiment)
+	capture.Set(c.Capture)
+	remaining = c.LMS.Remaining
 
-	ea := 0.000
+	lat = c.Moon.Lat
+	lng = c.Moon.Lng
+	sunWeather = c.Sun.Weather
+	dimmer.Configure(c)
 
-	font, err := truetype.Parse(gomonobold.TTF)
-	checkFatal(err)
+}
 
-	if `` != fontfile {
-		fb, err := ioutil.ReadFile(fontfile)
-		if nil == err {
-			font, _ = truetype.Parse(fb)
-		}
+// displayConfig the display for c, emulators mirror the panel chain
+// unless asked for the logical canvas; built from c alone so a reload can
+// compare the old and new displays
+func displayConfig(c *Config) (dc DisplayConfig, physical bool) {
+	r, lc := c.RGB, c.Layout()
+	physical = backendMatrix == r.Backend || !c.Emulator.Logical
+	dc = DisplayConfig{
+		Backend:    r.Backend,
+		Width:      r.Cols * lc.Chain,
+		Height:     r.Rows * lc.Parallel,
+		Brightness: currentBrightness(),
+		Scale:      c.Emulator.Scale,
+		Path:       c.Emulator.Path,
+		Listen:     c.Emulator.Listen,
 	}
+	if !physical {
+		dc.Width, dc.Height = lc.Width, lc.Height
+	}
+	return
+}
 
-	sface := truetype.NewFace(font, &truetype.Options{
-		Size: wf * 0.145,
-	})
-	dpface := truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.11,
-	})
-	dptface := truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.055,
-	})
-	dtface := truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.08,
-	})
-	lmsface := truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.066,
-	})
-
-	transit.SetFace(truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.066,
-		DPI:  72,
-	}))
-	transit.Start()
-	defer transit.Stop()
-
-	m, err := rgbmatrix.NewRGBLedMatrix(config)
-	checkFatal(err)
+// reportConfig prints the config problems, the exit code is 1 if any
+func reportConfig(readErr error) int {
 
-	rgbc := rgbmatrix.NewCanvas(m)
-	defer rgbc.Close()
-	bounds := rgbc.Bounds()
+	if nil != readErr {
+		fmt.Println(readErr)
+		return 1
+	}
+	file := viper.ConfigFileUsed()
+	c, err := LoadConfig()
+	if nil == err {
+		err = checkSections(c)
+	}
+	if nil != err {
+		fmt.Printf("%s: %v\n", file, err)
+		return 1
+	}
+	fmt.Printf("%s: ok\n", file)
+	if !c.API.Active && !c.Metrics.Active {
+		fmt.Printf("%s: /metrics not served, set api.active or metrics.active\n", file)
+	}
+	return 0
 
-	dc := gg.NewContext(W, H)
+}
 
-	var temps []string
+// checkSections the layout, scene and mapper sections check themselves
+// as they are built
+func checkSections(c *Config) error {
 
-	orangered := `#ff0000` // `#ff4500`
-	darkred := `#660000`
+	lc := c.Layout()
+	clockw, clockh = lc.Clock.Width, lc.Clock.Height
+	var problems ConfigErrors
+	if _, err := NewLayout(strings.ToLower(c.RGB.Layout), lc.Width, lc.Height, lc.Regions); nil != err {
+		problems.add(c.RGB.Layout+`.regions`, "%v", err)
+	}
+	if _, err := NewSceneScheduler(NewWidgetRegistry(), c.Scenes); nil != err {
+		problems.add(`scenes`, "%v", err)
+	}
+	if spec := c.Mapper(); `` != spec {
+		if _, err := NewPanelMapping(spec, lc.Chain, lc.Parallel, c.RGB.Cols*lc.Chain, c.RGB.Rows*lc.Parallel, c.RGB.Panels); nil != err {
+			problems.add(`RGB.mapper`, "%v", err)
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
 
-	tm := time.Now()
-	lastTempo := tm.Format(`15 04`)
-	tempo, err := imageTime(tm, hf*.2, orangered)
-	checkFatal(err)
+}
 
-	// eye-candy
-	grad := gg.NewRadialGradient(cx, cy, r+2, 0, 0, r+2)
+// services the control API, metrics, MQTT client and key input,
+// restarted when their sections change on reload
+type services struct {
+	api      *ControlAPI
+	metrics  *MetricsServer
+	mq       *MQTTClient
+	input    *InputKeys
+	notifier *Notifier
+}
 
-	grad.AddColorStop(0, parseHexColor(colorgrad1))
-	grad.AddColorStop(1, parseHexColor(colorgrad2))
+func (sv *services) start(c *Config, cl *Clock) {
 
-	lms.SetMaxLen(scroll)
-	lms.SetFace(truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.066,
-		DPI:  72,
-	}), "#ff9900c0")
+	if c.API.Active {
+		sv.api = NewControlAPI(c.API.Listen, cl.comp, cl.scenes, cl.cpu, sv.notifier)
+		sv.api.Start()
+	}
 
-	lastBrightness := daymode.brightness
-	var icache draw.Image
+	if c.Metrics.Active {
+		sv.metrics = NewMetricsServer(c.Metrics.Listen, metrics)
+		sv.metrics.Start()
+	}
 
-	lms.Start()
-	defer lms.Stop()
+	if mc := c.MQTT; mc.Active {
+		mq := NewMQTTClient(MQTTConfig{
+			Broker:   mc.Broker,
+			ClientID: mc.Client,
+			Username: mc.Username,
+			Password: os.Getenv(mc.PasswordEnv),
+			Topic:    mc.Topic,
+			QoS:      byte(mc.QoS),
+			Retain:   mc.Retain,
+			Interval: mc.Interval,
+		}, cl.scenes, cl.cpu, sv.notifier)
+		mq.Start()
+		sv.mq = mq
+	}
 
-	if nil != news {
-		news.SetFace(truetype.NewFace(font, &truetype.Options{
-			Size: hf * 0.055,
-			DPI:  72,
-		}))
+	if ic := c.Input; ic.Active {
+		in, err := NewInputKeys(ic.Device, ic.Keys)
+		if nil != err {
+			logMain.Error(`input rejected`, `device`, ic.Device, `err`, err)
+		} else {
+			in.Start()
+			sv.input = in
+		}
 	}
-	defer newsStop()
 
-	cpu := NewCPUStat(truetype.NewFace(font, &truetype.Options{
-		Size: hf * 0.150,
-		DPI:  72,
-	}), `#00ffff77`)
-	defer cpu.Stop()
+}
 
-	angle := 0.20
-	inca := angle
-	dump := 0
+func (sv *services) stop() {
+	if nil != sv.api {
+		sv.api.Close()
+		sv.api = nil
+	}
+	if nil != sv.metrics {
+		sv.metrics.Close()
+		sv.metrics = nil
+	}
+	if nil != sv.mq {
+		sv.mq.Stop()
+		sv.mq = nil
+	}
+	if nil != sv.input {
+		sv.input.Stop()
+		sv.input = nil
+	}
+}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
+// attach points the running services at a rebuilt clock, those whose
+// section changed restart
+func (sv *services) attach(old, c *Config, cl *Clock) {
+	if old.API != c.API || old.Metrics != c.Metrics || old.MQTT != c.MQTT || !reflect.DeepEqual(old.Input, c.Input) {
+		sv.stop()
+		sv.start(c, cl)
+		return
+	}
+	if nil != sv.api {
+		sv.api.Attach(cl.comp, cl.scenes, cl.cpu)
+	}
+	if nil != sv.mq {
+		sv.mq.Attach(cl.scenes, cl.cpu)
+	}
+}
 
-	go func() {
-		sig := <-sigs
-		fmt.Printf("shutdown inc. GC (%v)\n", sig)
-		os.Exit(0)
-	}()
+// reload rebuilds the clock for c, a clock or display that fails to
+// build leaves the running one in place
+func reload(c *Config, cl *Clock, rgbc Display, notifier *Notifier) (*Clock, Display, error) {
 
-	for {
+	old := currentConfig()
+	applyLayout(c)
+	next, err := newClock(c, notifier)
+	if nil != err {
+		applyLayout(old)
+		return cl, rgbc, err
+	}
 
-		if lastBrightness != daymode.brightness {
-			err = rgbc.SetBrightness(uint32(daymode.brightness))
-			lastBrightness = daymode.brightness
-			if nil != err {
-				fmt.Println("brightness", err)
+	odc, _ := displayConfig(old)
+	ndc, _ := displayConfig(c)
+	if odc != ndc {
+		rgbc.Close()
+		d, err := NewDisplay(ndc)
+		if nil != err {
+			next.Stop()
+			applyLayout(old)
+			if d, rerr := NewDisplay(odc); nil == rerr {
+				return cl, d, err
 			}
+			checkFatal(err)
 		}
+		rgbc = d
+	}
 
-		if nil != icache {
-			dc.DrawImageAnchored(icache, 0, 0, 0, 0)
-		} else {
+	cl.Stop()
+	setConfig(c)
+	setClock(next)
+	if err := ConfigureLogging(c.Log); nil != err {
+		logMain.Error(`log config rejected`, `err`, err)
+	}
+	applyConfig(c)
+	if !reflect.DeepEqual(old.Layout(), c.Layout()) {
+		rescaleWeatherIcons()
+	}
+	next.Start()
+	return next, rgbc, nil
 
-			dc.SetHexColor("#000000")
-			dc.Clear()
+}
 
-			cornerScroll(dc, `corner-scroll`, 0.9, `#ffcc0008`, false)
-			cornerScroll(dc, `alt-corner-scroll`, 0.0, `#d4af3708`, true)
+// runClock drives the display until a signal ends it
+func runClock(c *Config) int {
 
-			// keep it crisp, fold scroll under face
-			dc.DrawCircle(cx, cy, r+1)
-			dc.Fill()
+	/*
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	*/
 
-			var imGlobal iconCache
-			imGlobal, _ = cacheImage(`global`, imGlobal, 0.666, ``)
-			dc.DrawImageAnchored(imGlobal.image, 0, 0, 0, 0)
-			dc.SetFillStyle(grad)
-			dc.DrawCircle(cx, cy, r+1)
-			dc.Fill()
+	// runs ahead of the clock stop, the workers end before the art cache closes
+	defer supervisor.Wait(shutdownGrace)
 
-			dc.SetHexColor(orangered)
-			dc.SetLineWidth(lw)
-			for i := 0; i < 60; i += 5 {
+	prepareAssets()
 
-				var th float64 = math.Pi/30.00*float64(i) - math.Pi/3.00
-				x, y := math.Cos(th), math.Sin(th)
+	// concurrent updates
+	stop := sched(weather, wallDelay(30*time.Second))
+	toggle := sched(toggleMode, 15*time.Second)
+	rotator := sched(rotator, 3*time.Second)
 
-				x1, y1 := (float64(r)-float64(length))*x+cx, (float64(r)-float64(length))*y+cy
-				x2, y2 := float64(r)*x+cx, float64(r)*y+cy
+	dcfg, _ := displayConfig(c)
+	rgbc, err := NewDisplay(dcfg)
+	checkFatal(err)
 
-				dc.DrawLine(x1, y1, x2, y2)
-				dc.Stroke()
+	notifier := NewNotifier()
+	cl, err := newClock(c, notifier)
+	checkFatal(err)
+	setClock(cl)
+	cl.Start()
 
-			}
+	registerMetrics(metrics, func() FrameStats { return currentClock().comp.Stats() })
 
-			// cache the clock face - zero struggles
-			icache = imaging.New(clockw, clockh, color.NRGBA{0, 0, 0, 0})
-			icache = imaging.Paste(icache, dc.Image(), image.Pt(0, 0))
+	svc := &services{notifier: notifier}
+	svc.start(c, cl)
 
-		}
+	lastBrightness := currentBrightness()
+	lastFPS := fps
 
-		tm = time.Now()
-		s := float64(tm.Second())
+	angle := 0.20
+	inca := angle
+	var rec Recorder
 
-		h, err := strconv.ParseFloat(tm.Format("5.000"), 64)
-		if nil == err {
-			ea = 6 * h
-		} else {
-			ea = 6 * s
-		}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
 
-		// glitchy under one second ??
-		if ea < 8 {
-			dc.SetHexColor(darkred)
-			dc.DrawCircle(float64(cx), float64(cy), float64(r))
-			dc.Stroke()
+	go func() {
+		sig := <-sigs
+		logMain.Info(`shutdown`, `signal`, sig)
+		supervisor.Cancel()
+		// a second signal or a stuck shutdown exits regardless
+		select {
+		case sig = <-sigs:
+		case <-time.After(2 * shutdownGrace):
 		}
+		logMain.Warn(`forced exit`, `signal`, sig)
+		os.Exit(1)
+	}()
 
-		dc.SetHexColor(orangered)
-		dc.DrawArc(float64(cx), float64(cy), float64(r), degToRadians(0), degToRadians(ea))
-		dc.Stroke()
+	var exp *gg.Context
 
-		// svg time - needs crispier
-		ts := tm.Format("15 04")
-		if ts != lastTempo {
-			lastTempo = ts
-			tempo, err = imageTime(tm, hf*.2, orangered)
-			checkFatal(err)
-		}
-		if 0 == int(s)%2 {
-			dc.DrawImageAnchored(tempo[0], int(cx), int(cy), 0.5, 0.5)
-		} else {
-			dc.DrawImageAnchored(tempo[1], int(cx), int(cy), 0.5, 0.5)
-		}
+	ctx := supervisor.Context()
+	for nil == ctx.Err() {
 
-		// place weather icon
-		if imIcon.image != nil {
-			dc.DrawImageAnchored(imIcon.image, int(wf/2), int(0.71875*hf), 0.5, 0.5)
-		}
+		start := time.Now()
 
-		if !mode {
-			temps = strings.Split(w.Current.Temperature, " ")
-		} else {
-			p := w.Current.Daypart0.Precipitation
-			wdx := float64(cx + (wf * 0.09))
-			wdy := float64(0.25 * hf)
-			if 0 == int(s)%2 {
-				if togweather && `0%` != p {
-					temps[1] = p
-					// precipitation
-					if imPrecip.image != nil {
-						if `100%` == p {
-							wdx += 5.00
-						}
-						dc.DrawImageAnchored(imPrecip.image, int(wdx), int(wdy), 0.5, 0.5)
-					}
-				} else {
-					temps[1] = w.Current.Humidity
-					// humidity
-					if imHumid.image != nil {
-						dc.DrawImageAnchored(imHumid.image, int(wdx), int(wdy), 0.5, 0.5)
-					}
-				}
+		changed := false
+		select {
+		case nc := <-reloads:
+			old := currentConfig()
+			if cl, rgbc, err = reload(nc, cl, rgbc, notifier); nil != err {
+				logMain.Error(`config reload failed, rolled back`, `err`, err)
 			} else {
-				togweather = !togweather
-				temps = strings.Split(w.Current.Wind+" -- mph", " ") // fix for "Calm"
-				// place wind icon
-				if imWindDir.image != nil {
-					dc.DrawImageAnchored(imWindDir.image, int(wdx), int(wdy), 0.5, 0.5)
-				}
+				svc.attach(old, nc, cl)
+				lastFPS = fps
+				lastBrightness = -1
+				exp = nil
+				changed = true
+				logMain.Info(`config reloaded`, `layout`, layout)
 			}
+		default:
 		}
-		dc.SetFontFace(sface)
-		if !mode {
-			dc.SetHexColor("#0099ff")
-			wdy := float64(0.27 * hf)
-			dc.DrawStringAnchored(fmt.Sprintf("%v", math.Round(w.Current.tempF)), 8+(wf*.25), wdy, 0.5, 0.5)
-			if imThermo.image != nil {
-				dc.DrawImageAnchored(imThermo.image, int(cx), 4+int(wdy), 0.5, 0.5)
+
+		if b := currentBrightness(); lastBrightness != b {
+			err = rgbc.SetBrightness(b)
+			lastBrightness = b
+			if nil != err {
+				logDisplay.Warn(`set brightness failed`, `err`, err)
 			}
+			changed = true
 		}
-		dc.SetHexColor("#66ff99")
-		if !mode {
-			wdy := float64(0.27 * hf)
-			dc.DrawStringAnchored(fmt.Sprintf("%v", math.Round(w.Current.tempC)), -8+(wf*.75), wdy, 0.5, 0.5)
-		} else {
-			dc.DrawStringAnchored(temps[1], wf/3, hf*0.27, 0.5, 0.5)
+		if lastFPS != fps {
+			cl.comp.SetFPS(fps)
+			lastFPS = fps
 		}
 
-		if detail && W > 64 {
-			placeWeatherDetail(dc, hf, dpface)
+		if cl.Compose(timeNow()) {
+			changed = true
 		}
+		frame := cl.comp.Frame()
 
-		if `full` == layout {
-			dc.SetHexColor("#ff9900")
-			dc.SetFontFace(dtface)
-			temps = hackaDate(tm)
-			dpos := 2 + (5 * (hf / 8))
-			dc.DrawStringAnchored(temps[idx[0]], wf/4, dpos, 0.5, 0.5)
-			dc.DrawStringAnchored(temps[idx[1]], 3*(wf/4), dpos, 0.5, 0.5)
-
-			mx := int(wf * 0.171875)
-			moonI, err := NewLuna(tm, lat, lng).PhaseIcon(mx, mx)
-			if err == nil && moonI != nil {
-				dc.DrawImageAnchored(moonI, int(3*(wf/4))+2, int(3*(hf/4))+2, .5, .5)
+		if experiment.Get() {
+			if nil == exp {
+				exp = gg.NewContext(W, H)
 			}
+			exp.DrawImage(frame, 0, 0)
+			exp.DrawImageAnchored(imaging.Rotate(frame, -angle, image.Black), int(cl.cx), int(cl.cy), 0.5, 0.5)
+			frame = exp.Image().(*image.RGBA)
+			changed = true
 
-			if instrument {
-				if cpu.CPUStatsTemp() != nil {
-					hh := int(cpu.CPUStatsTemp().Bounds().Max.Y / 2)
-					dc.DrawImageAnchored(cpu.CPUStatsTemp(), int(wf/2), hh, .5, .5)
-				}
-				if cpu.CPUStatsUsage() != nil {
-					hh := H - int(cpu.CPUStatsUsage().Bounds().Max.Y/2)
-					dc.DrawImageAnchored(cpu.CPUStatsUsage(), int(wf/2), hh, .5, .5)
-				}
-				if cpu.MemStats() != nil {
-					hh := H - int(2.8*float64(cpu.MemStats().Bounds().Max.Y/2))
-					dc.DrawImageAnchored(cpu.MemStats(), int(wf/2), hh, .5, .5)
-				}
-			}
-			if showbright {
-				dc.DrawStringAnchored(evut, cx, hf-(length-2), 0.5, 0.5)
-				dc.DrawStringAnchored(imIcon.last, cx, (hf-(length-2))-8, 0.5, 0.5)
+			angle = angle + inca
+			if angle > 360 || angle < 0 {
+				inca *= -1
 			}
-			//dc.SetHexColor(w.Current.trendColor)
-			//dc.DrawStringAnchored(w.Current.trend, 32, 2+cy+(hf/4), 0.5, 0.5)
-
 		}
 
-		if `play` == lms.Player.Mode {
-
-			pinClockTop(dc)
+		if on := capture.Get(); on || rec.Recording() {
+			// a finished recording turns capture off
+			if !rec.Update(on, frame, changed, start) && on {
+				capture.Set(false)
+			}
+		}
 
-			if mode {
-				placeWeatherDetail(dc, hf/2, dptface)
+		if changed {
+			if nil != cl.pmap {
+				cl.pmap.Apply(cl.phys, frame)
+				rgbc.Draw(cl.phys)
 			} else {
-				dst := imaging.Resize(lms.Coverart(), 64, 64, imaging.Lanczos)
-				dc.DrawImage(dst, 65, 0)
+				rgbc.Draw(frame)
 			}
+			rgbc.Render()
+		}
 
-			dst := imaging.Resize(lms.Coverart(), 126, 49, imaging.Lanczos)
-			dst = imaging.Blur(imaging.AdjustBrightness(dst, -40), 6.5)
-			dc.DrawImage(dst, 1, 66)
+		cl.comp.Pace(start, changed)
 
-			if lms.VUActive() && !mode {
+	}
 
-				dc.DrawImageAnchored(lms.VU(), int(cx), int(cy)+24, .5, .5)
+	rec.Stop()
 
-			} else {
+	// blank the panel, the last frame would otherwise stay lit
+	rgbc.Draw(image.NewRGBA(rgbc.Bounds()))
+	rgbc.Render()
+	rgbc.Close()
 
-				dc.SetHexColor("#ff9900cc")
-				dc.SetFontFace(lmsface)
-
-				pos := int(cy + 11)
-				dc.DrawImageAnchored(lms.Player.Albumartist.Image(), int(W/2), pos, 0.5, 0.5)
-				pos += 9
-				dc.DrawImageAnchored(lms.Player.Album.Image(), int(W/2), pos, 0.5, 0.5)
-				pos += 9
-				dc.DrawImageAnchored(lms.Player.Title.Image(), int(W/2), pos, 0.5, 0.5)
-				pos += 9
-				dc.DrawImageAnchored(lms.Player.Artist.Image(), int(W/2), pos, 0.5, 0.5)
-				dc.DrawStringAnchored(fmt.Sprintf("• %v •", lms.Player.Year), float64(W/2), float64(cy+44), 0.5, 0.5)
-				pos += 9
-				dc.DrawImageAnchored(lms.PlayModifiers(), 1, pos, 0, 0.5)
-				vol := lms.Volume()
-				dc.DrawImageAnchored(vol, W-(vol.Bounds().Max.X+2), pos, 0, 0.5)
+	svc.stop()
+	cl.Stop()
 
-			}
+	stop <- true
+	toggle <- true
+	rotator <- true
+	logMain.Info(`stopping`)
+	return 0
 
-			placeBorderZone(dc, lmsface, lw, 68, 50)
-			drawHorizontalBar(dc, 10, 115, lms.Player.Percent)
-			base := float64(H - 9 + 4)
-			dc.SetHexColor("#ff9900")
-			dc.DrawStringAnchored(lms.Player.TimeStr, 2, base, 0, 0.5)
-			if remaining {
-				dc.DrawStringAnchored(lms.Player.RemStr, float64(W-2), base, 1, 0.5)
-			} else {
-				dc.DrawStringAnchored(lms.Player.DurStr, float64(W-2), base, 1, 0.5)
-			}
-			dc.SetHexColor("#0099ffcc")
-			dc.DrawStringAnchored(lms.Player.Bitty, float64(W/2), base, 0.5, 0.5)
-			base = 0.390625 * wf
-			dc.DrawImageAnchored(lms.VolumePopup(int(base), int(base)), int(wf/2), int(hf/4), .5, .5)
-
-		} else if transit.Display {
-			pinClockTop(dc)
-			placeWeatherDetail(dc, hf/2, dptface)
-			// active transit here - top 3
-			noData := true
-			pos := int(cy + 16)
-			for pred := range transit.Predictions() {
-				dc.DrawImageAnchored(pred, int(W/2), pos, 0.5, 0.5)
-				pos += 16
-				noData = false
-			}
-			if noData {
-				dc.SetHexColor("#ff9900cc")
-				dc.SetFontFace(sface)
-				dc.DrawStringAnchored(`WAITING`, float64(W/2), float64(pos+3), 0.5, 0.5)
-				dc.DrawStringAnchored(`FOR MBTA`, float64(W/2), float64(pos+19), 0.5, 0.5)
-			}
-			placeBorderZone(dc, lmsface, lw, 60, 55)
-		} else {
-			if news.Display() {
-				pinClockTop(dc)
-				placeWeatherDetail(dc, hf/2, dptface)
-				pos := int(cy + 2)
-				dc.DrawImageAnchored(news.Image(), 1, pos, 0, 0)
-				placeBorderZone(dc, lmsface, lw, 60, 59)
-			}
-		}
-		dc.SetLineWidth(lw)
+}
 
-		if experiment {
-			dc.DrawImageAnchored(imaging.Rotate(dc.Image(), -angle, image.Black), int(cx), int(cy), 0.5, 0.5)
+// prepareAssets fetches the weather and caches the fixed icons
+func prepareAssets() {
 
-			angle = angle + inca
-			if angle > 360 || angle < 0 {
-				inca *= -1
-			}
-		}
+	weather()
 
-		if capture && dump < 500 {
-			go dumpImage(dump, dc.Image())
-			dump++
-		}
+	mode = true
 
-		if folding {
-			itmp := imaging.Rotate180(dc.Image())
-			dst := imaging.New(4*64, 64, color.NRGBA{0, 0, 0, 0})
-			dst = imaging.Paste(dst, dc.Image(), image.Pt(0, 0))
-			dst = imaging.Paste(dst, itmp, image.Pt(128, 0))
-			draw.Draw(rgbc, dst.Bounds(), dst, image.ZP, draw.Over)
-		} else {
-			draw.Draw(rgbc, bounds, dc.Image(), image.ZP, draw.Over)
-		}
+	cacheFixedIcons()
 
-		rgbc.Render()
-		time.Sleep(60 * time.Millisecond)
+}
 
-	}
+// cacheFixedIcons the icons that never change
+func cacheFixedIcons() {
 
-	stop <- true
-	toggle <- true
-	rotator <- true
+	iconRender.Lock()
+	renderFixedIcons()
+	iconRender.Unlock()
+
+	channelIdent(`R`, 20, 20)
+	channelIdent(`L`, 20, 20)
 
 }
 
-func placeWeatherDetail(dc *gg.Context, hf float64, dpface font.Face) {
+func placeWeatherDetail(dc *gg.Context, rg *Region, dpface font.Face) {
 	dc.SetFontFace(dpface)
-	placeDetail(dc, w.Current.Daypart1, imIconDP1.image, hf)
-	placeDetail(dc, w.Current.Daypart2, imIconDP2.image, hf)
-	placeDetail(dc, w.Current.Daypart3, imIconDP3.image, hf)
-	placeDetail(dc, w.Current.Daypart4, imIconDP4.image, hf)
+	wc := currentWeather().Current
+	iconsMux.RLock()
+	dp1, dp2, dp3, dp4 := imIconDP1.image, imIconDP2.image, imIconDP3.image, imIconDP4.image
+	iconsMux.RUnlock()
+	placeDetail(dc, wc.Daypart1, dp1, rg.Rect)
+	placeDetail(dc, wc.Daypart2, dp2, rg.Rect)
+	placeDetail(dc, wc.Daypart3, dp3, rg.Rect)
+	placeDetail(dc, wc.Daypart4, dp4, rg.Rect)
+	if health.Stale(`weather`) {
+		staleMark(dc, rg.Rect)
+	}
 }
 
-func placeBorderZone(dc *gg.Context, lmsface font.Face, lw, y1, y2 float64) {
-	dc.SetFontFace(lmsface)
+// placeBorderZone frames the lower zone, the black mask clears overdraw below
+func placeBorderZone(dc *gg.Context, lw float64, zr image.Rectangle, mask int) {
+	x, y := float64(zr.Min.X), float64(zr.Min.Y)
 	dc.SetHexColor("#000000")
 	dc.SetLineWidth(lw - 2)
-	dc.DrawRectangle(0, 66, 128, y1)
+	dc.DrawRectangle(x, y, float64(zr.Dx()), float64(mask))
 	dc.Stroke()
 	dc.SetLineWidth(0.5)
 	dc.SetHexColor("#ff9900")
-	dc.DrawRectangle(0, 66, 128, y2)
+	dc.DrawRectangle(x, y, float64(zr.Dx()), float64(zr.Dy()))
 	dc.Stroke()
 }
 
-func pinClockTop(dc *gg.Context) {
-	dst := imaging.Resize(dc.Image(), 65, 65, imaging.CatmullRom) //Lanczos)
-	dc.DrawImage(dst, 0, 0)
-	dc.SetHexColor("#000000")
-	dc.DrawRectangle(65, 0, 65, 65)
-	dc.Fill()
-	dc.DrawRectangle(0, 65, 127, 63)
-	dc.Fill()
-}
-
-func placeDetail(dc *gg.Context, d Daypart, wi draw.Image, hf float64) {
+func placeDetail(dc *gg.Context, d Daypart, wi draw.Image, dr image.Rectangle) {
 	f, err := strconv.ParseFloat(d.ID, 64)
 	if err != nil {
 		return
 	}
+	hf := float64(dr.Dy())
 	dx := (f - 1.00) * 15
-	pdy1 := (hf * 0.11) + dx + 1
-	pdy2 := (hf * 0.24) + dx
+	pdy1 := float64(dr.Min.Y) + (hf * 0.11) + dx + 1
+	pdy2 := float64(dr.Min.Y) + (hf * 0.24) + dx
+	lx := float64(dr.Min.X)
 
 	if "1" != d.ID {
 		dc.SetLineWidth(0.15)
 		dc.SetHexColor("#86acac")
-		dc.DrawLine(67, pdy1-7, 127, pdy1-7)
+		dc.DrawLine(lx+2, pdy1-7, float64(dr.Max.X-1), pdy1-7)
 		dc.Stroke()
 	}
 
 	dc.SetHexColor("#2c3e50")
-	dc.DrawString(d.Label, 68, pdy1)
+	dc.DrawString(d.Label, lx+3, pdy1)
 	dc.SetHexColor("#0f3443")
-	dc.DrawString(fmt.Sprintf("% 4s %sF", d.Hilo, d.Temperature), 68, pdy2)
+	dc.DrawString(fmt.Sprintf("% 4s %sF", d.Hilo, d.Temperature), lx+3, pdy2)
 	if f > 1 {
 		f += 1.00
 	}
 	if wi != nil {
-		dc.DrawImageAnchored(wi, W-23, int(pdy1-12+(f-1)), 0, 0)
+		dc.DrawImageAnchored(wi, dr.Max.X-23, int(pdy1-12+(f-1)), 0, 0)
 	}
 }
 
@@ -729,9 +629,8 @@ func rotator() {
 	idx = append(idx[1:], idx[0:1]...)
 }
 
-func drawHorizontalBar(dc *gg.Context, x, y, pcnt float64) {
+func drawHorizontalBar(dc *gg.Context, x, y, l, pcnt float64) {
 	dc.SetLineWidth(0.3)
-	l := float64(W) - (2 * x)
 	lp := (l - 2.00) * (pcnt / 100.00)
 	dc.SetHexColor("#000000")
 	dc.DrawRectangle(x+1, y+1, l-2, 2)
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// keepLayout saves the config flags, the running config and the globals
+// applyLayout sets, the returned func puts them back
+func keepLayout() func() {
+	cp, lf, c := *configPath, *layoutFlag, currentConfig()
+	globals := []interface{}{&fontfile, &layout, &W, &H, &rows, &cols, &folding, &mapper,
+		&scroll, &hardware, &backend, &colorgrad1, &colorgrad2, &detail, &parallel, &chain,
+		&clockw, &clockh, &miAlpha, &miW, &miScale, &wiAlpha, &wiW, &wiScale, &iconStyle}
+	saved := make([]reflect.Value, len(globals))
+	for i, g := range globals {
+		v := reflect.ValueOf(g).Elem()
+		saved[i] = reflect.New(v.Type()).Elem()
+		saved[i].Set(v)
+	}
+	return func() {
+		for i, g := range globals {
+			reflect.ValueOf(g).Elem().Set(saved[i])
+		}
+		*configPath, *layoutFlag = cp, lf
+		setConfig(c)
+	}
+}
+
+// TestReloadDisplay a reload that changes the panel chain rebuilds the
+// display, one whose display fails keeps the running geometry
+func TestReloadDisplay(t *testing.T) {
+
+	tmp, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	defer keepLayout()()
+	defer viper.Reset()
+	viper.Reset()
+	viper.Set(`emulator.logical`, false)
+	viper.Set(`emulator.path`, filepath.Join(tmp, `rgbclock.png`))
+	*configPath, *layoutFlag = `testdata/config.yml`, `full`
+	c, err := loadConfig()
+	if nil != err {
+		t.Fatal(err)
+	}
+	cacheFixedIcons()
+
+	notifier := NewNotifier()
+	cl, err := newClock(c, notifier)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dc, _ := displayConfig(c)
+	d, err := NewDisplay(dc)
+	if nil != err {
+		t.Fatal(err)
+	}
+	setConfig(c)
+	defer func() { cl.Stop(); d.Close() }()
+
+	if want := image.Rect(0, 0, 256, 64); want != d.Bounds() {
+		t.Fatalf("display %v, want %v", d.Bounds(), want)
+	}
+
+	viper.Set(`full.chain`, 2)
+	viper.Set(`full.parallel`, 2)
+	viper.Set(`full.mapper`, ``)
+	nc, err := LoadConfig()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if cl, d, err = reload(nc, cl, d, notifier); nil != err {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 128, 128); want != d.Bounds() {
+		t.Errorf("reloaded display %v, want %v", d.Bounds(), want)
+	}
+	if 2 != chain || 2 != parallel {
+		t.Errorf("reloaded chain %d parallel %d, want 2 and 2", chain, parallel)
+	}
+
+	bad, err := LoadConfig()
+	if nil != err {
+		t.Fatal(err)
+	}
+	bad.RGB.Backend = `crt`
+	bad.Layouts[`full`] = LayoutSection{Width: 128, Height: 128, Chain: 4, Parallel: 1}
+	if cl, d, err = reload(bad, cl, d, notifier); nil == err {
+		t.Fatal("reloaded onto an unknown backend")
+	}
+	if want := image.Rect(0, 0, 128, 128); want != d.Bounds() {
+		t.Errorf("restored display %v, want %v", d.Bounds(), want)
+	}
+	if 2 != chain || 2 != parallel || backendPNG != backend {
+		t.Errorf("restored chain %d parallel %d backend %s", chain, parallel, backend)
+	}
+
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+type (
+	// PixelMapper rearranges a w x h matrix, mappers chain in the order
+	// given, each seeing the visible size of the one before, as hzeller's
+	// --led-pixel-mapper
+	PixelMapper interface {
+		// Visible returns the size seen through the mapper
+		Visible(w, h int) (int, int, error)
+		// Map returns the matrix position of visible x, y, negative when
+		// the pixel has no panel
+		Map(w, h, x, y int) (int, int)
+	}
+
+	mapperFactory func(chain, parallel int, param string, panels []interface{}) (PixelMapper, error)
+
+	// PanelMapping is a compiled mapper chain, a lookup from canvas pixel
+	// to chain pixel
+	PanelMapping struct {
+		Spec   string
+		Width  int // canvas
+		Height int
+		matrix image.Rectangle
+		lut    []int // matrix pixel offset per canvas pixel, -1 unmapped
+	}
+
+	uMapper struct {
+		parallel int
+	}
+
+	vMapper struct {
+		chain    int
+		parallel int
+		z        bool
+	}
+
+	rotateMapper struct {
+		angle int
+	}
+
+	mirrorMapper struct {
+		horizontal bool
+	}
+
+	// serpentineMapper lays the chain in rows of n panels, every other
+	// row runs back with the panels upside down
+	serpentineMapper struct {
+		chain    int
+		parallel int
+		n        int
+	}
+
+	// panelMapper places each panel by table, config RGB.panels
+	panelMapper struct {
+		chain    int
+		parallel int
+		panels   []panelOffset
+	}
+
+	panelOffset struct {
+		at     image.Point
+		rotate int
+	}
+)
+
+var pixelMappers = map[string]mapperFactory{
+	`u-mapper`:   newUMapper,
+	`u-maper`:    newUMapper, // as shipped in early config.yml
+	`v-mapper`:   newVMapper,
+	`rotate`:     newRotateMapper,
+	`mirror`:     newMirrorMapper,
+	`serpentine`: newSerpentineMapper,
+	`panels`:     newPanelMapper,
+}
+
+// NewPanelMapping compiles a mapper spec, "U-mapper;Rotate:180", for a
+// chain x parallel arrangement of panels forming a mw x mh matrix, panels
+// is the RGB.panels table read by the Panels mapper
+func NewPanelMapping(spec string, chain, parallel, mw, mh int, panels []interface{}) (*PanelMapping, error) {
+
+	var chained []PixelMapper
+	for _, m := range strings.Split(spec, `;`) {
+		m = strings.TrimSpace(m)
+		if `` == m {
+			continue
+		}
+		name, param := m, ``
+		if i := strings.Index(m, `:`); i >= 0 {
+			name, param = m[:i], m[i+1:]
+		}
+		mf, ok := pixelMappers[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown pixel mapper %q", name)
+		}
+		pm, err := mf(chain, parallel, strings.TrimSpace(param), panels)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		chained = append(chained, pm)
+	}
+
+	// matrix size as seen by each mapper
+	sizes := []image.Point{image.Pt(mw, mh)}
+	for i, pm := range chained {
+		w, h, err := pm.Visible(sizes[i].X, sizes[i].Y)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, image.Pt(w, h))
+	}
+
+	vs := sizes[len(sizes)-1]
+	pmap := &PanelMapping{
+		Spec:   spec,
+		Width:  vs.X,
+		Height: vs.Y,
+		matrix: image.Rect(0, 0, mw, mh),
+		lut:    make([]int, vs.X*vs.Y),
+	}
+	for y := 0; y < vs.Y; y++ {
+		for x := 0; x < vs.X; x++ {
+			mx, my := x, y
+			for i := len(chained) - 1; i >= 0 && mx >= 0; i-- {
+				mx, my = chained[i].Map(sizes[i].X, sizes[i].Y, mx, my)
+			}
+			o := -1
+			if image.Pt(mx, my).In(pmap.matrix) {
+				o = 4 * (my*mw + mx)
+			}
+			pmap.lut[y*vs.X+x] = o
+		}
+	}
+	return pmap, nil
+
+}
+
+// Bounds returns the physical matrix size
+func (pmap *PanelMapping) Bounds() image.Rectangle {
+	return pmap.matrix
+}
+
+// Apply maps the canvas into the matrix buffer, both sized per the mapping
+func (pmap *PanelMapping) Apply(dst, src *image.RGBA) {
+	for y := 0; y < pmap.Height; y++ {
+		so := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y)
+		row := pmap.lut[y*pmap.Width : (y+1)*pmap.Width]
+		for x, o := range row {
+			if o >= 0 {
+				copy(dst.Pix[o:o+4], src.Pix[so+4*x:so+4*x+4])
+			}
+		}
+	}
+}
+
+func newUMapper(chain, parallel int, param string, _ []interface{}) (PixelMapper, error) {
+	if chain < 2 || 0 != chain%2 {
+		return nil, fmt.Errorf("needs an even chain, have %d", chain)
+	}
+	if parallel > 1 && 0 != parallel%2 {
+		return nil, fmt.Errorf("needs 1 or an even parallel, have %d", parallel)
+	}
+	return &uMapper{parallel: parallel}, nil
+}
+
+// Visible folds the chain in half
+func (um *uMapper) Visible(w, h int) (int, int, error) {
+	if 0 != h%um.parallel {
+		return 0, 0, fmt.Errorf("U-mapper: height %d not divisible by parallel %d", h, um.parallel)
+	}
+	return w / 2, 2 * h, nil
+}
+
+// Map upper half to the far end of the chain, the lower folded back
+func (um *uMapper) Map(w, h, x, y int) (int, int) {
+	ph := h / um.parallel
+	vw := w / 2
+	slab := 2 * ph
+	by := (y / slab) * ph
+	y %= slab
+	if y < ph {
+		x += w / 2
+	} else {
+		x = vw - x - 1
+		y = slab - y - 1
+	}
+	return x, by + y
+}
+
+func newVMapper(chain, parallel int, param string, _ []interface{}) (PixelMapper, error) {
+	return &vMapper{chain: chain, parallel: parallel, z: strings.EqualFold(`Z`, param)}, nil
+}
+
+// Visible stacks the chain vertically
+func (vm *vMapper) Visible(w, h int) (int, int, error) {
+	return w * vm.parallel / vm.chain, h * vm.chain / vm.parallel, nil
+}
+
+// Map panels top down, Z flips every other panel
+func (vm *vMapper) Map(w, h, x, y int) (int, int) {
+	pw, ph := w/vm.chain, h/vm.parallel
+	xs := y / ph * pw
+	ys := x / pw * ph
+	xi, yi := x%pw, y%ph
+	if vm.z && 1 == (vm.chain-1-y/ph)%2 {
+		xi, yi = pw-1-xi, ph-1-yi
+	}
+	return xs + xi, ys + yi
+}
+
+func newRotateMapper(chain, parallel int, param string, _ []interface{}) (PixelMapper, error) {
+	a, err := strconv.Atoi(param)
+	if err != nil || 0 != a%90 {
+		return nil, fmt.Errorf("angle must be a multiple of 90, have %q", param)
+	}
+	return &rotateMapper{angle: (a%360 + 360) % 360}, nil
+}
+
+// Visible swaps the sides for quarter turns
+func (rm *rotateMapper) Visible(w, h int) (int, int, error) {
+	if 0 == rm.angle%180 {
+		return w, h, nil
+	}
+	return h, w, nil
+}
+
+// Map rotates clockwise
+func (rm *rotateMapper) Map(w, h, x, y int) (int, int) {
+	return rotatePoint(rm.angle, w, h, x, y)
+}
+
+func rotatePoint(angle, w, h, x, y int) (int, int) {
+	switch angle {
+	case 90:
+		return w - y - 1, x
+	case 180:
+		return w - x - 1, h - y - 1
+	case 270:
+		return y, h - x - 1
+	}
+	return x, y
+}
+
+func newMirrorMapper(chain, parallel int, param string, _ []interface{}) (PixelMapper, error) {
+	switch strings.ToUpper(param) {
+	case `H`:
+		return &mirrorMapper{horizontal: true}, nil
+	case `V`:
+		return &mirrorMapper{horizontal: false}, nil
+	}
+	return nil, fmt.Errorf("direction must be H or V, have %q", param)
+}
+
+// Visible unchanged
+func (mm *mirrorMapper) Visible(w, h int) (int, int, error) {
+	return w, h, nil
+}
+
+// Map flips about the axis
+func (mm *mirrorMapper) Map(w, h, x, y int) (int, int) {
+	if mm.horizontal {
+		return w - x - 1, y
+	}
+	return x, h - y - 1
+}
+
+func newSerpentineMapper(chain, parallel int, param string, _ []interface{}) (PixelMapper, error) {
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 1 || 0 != chain%n {
+		return nil, fmt.Errorf("panels per row must divide the chain of %d, have %q", chain, param)
+	}
+	return &serpentineMapper{chain: chain, parallel: parallel, n: n}, nil
+}
+
+// Visible rows of n panels, parallel chains stacked
+func (sm *serpentineMapper) Visible(w, h int) (int, int, error) {
+	pw, ph := w/sm.chain, h/sm.parallel
+	return sm.n * pw, sm.parallel * (sm.chain / sm.n) * ph, nil
+}
+
+// Map snakes through the rows
+func (sm *serpentineMapper) Map(w, h, x, y int) (int, int) {
+	pw, ph := w/sm.chain, h/sm.parallel
+	rows := sm.chain / sm.n
+	p := y / (rows * ph)
+	y %= rows * ph
+	row, col := y/ph, x/pw
+	xi, yi := x%pw, y%ph
+	if 1 == row%2 {
+		col = sm.n - 1 - col
+		xi, yi = pw-1-xi, ph-1-yi
+	}
+	return (row*sm.n+col)*pw + xi, p*ph + yi
+}
+
+func newPanelMapper(chain, parallel int, param string, conf []interface{}) (PixelMapper, error) {
+	key := `RGB.panels`
+	if `` != param {
+		return nil, fmt.Errorf("takes no parameter, the table is %s", key)
+	}
+	if len(conf) != chain*parallel {
+		return nil, fmt.Errorf("%s: %d panels listed, chain has %d", key, len(conf), chain*parallel)
+	}
+	pm := &panelMapper{chain: chain, parallel: parallel}
+	for i, c := range conf {
+		spec, err := cast.ToStringMapE(c)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %v", key, i, err)
+		}
+		var po panelOffset
+		for k, v := range spec {
+			switch strings.ToLower(k) {
+			case `at`:
+				var at []int
+				at, err = cast.ToIntSliceE(v)
+				if nil == err && 2 != len(at) {
+					err = fmt.Errorf("at expects [x, y]")
+				}
+				if nil == err {
+					po.at = image.Pt(at[0], at[1])
+				}
+			case `rotate`:
+				po.rotate, err = cast.ToIntE(v)
+				if nil == err && 0 != po.rotate%90 {
+					err = fmt.Errorf("rotate must be a multiple of 90")
+				}
+				po.rotate = (po.rotate%360 + 360) % 360
+			default:
+				err = fmt.Errorf("unknown attribute %q", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %v", key, i, err)
+			}
+		}
+		pm.panels = append(pm.panels, po)
+	}
+	return pm, nil
+}
+
+// panel returns the visible rect of panel i
+func (pm *panelMapper) panel(i, pw, ph int) image.Rectangle {
+	po := pm.panels[i]
+	if 0 != po.rotate%180 {
+		pw, ph = ph, pw
+	}
+	return image.Rect(po.at.X, po.at.Y, po.at.X+pw, po.at.Y+ph)
+}
+
+// Visible bounds all the panels
+func (pm *panelMapper) Visible(w, h int) (int, int, error) {
+	pw, ph := w/pm.chain, h/pm.parallel
+	var vr image.Rectangle
+	for i := range pm.panels {
+		vr = vr.Union(pm.panel(i, pw, ph))
+	}
+	return vr.Max.X, vr.Max.Y, nil
+}
+
+// Map finds the panel showing x, y
+func (pm *panelMapper) Map(w, h, x, y int) (int, int) {
+	pw, ph := w/pm.chain, h/pm.parallel
+	pt := image.Pt(x, y)
+	for i, po := range pm.panels {
+		r := pm.panel(i, pw, ph)
+		if !pt.In(r) {
+			continue
+		}
+		px, py := rotatePoint(po.rotate, pw, ph, x-r.Min.X, y-r.Min.Y)
+		return (i%pm.chain)*pw + px, (i/pm.chain)*ph + py
+	}
+	return -1, -1
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testCanvas an opaque w x h canvas of distinct pixels
+func testCanvas(w, h int) *image.RGBA {
+	im := image.NewRGBA(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewSource(1))
+	for i := range im.Pix {
+		im.Pix[i] = uint8(r.Intn(256))
+		if 3 == i%4 {
+			im.Pix[i] = 255
+		}
+	}
+	return im
+}
+
+// TestMapperFold the default U-mapper;Rotate:180 against the original fold
+// of the 128x128 face onto a chain of four 64x64 panels
+func TestMapperFold(t *testing.T) {
+
+	canvas := testCanvas(128, 128)
+
+	// as the render loop folded before mappers
+	want := image.NewRGBA(image.Rect(0, 0, 256, 64))
+	itmp := imaging.Rotate180(canvas)
+	dst := imaging.New(4*64, 64, color.NRGBA{0, 0, 0, 0})
+	dst = imaging.Paste(dst, canvas, image.Pt(0, 0))
+	dst = imaging.Paste(dst, itmp, image.Pt(128, 0))
+	draw.Draw(want, dst.Bounds(), dst, image.ZP, draw.Over)
+
+	pmap, err := NewPanelMapping(`U-mapper;Rotate:180`, 4, 1, 256, 64, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 128 != pmap.Width || 128 != pmap.Height {
+		t.Fatalf("visible %dx%d, want 128x128", pmap.Width, pmap.Height)
+	}
+	got := image.NewRGBA(pmap.Bounds())
+	pmap.Apply(got, canvas)
+
+	for i := range want.Pix {
+		if want.Pix[i] != got.Pix[i] {
+			p := i / 4
+			t.Fatalf("matrix pixel %d,%d differs from the fold", p%256, p/256)
+		}
+	}
+
+}
+
+func TestMapperChains(t *testing.T) {
+
+	panels := []interface{}{
+		map[string]interface{}{`at`: []int{0, 64}, `rotate`: 180},
+		map[string]interface{}{`at`: []int{0, 0}},
+	}
+
+	for _, tc := range []struct {
+		spec            string
+		chain, parallel int
+		mw, mh          int
+		w, h            int
+		// a visible pixel and the matrix pixel it lands on
+		from, to image.Point
+	}{
+		{`U-mapper`, 4, 1, 256, 64, 128, 128, image.Pt(0, 0), image.Pt(128, 0)},
+		{`U-mapper`, 4, 1, 256, 64, 128, 128, image.Pt(0, 64), image.Pt(127, 63)},
+		{`V-mapper`, 2, 1, 128, 64, 64, 128, image.Pt(0, 64), image.Pt(64, 0)},
+		{`V-mapper:Z`, 2, 1, 128, 64, 64, 128, image.Pt(0, 0), image.Pt(63, 63)},
+		{`Rotate:90`, 1, 1, 64, 32, 32, 64, image.Pt(0, 0), image.Pt(63, 0)},
+		{`Rotate:270`, 1, 1, 64, 32, 32, 64, image.Pt(0, 0), image.Pt(0, 31)},
+		{`Mirror:H`, 1, 1, 64, 32, 64, 32, image.Pt(0, 5), image.Pt(63, 5)},
+		{`Mirror:V`, 1, 1, 64, 32, 64, 32, image.Pt(3, 0), image.Pt(3, 31)},
+		{`Serpentine:2`, 4, 1, 256, 64, 128, 128, image.Pt(0, 64), image.Pt(255, 63)},
+		{`Serpentine:2`, 4, 1, 256, 64, 128, 128, image.Pt(64, 0), image.Pt(64, 0)},
+		{`Panels`, 2, 1, 128, 64, 64, 128, image.Pt(0, 0), image.Pt(64, 0)},
+		{`Panels`, 2, 1, 128, 64, 64, 128, image.Pt(0, 64), image.Pt(63, 63)},
+	} {
+		pmap, err := NewPanelMapping(tc.spec, tc.chain, tc.parallel, tc.mw, tc.mh, panels)
+		if nil != err {
+			t.Errorf("%s: %v", tc.spec, err)
+			continue
+		}
+		if tc.w != pmap.Width || tc.h != pmap.Height {
+			t.Errorf("%s: visible %dx%d, want %dx%d", tc.spec, pmap.Width, pmap.Height, tc.w, tc.h)
+			continue
+		}
+		o := pmap.lut[tc.from.Y*pmap.Width+tc.from.X]
+		if want := 4 * (tc.to.Y*tc.mw + tc.to.X); want != o {
+			t.Errorf("%s: %v lands at %d,%d, want %v", tc.spec, tc.from, o/4%tc.mw, o/4/tc.mw, tc.to)
+		}
+	}
+
+}
+
+func TestMapperRejects(t *testing.T) {
+	for _, tc := range []struct {
+		spec            string
+		chain, parallel int
+	}{
+		{`Sideways`, 4, 1},
+		{`U-mapper`, 3, 1},
+		{`U-mapper`, 4, 3},
+		{`Rotate:45`, 4, 1},
+		{`Mirror:X`, 4, 1},
+		{`Serpentine:3`, 4, 1},
+		{`Panels`, 2, 1},
+		{`Panels:RGB.panels`, 2, 1},
+	} {
+		if _, err := NewPanelMapping(tc.spec, tc.chain, tc.parallel, 64*tc.chain, 64*tc.parallel, nil); nil == err {
+			t.Errorf("%s on a %dx%d chain accepted", tc.spec, tc.chain, tc.parallel)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	svg "github.com/ajstarks/svgo"
 	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
 	"github.com/mellena1/mbta-v3-go/mbta"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
@@ -83,7 +84,7 @@ func cleanSplit(s string) []string {
 // Start updates
 func (m *MBTA) Start() {
 	if !m.active {
-		m.update = sched(m.getPredicted, 30*time.Second)
+		m.update = sched(m.getPredicted, wallDelay(30*time.Second))
 		m.active = true
 	}
 }
@@ -119,135 +120,152 @@ func (m *MBTA) indexOf(pp RGBPrediction) int {
 
 func (m *MBTA) getPredicted() {
 
-	day := int(time.Now().Weekday())
-	check, _ := parseTime(time.Now().Format("03:04 PM"))
-	if check.After(m.from) && check.Before(m.until) && intInSlice(m.days, day) {
-		m.Display = true
-	} else {
-		m.Display = false
+	now := timeNow()
+	if !m.inWindow(now) {
 		return
 	}
 
-	pred, _, err := m.mc.Predictions.GetAllPredictions(
-		&mbta.GetAllPredictionsRequestConfig{
-			Sort:           mbta.PredictionsSortByArrivalTimeAscending,
-			Include:        []mbta.PredictionInclude{"stop", "route"},
-			FilterRouteIDs: m.route,
-			FilterStopIDs:  m.stop,
-		})
+	var pred []*mbta.Prediction
+	var err error
+	if nil != fixtures {
+		var b []byte
+		if b, err = fixtures.Payload(`mbta.json`, now); nil == err {
+			pred, err = decodePredictions(b)
+		}
+	} else {
+		pred, _, err = m.mc.Predictions.GetAllPredictions(
+			&mbta.GetAllPredictionsRequestConfig{
+				Sort:           mbta.PredictionsSortByArrivalTimeAscending,
+				Include:        []mbta.PredictionInclude{"stop", "route"},
+				FilterRouteIDs: m.route,
+				FilterStopIDs:  m.stop,
+			})
+	}
+	mMBTAFetch.With(outcome(err)).Inc()
 
 	if nil == err {
+		health.OK(`mbta`)
+		m.applyPredictions(pred, now)
+	} else {
+		// the last predictions stay, counting down, until stale
+		health.Fail(sourceError(`mbta`, `predictions`, err))
+	}
 
-		// reset
-		for i := range m.prediction {
-			m.prediction[i].changed = -1
-			m.prediction[i].countdown = ``
-			m.prediction[i].stack = 0
-		}
-		testOff := time.Now().Add(m.offset)
+}
 
-		for _, p := range pred {
+// inWindow sets Display for the active days and hours at now
+func (m *MBTA) inWindow(now time.Time) bool {
+	check, _ := parseTime(now.Format("03:04 PM"))
+	m.Display = check.After(m.from) && check.Before(m.until) && intInSlice(m.days, int(now.Weekday()))
+	return m.Display
+}
 
-			if p.ScheduleRelationship != string(mbta.ScheduleRelationshipSkipped) {
+// applyPredictions merges the predictions due within the offset of now
+// and redraws their lines
+func (m *MBTA) applyPredictions(pred []*mbta.Prediction, now time.Time) {
 
-				tt := p.ArrivalTime
-				if "" == tt {
-					tt = p.DepartureTime
-				}
-				testArr, err := time.Parse(time.RFC3339, tt)
-				if nil == err && testArr.Before(testOff) && testArr.After(time.Now()) {
+	// reset
+	for i := range m.prediction {
+		m.prediction[i].changed = -1
+		m.prediction[i].countdown = ``
+		m.prediction[i].stack = 0
+	}
+	testOff := now.Add(m.offset)
 
-					if "" == p.DepartureTime {
-						p.DepartureTime = p.ArrivalTime
-					}
-					testDep, err := time.Parse(time.RFC3339, p.DepartureTime)
-					if nil != err {
-						testDep = testArr
-					}
+	for _, p := range pred {
 
-					pn := p.Stop.Name
-					if nil != p.Stop.PlatformName {
-						pn += `.` + *p.Stop.PlatformName
-					}
+		if nil == p.ScheduleRelationship || *p.ScheduleRelationship != mbta.ScheduleRelationshipSkipped {
 
-					pp := RGBPrediction{
-						routeType:     p.Route.Description,
-						stopName:      pn,
-						arrivalTime:   testArr,
-						departureTime: testDep,
-						direction:     p.Route.DirectionNames[p.DirectionID],
-						routeID:       m.fixRoute(p.Route.ID, p.Route.Description),
-						stopID:        p.Stop.ID,
-						changed:       0,
-						countdown:     m.countdown(testArr),
-						stack:         0,
-						slice:         image.NewRGBA(image.Rect(0, 0, 1, 1)),
-						canvas:        image.NewRGBA(image.Rect(0, 0, 1, 1)),
-						color:         `#` + p.Route.Color,
-					}
-					idx := m.indexOf(pp)
-					if -1 != idx {
-						if m.prediction[idx].changed == -1 {
-							m.prediction[idx].arrivalTime = pp.arrivalTime
-							m.prediction[idx].departureTime = pp.departureTime
-							m.prediction[idx].countdown = pp.countdown
-							m.prediction[idx].changed = 1
-						} else {
-							m.prediction[idx].stack++
-						}
+			tt := p.ArrivalTime
+			if nil == tt {
+				tt = p.DepartureTime
+			}
+			if nil == tt {
+				continue
+			}
+			testArr := tt.Time
+			if testArr.Before(testOff) && testArr.After(now) {
+
+				testDep := testArr
+				if nil != p.DepartureTime {
+					testDep = p.DepartureTime.Time
+				}
+
+				pn := p.Stop.Name
+				if nil != p.Stop.PlatformName {
+					pn += `.` + *p.Stop.PlatformName
+				}
+
+				pp := RGBPrediction{
+					routeType:     p.Route.Description,
+					stopName:      pn,
+					arrivalTime:   testArr,
+					departureTime: testDep,
+					direction:     p.Route.DirectionNames[p.DirectionID],
+					routeID:       m.fixRoute(p.Route.ID, p.Route.Description),
+					stopID:        p.Stop.ID,
+					changed:       0,
+					countdown:     m.countdown(now, testArr),
+					stack:         0,
+					slice:         image.NewRGBA(image.Rect(0, 0, 1, 1)),
+					canvas:        image.NewRGBA(image.Rect(0, 0, 1, 1)),
+					color:         `#` + p.Route.Color,
+				}
+				idx := m.indexOf(pp)
+				if -1 != idx {
+					if m.prediction[idx].changed == -1 {
+						m.prediction[idx].arrivalTime = pp.arrivalTime
+						m.prediction[idx].departureTime = pp.departureTime
+						m.prediction[idx].countdown = pp.countdown
+						m.prediction[idx].changed = 1
 					} else {
-						m.prediction = append(m.prediction, pp)
+						m.prediction[idx].stack++
 					}
 				} else {
-					if nil != err {
-						fmt.Println(err)
-						fmt.Println(p)
-					}
+					m.prediction = append(m.prediction, pp)
 				}
 			}
 		}
-		// update the graphic
-		m.mux.Lock()
-		for i, this := range m.prediction {
-			if -1 != this.changed {
+	}
+	// update the graphic
+	m.mux.Lock()
+	for i, this := range m.prediction {
+		if -1 != this.changed {
 
-				m.prediction[i].changed = 1
+			m.prediction[i].changed = 1
 
-				// init icon as needed
-				if nil == m.prediction[i].icon.image {
-					m.prediction[i].icon, _ = m.vehicleImage(this.routeType, this.color)
-				}
+			// init icon as needed
+			if nil == m.prediction[i].icon.image {
+				m.prediction[i].icon, _ = m.vehicleImage(this.routeType, this.color)
+			}
 
-				m.prediction[i].canvas = image.NewRGBA(image.Rect(0, 0, 128, 18))
+			m.prediction[i].canvas = image.NewRGBA(image.Rect(0, 0, 128, 18))
 
-				dst := imaging.Resize(m.prediction[i].icon.image, 18, 18, imaging.Lanczos)
-				draw.Draw(m.prediction[i].canvas, m.prediction[i].canvas.Bounds(), dst, image.Pt(0, 1), draw.Over)
+			dst := imaging.Resize(m.prediction[i].icon.image, 18, 18, imaging.Lanczos)
+			draw.Draw(m.prediction[i].canvas, m.prediction[i].canvas.Bounds(), dst, image.Pt(0, 1), draw.Over)
 
-				mx := m.face.Metrics()
-				yy := 2 + int(float64(mx.Ascent>>6)-float64(mx.Descent>>6))
-				point := fixed.Point26_6{fixed.Int26_6(19 * 64), fixed.Int26_6(yy * 64)}
+			mx := m.face.Metrics()
+			yy := 2 + int(float64(mx.Ascent>>6)-float64(mx.Descent>>6))
+			point := fixed.Point26_6{fixed.Int26_6(19 * 64), fixed.Int26_6(yy * 64)}
 
-				d := &font.Drawer{
-					Dst:  m.prediction[i].canvas,
-					Src:  image.NewUniform(parseHexColor(this.color)),
-					Face: m.face,
-					Dot:  point,
-				}
-				others := ``
-				if this.stack > 0 {
-					others = fmt.Sprintf(" +%d", this.stack)
-				}
-				d.DrawString(fmt.Sprintf("%v %v %v%v", this.routeID, this.direction, this.countdown, others))
-				point = fixed.Point26_6{fixed.Int26_6(19 * 64), fixed.Int26_6((yy + 7) * 64)}
-				d.Dot = point
-				d.Src = image.NewUniform(image.White)
-				d.DrawString(fmt.Sprintf("%v", this.stopName))
+			d := &font.Drawer{
+				Dst:  m.prediction[i].canvas,
+				Src:  image.NewUniform(parseHexColor(this.color)),
+				Face: m.face,
+				Dot:  point,
+			}
+			others := ``
+			if this.stack > 0 {
+				others = fmt.Sprintf(" +%d", this.stack)
 			}
+			d.DrawString(fmt.Sprintf("%v %v %v%v", this.routeID, this.direction, this.countdown, others))
+			point = fixed.Point26_6{fixed.Int26_6(19 * 64), fixed.Int26_6((yy + 7) * 64)}
+			d.Dot = point
+			d.Src = image.NewUniform(image.White)
+			d.DrawString(fmt.Sprintf("%v", this.stopName))
 		}
-		m.mux.Unlock()
-	} else {
-		fmt.Println(err)
 	}
+	m.mux.Unlock()
 
 }
 
@@ -258,8 +276,8 @@ func (m *MBTA) fixRoute(route, desc string) string {
 	return route
 }
 
-func (m *MBTA) countdown(t time.Time) string {
-	ret := nextEvent(time.Now(), t)[3:]
+func (m *MBTA) countdown(now, t time.Time) string {
+	ret := nextEvent(now, t)[3:]
 	if strings.Contains(ret, `m`) {
 		ret = strings.Split(ret, `m`)[0]
 		if `1` == ret {
@@ -403,3 +421,94 @@ func (m *MBTA) vehicleImage(routeType, color string) (ic iconCache, err error) {
 	return ic, nil
 
 }
+
+// mbtaWidget lists the top predictions in the lower zone
+type mbtaWidget struct {
+	m    *MBTA
+	face font.Face
+	lw   float64
+}
+
+// Widget adapts the predictions, face for the waiting notice and lw the
+// frame line width
+func (m *MBTA) Widget(face font.Face, lw float64) Widget {
+	return &mbtaWidget{m: m, face: face, lw: lw}
+}
+
+// Start updates
+func (mw *mbtaWidget) Start() {
+	mw.m.Start()
+}
+
+// Stop updates
+func (mw *mbtaWidget) Stop() {
+	mw.m.Stop()
+}
+
+// PreferredSize fills the region
+func (mw *mbtaWidget) PreferredSize() image.Point {
+	return image.ZP
+}
+
+// Dirty always, countdowns tick
+func (mw *mbtaWidget) Dirty() bool {
+	return true
+}
+
+// Visible within the active window
+func (mw *mbtaWidget) Visible() bool {
+	return mw.m.Display
+}
+
+// Render the predictions, top 3
+func (mw *mbtaWidget) Render(dc *gg.Context, rg *Region) {
+	tx, _ := rg.Center()
+	noData := true
+	pos := rg.Rect.Min.Y + 14
+	for pred := range mw.m.Predictions() {
+		dc.DrawImageAnchored(pred, int(tx), pos, 0.5, 0.5)
+		pos += 16
+		noData = false
+	}
+	if noData {
+		dc.SetHexColor("#ff9900cc")
+		dc.SetFontFace(mw.face)
+		dc.DrawStringAnchored(`WAITING`, tx, float64(pos+3), 0.5, 0.5)
+		dc.DrawStringAnchored(`FOR MBTA`, tx, float64(pos+19), 0.5, 0.5)
+	} else if health.Stale(`mbta`) {
+		staleMark(dc, rg.Rect)
+	}
+	placeBorderZone(dc, mw.lw, rg.Rect, H-rg.Rect.Min.Y-2)
+}
+
+// PredictionStatus a current prediction for status reporting
+type PredictionStatus struct {
+	Route     string    `json:"route"`
+	Stop      string    `json:"stop"`
+	Direction string    `json:"direction"`
+	Arrival   time.Time `json:"arrival"`
+	Departure time.Time `json:"departure"`
+	Countdown string    `json:"countdown"`
+}
+
+// Status returns the active predictions
+func (m *MBTA) Status() []PredictionStatus {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ps := []PredictionStatus{}
+	for i := range m.prediction {
+		p := &m.prediction[i]
+		if 1 != p.changed {
+			continue
+		}
+		ps = append(ps, PredictionStatus{
+			Route:     p.routeID,
+			Stop:      p.stopName,
+			Direction: p.direction,
+			Arrival:   p.arrivalTime,
+			Departure: p.departureTime,
+			Countdown: p.countdown,
+		})
+	}
+	return ps
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Counter monotonically increasing count
+	Counter struct {
+		v uint64
+	}
+
+	// CounterVec counters by one label value
+	CounterVec struct {
+		label  string
+		values map[string]*Counter
+		mux    sync.Mutex
+	}
+
+	// Histogram observations into cumulative buckets
+	Histogram struct {
+		buckets []float64
+		counts  []uint64
+		count   uint64
+		sum     float64
+		mux     sync.Mutex
+	}
+
+	// metric a named series in the exposition
+	metric struct {
+		name  string
+		help  string
+		kind  string
+		write func(io.Writer, string)
+	}
+
+	// MetricsRegistry renders the Prometheus text exposition format
+	MetricsRegistry struct {
+		metrics []*metric
+		mux     sync.Mutex
+	}
+)
+
+// latencyBuckets seconds, frame times through to slow RPCs
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var metrics = NewMetricsRegistry()
+
+// data source instrumentation, registered at start up
+var (
+	mFrameSeconds  = metrics.Histogram(`rgbclock_frame_seconds`, `Frame render time, compose to display.`, latencyBuckets)
+	mSSEEvents     = metrics.Counter(`rgbclock_sse_events_total`, `SSE visualizer events received.`)
+	mSSEMalformed  = metrics.Counter(`rgbclock_sse_malformed_total`, `SSE events dropped as unreadable or malformed JSON.`)
+	mLMSRPCSeconds = metrics.Histogram(`rgbclock_lms_rpc_seconds`, `LMS JSON RPC request latency.`, latencyBuckets)
+	mLMSRPCErrors  = metrics.Counter(`rgbclock_lms_rpc_errors_total`, `LMS JSON RPC requests failed.`)
+	mLMSCLIEvents  = metrics.Counter(`rgbclock_lms_cli_events_total`, `LMS CLI notifications received.`)
+	mCacheHits     = metrics.Counter(`rgbclock_cache_hits_total`, `Cover art cache hits.`)
+	mCacheMisses   = metrics.Counter(`rgbclock_cache_misses_total`, `Cover art cache misses.`)
+	mWeatherFetch  = metrics.CounterVec(`rgbclock_weather_fetch_total`, `Weather fetches by result.`, `result`)
+	mMBTAFetch     = metrics.CounterVec(`rgbclock_mbta_fetch_total`, `MBTA prediction fetches by result.`, `result`)
+	mNewsFetch     = metrics.CounterVec(`rgbclock_news_fetch_total`, `News feed fetches by result.`, `result`)
+)
+
+// weatherFetched time of the last good weather payload
+var weatherFetched int64
+
+// NewMetricsRegistry creates an empty registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{}
+}
+
+func (mr *MetricsRegistry) register(m *metric) {
+	mr.mux.Lock()
+	mr.metrics = append(mr.metrics, m)
+	mr.mux.Unlock()
+}
+
+// Counter registers a counter
+func (mr *MetricsRegistry) Counter(name, help string) *Counter {
+	c := &Counter{}
+	mr.register(&metric{name: name, help: help, kind: `counter`, write: func(w io.Writer, n string) {
+		fmt.Fprintf(w, "%s %d\n", n, c.Value())
+	}})
+	return c
+}
+
+// CounterVec registers a counter labelled by one dimension
+func (mr *MetricsRegistry) CounterVec(name, help, label string) *CounterVec {
+	cv := &CounterVec{label: label, values: make(map[string]*Counter)}
+	mr.register(&metric{name: name, help: help, kind: `counter`, write: cv.write})
+	return cv
+}
+
+// Histogram registers a histogram with the given upper bounds
+func (mr *MetricsRegistry) Histogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	mr.register(&metric{name: name, help: help, kind: `histogram`, write: h.write})
+	return h
+}
+
+// GaugeFunc registers a gauge sampled at scrape time
+func (mr *MetricsRegistry) GaugeFunc(name, help string, f func() float64) {
+	mr.register(&metric{name: name, help: help, kind: `gauge`, write: func(w io.Writer, n string) {
+		fmt.Fprintf(w, "%s %s\n", n, formatFloat(f()))
+	}})
+}
+
+// CounterFunc registers a counter sampled at scrape time
+func (mr *MetricsRegistry) CounterFunc(name, help string, f func() float64) {
+	mr.register(&metric{name: name, help: help, kind: `counter`, write: func(w io.Writer, n string) {
+		fmt.Fprintf(w, "%s %s\n", n, formatFloat(f()))
+	}})
+}
+
+// Write renders every metric, text format 0.0.4
+func (mr *MetricsRegistry) Write(w io.Writer) {
+	mr.mux.Lock()
+	ms := append([]*metric(nil), mr.metrics...)
+	mr.mux.Unlock()
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].name < ms[j].name })
+	for _, m := range ms {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		m.write(w, m.name)
+	}
+}
+
+// ServeHTTP serves the exposition, mount on /metrics
+func (mr *MetricsRegistry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set(`Content-Type`, `text/plain; version=0.0.4; charset=utf-8`)
+	mr.Write(rw)
+}
+
+// MetricsServer serves /metrics alone, config metrics.listen
+type MetricsServer struct {
+	server *http.Server
+}
+
+// NewMetricsServer creates the server for mr on listen
+func NewMetricsServer(listen string, mr *MetricsRegistry) *MetricsServer {
+	mux := http.NewServeMux()
+	mux.Handle(`/metrics`, mr)
+	return &MetricsServer{server: &http.Server{
+		Addr:         listen,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}}
+}
+
+// Start serves in the background
+func (ms *MetricsServer) Start() {
+	go func() {
+		err := ms.server.ListenAndServe()
+		if nil != err && http.ErrServerClosed != err {
+			logAPI.Error(`metrics serve failed`, `listen`, ms.server.Addr, `err`, err)
+		}
+	}()
+}
+
+// Close stops the server
+func (ms *MetricsServer) Close() error {
+	return ms.server.Close()
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Value returns the count
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// With returns the counter for the label value
+func (cv *CounterVec) With(value string) *Counter {
+	cv.mux.Lock()
+	defer cv.mux.Unlock()
+	c, ok := cv.values[value]
+	if !ok {
+		c = &Counter{}
+		cv.values[value] = c
+	}
+	return c
+}
+
+func (cv *CounterVec) write(w io.Writer, name string) {
+	cv.mux.Lock()
+	defer cv.mux.Unlock()
+	values := make([]string, 0, len(cv.values))
+	for v := range cv.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, cv.label, v, cv.values[v].Value())
+	}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	h.mux.Lock()
+	for i, ub := range h.buckets {
+		if v <= ub {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+	h.mux.Unlock()
+}
+
+// Since records the seconds elapsed from start
+func (h *Histogram) Since(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	var cum uint64
+	for i, ub := range h.buckets {
+		cum += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatFloat(ub), cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// outcome labels a fetch result
+func outcome(err error) string {
+	if nil != err {
+		return `error`
+	}
+	return `ok`
+}
+
+// formatFloat shortest form, Inf and NaN spelt as Prometheus expects
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// registerMetrics adds the render loop and weather samples to mr, stats
+// reads the compositor in use, it is replaced on reload
+func registerMetrics(mr *MetricsRegistry, stats func() FrameStats) {
+	started := timeNow().Unix()
+	mr.CounterFunc(`rgbclock_frames_total`, `Frames paced.`, func() float64 {
+		return float64(stats().Frames)
+	})
+	mr.CounterFunc(`rgbclock_frames_composed_total`, `Frames with changes pushed to the display.`, func() float64 {
+		return float64(stats().Composed)
+	})
+	mr.CounterFunc(`rgbclock_frame_overruns_total`, `Frames exceeding the frame interval.`, func() float64 {
+		return float64(stats().Overruns)
+	})
+	mr.GaugeFunc(`rgbclock_fps`, `Frames per second, smoothed.`, func() float64 {
+		return stats().FPS
+	})
+	mr.GaugeFunc(`rgbclock_weather_age_seconds`, `Seconds since the last good weather payload, since start when none.`, func() float64 {
+		t := atomic.LoadInt64(&weatherFetched)
+		if 0 == t {
+			t = started
+		}
+		return float64(timeNow().Unix() - t)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape GETs /metrics from mr on its own listener, sample lines and the
+// TYPE of each series
+func scrape(t *testing.T, mr *MetricsRegistry) ([]string, map[string]string) {
+
+	t.Helper()
+
+	srv := httptest.NewServer(NewMetricsServer(``, mr).server.Handler)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + `/metrics`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get(`Content-Type`); !strings.HasPrefix(ct, `text/plain; version=0.0.4`) {
+		t.Errorf("content type %q", ct)
+	}
+
+	var samples []string
+	kinds := make(map[string]string)
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if f := strings.Fields(line); 4 == len(f) && `#` == f[0] && `TYPE` == f[1] {
+			kinds[f[2]] = f[3]
+		} else if !strings.HasPrefix(line, `#`) {
+			samples = append(samples, line)
+		}
+	}
+	return samples, kinds
+
+}
+
+func TestMetricsScrape(t *testing.T) {
+
+	mr := NewMetricsRegistry()
+	registerMetrics(mr, func() FrameStats {
+		return FrameStats{Frames: 160, Composed: 40, Overruns: 2, FPS: 15.5}
+	})
+	frames := mr.Histogram(`rgbclock_frame_seconds`, `Frame render time, compose to display.`, latencyBuckets)
+	frames.Observe(.003)
+	frames.Observe(.2)
+	fetches := mr.CounterVec(`rgbclock_weather_fetch_total`, `Weather fetches by result.`, `result`)
+	fetches.With(outcome(nil)).Inc()
+	fetches.With(outcome(errors.New(`timeout`))).Inc()
+	fetches.With(outcome(errors.New(`refused`))).Inc()
+
+	samples, kinds := scrape(t, mr)
+	for name, kind := range map[string]string{
+		`rgbclock_frames_total`:          `counter`,
+		`rgbclock_frames_composed_total`: `counter`,
+		`rgbclock_frame_overruns_total`:  `counter`,
+		`rgbclock_fps`:                   `gauge`,
+		`rgbclock_weather_age_seconds`:   `gauge`,
+		`rgbclock_frame_seconds`:         `histogram`,
+		`rgbclock_weather_fetch_total`:   `counter`,
+	} {
+		if kind != kinds[name] {
+			t.Errorf("%s is a %q, want %s", name, kinds[name], kind)
+		}
+	}
+	for _, want := range []string{
+		`rgbclock_frames_total 160`,
+		`rgbclock_frames_composed_total 40`,
+		`rgbclock_frame_overruns_total 2`,
+		`rgbclock_fps 15.5`,
+		`rgbclock_frame_seconds_bucket{le="0.001"} 0`,
+		`rgbclock_frame_seconds_bucket{le="0.005"} 1`,
+		`rgbclock_frame_seconds_bucket{le="0.25"} 2`,
+		`rgbclock_frame_seconds_bucket{le="+Inf"} 2`,
+		`rgbclock_frame_seconds_sum 0.203`,
+		`rgbclock_frame_seconds_count 2`,
+		`rgbclock_weather_fetch_total{result="error"} 2`,
+		`rgbclock_weather_fetch_total{result="ok"} 1`,
+	} {
+		found := false
+		for _, s := range samples {
+			found = found || want == s
+		}
+		if !found {
+			t.Errorf("no %q in\n%s", want, strings.Join(samples, "\n"))
+		}
+	}
+
+}
+
+// TestMetricsSources the source error series are registered once at
+// start up under their documented names
+func TestMetricsSources(t *testing.T) {
+
+	_, kinds := scrape(t, metrics)
+	for _, name := range []string{
+		`rgbclock_frame_seconds`,
+		`rgbclock_lms_rpc_seconds`,
+		`rgbclock_lms_rpc_errors_total`,
+		`rgbclock_sse_malformed_total`,
+		`rgbclock_weather_fetch_total`,
+		`rgbclock_mbta_fetch_total`,
+		`rgbclock_news_fetch_total`,
+	} {
+		want := `counter`
+		if strings.HasSuffix(name, `_seconds`) {
+			want = `histogram`
+		}
+		if want != kinds[name] {
+			t.Errorf("%s is a %q, want %s", name, kinds[name], want)
+		}
+	}
+
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	svg "github.com/ajstarks/svgo"
+	"github.com/fogleman/gg"
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
 )
@@ -546,3 +547,55 @@ func (m *Moon) PhaseIcon(sw, sh int) (img draw.Image, err error) {
 	return img, nil
 
 }
+
+// moonWidget renders the phase icon for the configured location, the
+// phase is recalculated once a minute
+type moonWidget struct {
+	icon draw.Image
+	at   time.Time
+	size int
+}
+
+// NewMoonWidget creates the moon phase widget
+func NewMoonWidget() Widget {
+	return &moonWidget{}
+}
+
+// Start does nothing
+func (mw *moonWidget) Start() {}
+
+// Stop does nothing
+func (mw *moonWidget) Stop() {}
+
+// PreferredSize is the last rendered icon size
+func (mw *moonWidget) PreferredSize() image.Point {
+	return image.Pt(mw.size, mw.size)
+}
+
+// Dirty on the minute
+func (mw *moonWidget) Dirty() bool {
+	return nil == mw.icon || !mw.at.Equal(frameTime().Truncate(time.Minute))
+}
+
+// Visible always
+func (mw *moonWidget) Visible() bool {
+	return true
+}
+
+// Render places the icon, square to the smaller region side
+func (mw *moonWidget) Render(dst *gg.Context, rg *Region) {
+	mx := rg.Rect.Dx()
+	if rg.Rect.Dy() < mx {
+		mx = rg.Rect.Dy()
+	}
+	if mw.Dirty() || mx != mw.size {
+		mw.at = frameTime().Truncate(time.Minute)
+		mw.size = mx
+		icon, err := NewLuna(mw.at, lat, lng).PhaseIcon(mx, mx)
+		if err != nil || nil == icon {
+			return
+		}
+		mw.icon = icon
+	}
+	rg.DrawImage(dst, mw.icon)
+}
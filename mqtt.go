@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type (
+	// MQTTConfig setup, config mqtt
+	MQTTConfig struct {
+		Broker   string // tcp://host:1883
+		ClientID string
+		Username string
+		Password string
+		Topic    string // prefix for all topics
+		QoS      byte
+		Retain   bool
+		Interval time.Duration
+	}
+
+	// MQTTClient publishes clock state under <topic>/ and accepts commands
+	// on <topic>/set/brightness, <topic>/set/scene, <topic>/set/lms/<command>
+	// and <topic>/notify
+	MQTTClient struct {
+		client   mqtt.Client
+		broker   string
+		topic    string
+		qos      byte
+		retain   bool
+		interval time.Duration
+		scenes   *SceneScheduler
+		cpu      *CPUStat
+		notifier *Notifier
+		last     map[string]string
+		stop     chan bool
+		cancel   context.CancelFunc // ends the connect retries
+		mux      sync.Mutex
+		parts    sync.RWMutex // scenes and cpu swap on reload
+	}
+)
+
+// NewMQTTClient creates a client for the broker, Start connects
+func NewMQTTClient(mc MQTTConfig, scenes *SceneScheduler, cpu *CPUStat, notifier *Notifier) *MQTTClient {
+
+	if `` == mc.Topic {
+		mc.Topic = `rgbclock`
+	}
+	mc.Topic = strings.TrimSuffix(mc.Topic, `/`)
+	if `` == mc.ClientID {
+		mc.ClientID = `rgbclock`
+	}
+	if mc.Interval <= 0 {
+		mc.Interval = 5 * time.Second
+	}
+
+	mq := &MQTTClient{
+		broker:   mc.Broker,
+		topic:    mc.Topic,
+		qos:      mc.QoS,
+		retain:   mc.Retain,
+		interval: mc.Interval,
+		scenes:   scenes,
+		cpu:      cpu,
+		notifier: notifier,
+		last:     make(map[string]string),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(mc.Broker).
+		SetClientID(mc.ClientID).
+		SetUsername(mc.Username).
+		SetPassword(mc.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(5*time.Second).
+		SetWill(mq.topic+`/status`, `offline`, mc.QoS, true).
+		SetOnConnectHandler(mq.connected).
+		SetConnectionLostHandler(func(c mqtt.Client, err error) {
+			logMQTT.Warn(`connection lost`, `err`, err)
+		})
+	mq.client = mqtt.NewClient(opts)
+	return mq
+
+}
+
+// Start publishes state once per interval, nothing while disconnected,
+// and connects under the supervisor: a broker down at boot is retried
+// with its backoff, the client reconnects on its own after that
+func (mq *MQTTClient) Start() {
+	mq.stop = sched(mq.publishState, mq.interval)
+	var ctx context.Context
+	ctx, mq.cancel = context.WithCancel(supervisor.Context())
+	supervisor.GoContext(ctx, `mqtt`, mq.connect)
+}
+
+// connect makes the first connection, an error has the supervisor retry
+func (mq *MQTTClient) connect(ctx context.Context) error {
+	tok := mq.client.Connect()
+	if !tok.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("mqtt connect to %s timed out", mq.broker)
+	}
+	if err := tok.Error(); nil != err {
+		return err
+	}
+	if nil != ctx.Err() {
+		mq.client.Disconnect(250) // stopped meanwhile
+	}
+	return nil
+}
+
+// Stop publishes offline and disconnects
+func (mq *MQTTClient) Stop() {
+	if nil != mq.cancel {
+		mq.cancel()
+		mq.cancel = nil
+	}
+	if nil != mq.stop {
+		mq.stop <- true
+		mq.stop = nil
+	}
+	if mq.client.IsConnected() {
+		mq.client.Publish(mq.topic+`/status`, mq.qos, true, `offline`).WaitTimeout(time.Second)
+		mq.client.Disconnect(250)
+	}
+}
+
+// Attach switches to the modules of a reloaded clock
+func (mq *MQTTClient) Attach(scenes *SceneScheduler, cpu *CPUStat) {
+	mq.parts.Lock()
+	mq.scenes, mq.cpu = scenes, cpu
+	mq.parts.Unlock()
+}
+
+func (mq *MQTTClient) attached() (*SceneScheduler, *CPUStat) {
+	mq.parts.RLock()
+	defer mq.parts.RUnlock()
+	return mq.scenes, mq.cpu
+}
+
+// connected (re)subscribes and republishes everything
+func (mq *MQTTClient) connected(c mqtt.Client) {
+
+	mq.mux.Lock()
+	mq.last = make(map[string]string)
+	mq.mux.Unlock()
+
+	c.Publish(mq.topic+`/status`, mq.qos, true, `online`)
+	subs := map[string]mqtt.MessageHandler{
+		mq.topic + `/set/brightness`: mq.setBrightness,
+		mq.topic + `/set/scene`:      mq.setScene,
+		mq.topic + `/set/lms/+`:      mq.control,
+		mq.topic + `/notify`:         mq.notify,
+	}
+	for t, h := range subs {
+		if tok := c.Subscribe(t, mq.qos, h); tok.WaitTimeout(5*time.Second) && nil != tok.Error() {
+			logMQTT.Error(`subscribe failed`, `topic`, t, `err`, tok.Error())
+		}
+	}
+
+}
+
+// publishState sends the values changed since the last publish
+func (mq *MQTTClient) publishState() {
+
+	if !mq.client.IsConnected() {
+		return
+	}
+
+	scenes, cpu := mq.attached()
+	day := `night`
+	if dl, _ := currentDaylight(); dl.isdaylight {
+		day = `day`
+	}
+	state := map[string]string{
+		`daymode`:         day,
+		`brightness`:      strconv.Itoa(currentBrightness()),
+		`cpu/temperature`: fmt.Sprintf("%.1f", cpu.Status().Temperature),
+	}
+	if sc := scenes.Current(); nil != sc {
+		state[`scene`] = sc.Name
+	} else {
+		state[`scene`] = ``
+	}
+
+	lms, _, transit := currentSources()
+	if nil != lms {
+		ls := lms.Status()
+		state[`lms/mode`] = ls.Mode
+		state[`lms/track`] = mq.encode(map[string]string{
+			`artist`: ls.Artist,
+			`album`:  ls.Album,
+			`title`:  ls.Title,
+		})
+	}
+
+	if nil != transit {
+		// next arrival, empty when none predicted
+		next := ``
+		var first *PredictionStatus
+		ps := transit.Status()
+		for i := range ps {
+			if nil == first || ps[i].Arrival.Before(first.Arrival) {
+				first = &ps[i]
+			}
+		}
+		if nil != first {
+			next = mq.encode(first)
+		}
+		state[`mbta/next`] = next
+	}
+
+	mq.mux.Lock()
+	defer mq.mux.Unlock()
+	for k, v := range state {
+		if last, ok := mq.last[k]; ok && last == v {
+			continue
+		}
+		mq.client.Publish(mq.topic+`/`+k, mq.qos, mq.retain, v)
+		mq.last[k] = v
+	}
+
+}
+
+func (mq *MQTTClient) encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if nil != err {
+		return ``
+	}
+	return string(b)
+}
+
+// command reads a plain payload or JSON {"<key>": .., "for": "10m"}
+func command(payload []byte, key string) (string, time.Time, error) {
+	p := strings.TrimSpace(string(payload))
+	if !strings.HasPrefix(p, `{`) {
+		return p, time.Time{}, nil
+	}
+	var cmd map[string]interface{}
+	if err := json.Unmarshal(payload, &cmd); nil != err {
+		return ``, time.Time{}, err
+	}
+	v := ``
+	if cv, ok := cmd[key]; ok && nil != cv {
+		v = fmt.Sprintf("%v", cv)
+	}
+	d, _ := cmd[`for`].(string)
+	until, err := expiry(d)
+	return v, until, err
+}
+
+// setBrightness payload 0..100 or auto
+func (mq *MQTTClient) setBrightness(c mqtt.Client, m mqtt.Message) {
+	v, until, err := command(m.Payload(), `value`)
+	if nil == err {
+		if `auto` == v || `` == v {
+			dimmer.Clear()
+			return
+		}
+		var b int
+		b, err = strconv.Atoi(v)
+		if nil == err && (b < 0 || b > 100) {
+			err = fmt.Errorf("brightness must be 0..100, have %d", b)
+		}
+		if nil == err {
+			dimmer.Override(b, until)
+		}
+	}
+	if nil != err {
+		logMQTT.Warn(`bad command`, `topic`, m.Topic(), `err`, err)
+	}
+}
+
+// setScene payload scene name, none for the full face, empty to resume
+func (mq *MQTTClient) setScene(c mqtt.Client, m mqtt.Message) {
+	v, until, err := command(m.Payload(), `name`)
+	if nil == err {
+		scenes, _ := mq.attached()
+		err = scenes.Force(v, until)
+	}
+	if nil != err {
+		logMQTT.Warn(`bad command`, `topic`, m.Topic(), `err`, err)
+	}
+}
+
+// control a transport command, the topic's last level, payload its value
+func (mq *MQTTClient) control(c mqtt.Client, m mqtt.Message) {
+	name := m.Topic()[strings.LastIndex(m.Topic(), `/`)+1:]
+	v, _, err := command(m.Payload(), `value`)
+	if nil == err {
+		lms, _, _ := currentSources()
+		err = lms.Control(name, v)
+	}
+	if nil != err {
+		logMQTT.Warn(`bad command`, `topic`, m.Topic(), `err`, err)
+	}
+}
+
+// notify payload message text, empty clears
+func (mq *MQTTClient) notify(c mqtt.Client, m mqtt.Message) {
+	v, until, err := command(m.Payload(), `text`)
+	if nil != err {
+		logMQTT.Warn(`bad command`, `topic`, m.Topic(), `err`, err)
+		return
+	}
+	if `` == v {
+		mq.notifier.Clear()
+		return
+	}
+	// until is on the time source clock, as is the duration back from it
+	var d time.Duration
+	if !until.IsZero() {
+		d = until.Sub(timeNow())
+	}
+	mq.notifier.Notify(v, d)
+}
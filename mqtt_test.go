@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeToken a completed token
+type fakeToken struct{}
+
+func (ft *fakeToken) Wait() bool                     { return true }
+func (ft *fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (ft *fakeToken) Error() error                   { return nil }
+
+// failedToken a completed token with an error
+type failedToken struct{ fakeToken }
+
+func (ft *failedToken) Error() error { return fmt.Errorf("connection refused") }
+
+// fakeMQTT a connected client recording what is published, topic=payload
+type fakeMQTT struct {
+	mqtt.Client
+	published []string
+}
+
+func (fc *fakeMQTT) IsConnected() bool { return true }
+
+func (fc *fakeMQTT) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	fc.published = append(fc.published, fmt.Sprintf("%s=%v", topic, payload))
+	return &fakeToken{}
+}
+
+func (fc *fakeMQTT) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	return &fakeToken{}
+}
+
+// downMQTT a broker refusing the first connects
+type downMQTT struct {
+	fakeMQTT
+	refuse   int32
+	connects int32
+}
+
+func (dc *downMQTT) IsConnected() bool {
+	return atomic.LoadInt32(&dc.connects) > atomic.LoadInt32(&dc.refuse)
+}
+
+func (dc *downMQTT) Connect() mqtt.Token {
+	if atomic.AddInt32(&dc.connects, 1) <= atomic.LoadInt32(&dc.refuse) {
+		return &failedToken{}
+	}
+	return &fakeToken{}
+}
+
+func (dc *downMQTT) Disconnect(quiesce uint) {}
+
+// sent the topics published since the last call, sorted
+func (fc *fakeMQTT) sent() []string {
+	p := fc.published
+	fc.published = nil
+	sort.Strings(p)
+	return p
+}
+
+func TestMQTTCommand(t *testing.T) {
+
+	t0 := time.Date(2020, time.October, 16, 10, 23, 0, 0, time.UTC)
+	ts := timeSource
+	defer func() { timeSource = ts }()
+	timeSource = &stoppedTime{t: t0}
+
+	for _, tc := range []struct {
+		payload string
+		key     string
+		want    string
+		until   time.Time
+		bad     bool
+	}{
+		{`hello`, `text`, `hello`, time.Time{}, false},
+		{"  up next \n", `text`, `up next`, time.Time{}, false},
+		{``, `text`, ``, time.Time{}, false},
+		{`{"text": "hello"}`, `text`, `hello`, time.Time{}, false},
+		{`{"text": "hello", "for": "10m"}`, `text`, `hello`, t0.Add(10 * time.Minute), false},
+		{`{"value": 42, "for": "90s"}`, `value`, `42`, t0.Add(90 * time.Second), false},
+		{`{"text": null}`, `text`, ``, time.Time{}, false},
+		{`{"value": 42}`, `text`, ``, time.Time{}, false},
+		{`{"text": "hello", "for": "soon"}`, `text`, ``, time.Time{}, true},
+		{`{"text": "hello"`, `text`, ``, time.Time{}, true},
+	} {
+		v, until, err := command([]byte(tc.payload), tc.key)
+		if tc.bad {
+			if nil == err {
+				t.Errorf("%q: no error", tc.payload)
+			}
+			continue
+		}
+		if nil != err {
+			t.Errorf("%q: %v", tc.payload, err)
+		} else if tc.want != v || !tc.until.Equal(until) {
+			t.Errorf("%q: %q until %v, want %q until %v", tc.payload, v, until, tc.want, tc.until)
+		}
+	}
+
+}
+
+// TestMQTTPublishState only changed values go out, a reconnect sends
+// everything again
+func TestMQTTPublishState(t *testing.T) {
+
+	defer setClock(currentClock())
+	setClock(nil) // no sources
+
+	scenes, err := NewSceneScheduler(NewWidgetRegistry(), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cpu := &CPUStat{}
+	mq := NewMQTTClient(MQTTConfig{Broker: `tcp://127.0.0.1:1883`, Topic: `clock/`}, scenes, cpu, NewNotifier())
+	fc := &fakeMQTT{}
+	mq.client = fc
+
+	mq.publishState()
+	first := fc.sent()
+	want := []string{`clock/brightness`, `clock/cpu/temperature`, `clock/daymode`, `clock/scene`}
+	topics := make([]string, len(first))
+	for i, p := range first {
+		topics[i] = strings.SplitN(p, `=`, 2)[0]
+	}
+	if !reflect.DeepEqual(want, topics) {
+		t.Fatalf("published %q, want %q", first, want)
+	}
+
+	mq.publishState()
+	if got := fc.sent(); 0 != len(got) {
+		t.Errorf("republished unchanged %q", got)
+	}
+
+	cpu.mux.Lock()
+	cpu.temperature = 51.3
+	cpu.mux.Unlock()
+	mq.publishState()
+	if got, want := fc.sent(), []string{`clock/cpu/temperature=51.3`}; !reflect.DeepEqual(want, got) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+
+	mq.connected(fc)
+	if got := fc.sent(); !reflect.DeepEqual([]string{`clock/status=online`}, got) {
+		t.Errorf("connect published %q", got)
+	}
+	mq.publishState()
+	if got := fc.sent(); len(want) != len(got) {
+		t.Errorf("after reconnect published %q, want all %d", got, len(want))
+	}
+
+}
+
+// TestMQTTConnectRetry a broker down at boot is retried under the
+// supervisor rather than given up on
+func TestMQTTConnectRetry(t *testing.T) {
+
+	scenes, err := NewSceneScheduler(NewWidgetRegistry(), nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	mq := NewMQTTClient(MQTTConfig{Broker: `tcp://127.0.0.1:1883`, Interval: time.Hour}, scenes, &CPUStat{}, NewNotifier())
+	dc := &downMQTT{refuse: 1}
+	mq.client = dc
+	mq.Start()
+	defer mq.Stop()
+
+	deadline := time.Now().Add(3 * backoffMin)
+	for !dc.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&dc.connects); 2 != n {
+		t.Errorf("%d connects, want a retry after the refusal", n)
+	}
+
+}
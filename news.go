@@ -58,8 +58,8 @@ func InitNews(nc News) *News {
 		Velocity:   nc.Velocity,
 		Width:      nc.Width,
 		Limit:      nc.Limit,
-		atTime:     time.Now().Add(-1 * time.Hour),
-		lastNews:   time.Now().Add(-24 * time.Hour),
+		atTime:     timeNow().Add(-1 * time.Hour),
+		lastNews:   timeNow().Add(-24 * time.Hour),
 		Repeat:     nc.Repeat,
 		face:       basicfont.Face7x13,
 		fontHeight: 13,
@@ -80,7 +80,7 @@ func InitNews(nc News) *News {
 
 // Stop deactivate news scheduling
 func (n *News) Stop() {
-	n.marquee <- true
+	n.stopScroller()
 	// deactivate schedule etc
 	if n.resetTimer != nil {
 		n.resetTimer.Stop()
@@ -93,24 +93,24 @@ func (n *News) Stop() {
 func (n *News) nextTime() {
 
 	// define next active window and init timers
-	if n.atTime.Before(time.Now()) {
+	if n.atTime.Before(timeNow()) {
 		n.atTime, _ = time.Parse(`2006-01-02T15:04-0700`,
-			strings.Replace(time.Now().Format(`2006-01-02TXX:XX-0700`),
+			strings.Replace(timeNow().Format(`2006-01-02TXX:XX-0700`),
 				`XX:XX`, n.SeedTime, -1))
 	}
 
 	for {
-		if n.atTime.After(time.Now()) {
+		if n.atTime.After(timeNow()) {
 			break
 		}
 		n.atTime = n.atTime.Add(n.Repeat)
 	}
 	n.endTime = n.atTime.Add(n.Duration)
 
-	waitDuration := time.Duration(n.atTime.Add(-1*time.Minute).UnixNano() - time.Now().UnixNano())
-	n.newsTimer = time.AfterFunc(waitDuration, func() { n.getNews() })
-	waitDuration = time.Duration(n.endTime.UnixNano() - time.Now().UnixNano())
-	n.resetTimer = time.AfterFunc(waitDuration, func() { n.nextTime() })
+	waitDuration := time.Duration(n.atTime.Add(-1*time.Minute).UnixNano() - timeNow().UnixNano())
+	n.newsTimer = supervisor.AfterFunc(`news`, wallDelay(waitDuration), n.getNews)
+	waitDuration = time.Duration(n.endTime.UnixNano() - timeNow().UnixNano())
+	n.resetTimer = supervisor.AfterFunc(`news`, wallDelay(waitDuration), n.nextTime)
 
 	n.stopScroller()
 
@@ -130,7 +130,7 @@ func (n *News) SetFace(f font.Face) {
 
 // Display init news scheduling
 func (n *News) Display() bool {
-	t := time.Now()
+	t := timeNow()
 	return (t.After(n.atTime.Add(-1*time.Second)) &&
 		t.Before(n.endTime) &&
 		n.news != ``)
@@ -194,28 +194,27 @@ func (n *News) scroller() {
 
 }
 
-// getNews fetch news text
+// getNews fetch news text, the last headlines stay when every feed fails
 func (n *News) getNews() {
 
-	// shouldn't need this!
-	defer func() {
-		if err := recover(); err != nil {
-			panic(err)
-		}
-	}()
-
-	f := n.fetchFeeds()
+	f, err := n.fetchFeeds()
+	if 0 == len(f) && nil != err {
+		health.Fail(sourceError(`news`, `fetch`, err))
+		return
+	}
+	health.OK(`news`)
 
-	n.news = ``
+	now := timeNow()
+	news := ``
 	sep := ``
 	for _, fl := range f {
 		if fl != nil {
 			for xx, fi := range fl.Items {
-				if xx < 9 && fi.PublishedParsed != nil && fi.PublishedParsed.After(n.lastNews) {
-					if !strings.Contains(n.news, fi.Title) {
-						n.news += sep + "• " + fi.Title
+				if xx < 9 && fi.PublishedParsed != nil && fi.PublishedParsed.After(n.lastNews) && fi.PublishedParsed.Before(now) {
+					if !strings.Contains(news, fi.Title) {
+						news += sep + "• " + fi.Title
 						if n.Detail {
-							n.news += "\n" + fi.Description
+							news += "\n" + fi.Description
 						}
 						sep = "\n"
 					}
@@ -223,13 +222,27 @@ func (n *News) getNews() {
 			}
 		}
 	}
-	n.lastNews = time.Now().UTC()
+	n.news = news
+	n.lastNews = now.UTC()
 
 	n.paintCanvas()
 
 }
 
-func (n *News) fetchFeeds() []*gofeed.Feed {
+// fetchFeeds the feeds read, err the last failure if any
+func (n *News) fetchFeeds() ([]*gofeed.Feed, error) {
+
+	if nil != fixtures {
+		b, err := fixtures.Payload(`news.xml`, timeNow())
+		if nil != err {
+			return nil, err
+		}
+		feed, err := gofeed.NewParser().ParseString(string(b))
+		if nil != err {
+			return nil, err
+		}
+		return []*gofeed.Feed{feed}, nil
+	}
 
 	fc := make(chan freedres, len(n.Feeds))
 
@@ -241,24 +254,28 @@ func (n *News) fetchFeeds() []*gofeed.Feed {
 	}
 
 	var fs []*gofeed.Feed
+	var err error
 	for i := 0; i < len(n.Feeds); i++ {
 		res := <-fc
 		if res.err != nil {
+			err = res.err
 			continue
 		}
 		fs = append(fs, res.feed)
 	}
 
-	return fs
+	return fs, err
 }
 
 func (n *News) fetchFeed(uri string, fc chan freedres) {
 
 	fp := gofeed.NewParser()
 	feed, err := fp.ParseURL(uri)
+	mNewsFetch.With(outcome(err)).Inc()
 
 	if err != nil {
 		fc <- freedres{nil, err}
+		return
 	}
 
 	fc <- freedres{feed, nil}
@@ -270,8 +287,65 @@ func (n *News) Image() *image.RGBA {
 	return n.canvas
 }
 
-func newsStop() {
-	if nil != news {
-		news.Stop()
+// Widget adapts the headline scroller, lw is the frame line width
+func (n *News) Widget(lw float64) Widget {
+	return &newsWidget{n: n, lw: lw}
+}
+
+// newsWidget scrolls headlines in the lower zone during the news window
+type newsWidget struct {
+	n  *News
+	lw float64
+}
+
+// Start does nothing, the window timers run from InitNews
+func (nw *newsWidget) Start() {}
+
+// Stop deactivate news scheduling
+func (nw *newsWidget) Stop() {
+	nw.n.Stop()
+}
+
+// PreferredSize is the scroller canvas
+func (nw *newsWidget) PreferredSize() image.Point {
+	if nil == nw.n.canvas {
+		return image.ZP
+	}
+	return nw.n.canvas.Bounds().Size()
+}
+
+// Dirty always, the marquee scrolls
+func (nw *newsWidget) Dirty() bool {
+	return true
+}
+
+// Visible within the news window
+func (nw *newsWidget) Visible() bool {
+	return nw.n.Display()
+}
+
+// Render the scroller, framed
+func (nw *newsWidget) Render(dc *gg.Context, rg *Region) {
+	dc.DrawImageAnchored(nw.n.Image(), rg.Rect.Min.X+1, rg.Rect.Min.Y, 0, 0)
+	placeBorderZone(dc, nw.lw, rg.Rect, H-rg.Rect.Min.Y-2)
+}
+
+// NewsStatus the news window for status reporting
+type NewsStatus struct {
+	Display  bool      `json:"display"`
+	From     time.Time `json:"from"`
+	Until    time.Time `json:"until"`
+	Headline string    `json:"headlines"`
+}
+
+// Status returns the news window and headlines
+func (n *News) Status() NewsStatus {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return NewsStatus{
+		Display:  n.Display(),
+		From:     n.atTime,
+		Until:    n.endTime,
+		Headline: n.news,
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+type (
+	// notification an on-screen message
+	notification struct {
+		text     string
+		duration time.Duration
+	}
+
+	// Notifier queues on-screen messages for the lower zone, each shown
+	// for its duration in turn
+	Notifier struct {
+		queue   []notification
+		current notification
+		until   time.Time
+		mux     sync.Mutex
+	}
+
+	notifyWidget struct {
+		n     *Notifier
+		face  font.Face
+		color string
+		lw    float64
+		shown string
+	}
+)
+
+// notifyLimit caps the queued messages, the oldest are dropped
+const notifyLimit = 8
+
+// NewNotifier creates an empty message queue
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Notify queues a message, a zero duration shows it for 10 seconds
+func (n *Notifier) Notify(text string, d time.Duration) {
+	if d <= 0 {
+		d = 10 * time.Second
+	}
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.queue = append(n.queue, notification{text: text, duration: d})
+	if len(n.queue) > notifyLimit {
+		n.queue = n.queue[len(n.queue)-notifyLimit:]
+	}
+}
+
+// Clear drops the showing and queued messages
+func (n *Notifier) Clear() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.queue = nil
+	n.until = time.Time{}
+}
+
+// Message returns the message showing at t, advancing the queue
+func (n *Notifier) Message(t time.Time) (string, bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if t.Before(n.until) {
+		return n.current.text, true
+	}
+	if 0 == len(n.queue) {
+		return ``, false
+	}
+	n.current, n.queue = n.queue[0], n.queue[1:]
+	n.until = t.Add(n.current.duration)
+	return n.current.text, true
+}
+
+// Widget adapts the notifier to the lower zone, lw is the frame line width
+func (n *Notifier) Widget(face font.Face, color string, lw float64) Widget {
+	return &notifyWidget{n: n, face: face, color: color, lw: lw}
+}
+
+// Start does nothing
+func (nw *notifyWidget) Start() {}
+
+// Stop does nothing
+func (nw *notifyWidget) Stop() {}
+
+// PreferredSize fills the region
+func (nw *notifyWidget) PreferredSize() image.Point { return image.ZP }
+
+// Dirty when the message changes
+func (nw *notifyWidget) Dirty() bool {
+	text, _ := nw.n.Message(frameTime())
+	return text != nw.shown
+}
+
+// Visible while a message shows
+func (nw *notifyWidget) Visible() bool {
+	_, ok := nw.n.Message(frameTime())
+	return ok
+}
+
+// Render the message wrapped and centered, framed
+func (nw *notifyWidget) Render(dc *gg.Context, rg *Region) {
+	text, _ := nw.n.Message(frameTime())
+	nw.shown = text
+	cx, cy := rg.Center()
+	dc.SetFontFace(nw.face)
+	dc.SetHexColor(nw.color)
+	dc.DrawStringWrapped(text, cx, cy, 0.5, 0.5, float64(rg.Rect.Dx()-4), 1.2, gg.AlignCenter)
+	placeBorderZone(dc, nw.lw, rg.Rect, H-rg.Rect.Min.Y-2)
+}
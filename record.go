@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	recordGIF    = `gif`
+	recordFrames = `frames`
+)
+
+type (
+	// Recorder captures the frames shown while capture is on to an
+	// animated GIF or numbered PNGs with an ffmpeg concat manifest, a
+	// single worker per recording writes them in the background
+	Recorder struct {
+		rc      RecordSection
+		frames  chan recordFrame
+		started time.Time
+		last    time.Time // of the latest update, on the same clock as started
+		count   int
+		dropped int
+	}
+
+	// recordFrame a frame and the time it went to the display
+	recordFrame struct {
+		img *image.NRGBA
+		at  time.Time
+	}
+)
+
+var mRecordDropped = metrics.Counter(`rgbclock_record_dropped_total`, `Frames dropped by a recorder falling behind.`)
+
+// Recording reports a recording in progress
+func (rec *Recorder) Recording() bool {
+	return nil != rec.frames
+}
+
+// Update follows the capture switch: starts, feeds and ends recordings,
+// changed frames are queued with the time shown; the result is false
+// once the recording ends on its duration or frame limit
+func (rec *Recorder) Update(on bool, frame image.Image, changed bool, now time.Time) bool {
+
+	if on != rec.Recording() {
+		if on {
+			rec.start(currentConfig().Record, now)
+		} else {
+			rec.Stop()
+		}
+	}
+	if !on {
+		return false
+	}
+	rec.last = now
+
+	if (rec.rc.Duration > 0 && now.Sub(rec.started) >= rec.rc.Duration) || rec.count >= rec.rc.Limit {
+		rec.Stop()
+		return false
+	}
+	if changed || 0 == rec.count {
+		img := imaging.Resize(frame, frame.Bounds().Dx()*rec.rc.Scale, frame.Bounds().Dy()*rec.rc.Scale, imaging.NearestNeighbor)
+		select {
+		case rec.frames <- recordFrame{img: img, at: now}:
+			rec.count++
+		default:
+			rec.dropped++
+			mRecordDropped.Inc()
+		}
+	}
+	return true
+
+}
+
+func (rec *Recorder) start(rc RecordSection, now time.Time) {
+
+	*rec = Recorder{
+		rc:      rc,
+		frames:  make(chan recordFrame, rc.Queue),
+		started: now,
+		last:    now,
+	}
+
+	name := path.Join(rc.Folder, `rgbclock-`+now.Format(`20060102-150405`))
+	frames := rec.frames
+	supervisor.Go(`recorder`, func(_ context.Context) error {
+		var err error
+		if recordGIF == rc.Format {
+			name += `.gif`
+			err = writeGIF(name, frames)
+		} else {
+			err = writeFrames(name, frames)
+		}
+		if nil != err {
+			logDisplay.Error(`recording failed`, `path`, name, `err`, err)
+			// drain so the render loop never blocks on a dead worker
+			for range frames {
+			}
+		}
+		return nil
+	})
+	logDisplay.Info(`recording`, `path`, name, `format`, rc.Format, `duration`, rc.Duration)
+
+}
+
+// Stop ends the recording, the worker finishes the file
+func (rec *Recorder) Stop() {
+	if !rec.Recording() {
+		return
+	}
+	close(rec.frames)
+	rec.frames = nil
+	logDisplay.Info(`recording stopped`, `frames`, rec.count, `dropped`, rec.dropped,
+		`seconds`, rec.last.Sub(rec.started).Seconds())
+}
+
+// writeGIF encodes the frames once all are in, each shown until the
+// next, GIF delays are in hundredths so the error is not allowed to
+// accumulate
+func writeGIF(name string, frames chan recordFrame) error {
+
+	anim := &gif.GIF{}
+	var first, last time.Time
+	cs := func(t time.Time) int {
+		return int(t.Sub(first).Round(10*time.Millisecond) / (10 * time.Millisecond))
+	}
+	for f := range frames {
+		if 0 == len(anim.Image) {
+			first = f.at
+		} else {
+			anim.Delay = append(anim.Delay, cs(f.at)-cs(last))
+		}
+		pi := image.NewPaletted(f.img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(pi, pi.Rect, f.img, image.ZP)
+		anim.Image = append(anim.Image, pi)
+		last = f.at
+	}
+	if 0 == len(anim.Image) {
+		return nil
+	}
+	// the last frame holds as long as the one before, or a second
+	hold := 100
+	if n := len(anim.Delay); n > 0 {
+		hold = anim.Delay[n-1]
+	}
+	anim.Delay = append(anim.Delay, hold)
+
+	if err := os.MkdirAll(path.Dir(name), 0755); nil != err {
+		return err
+	}
+	fh, err := os.Create(name)
+	if nil != err {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	if err = gif.EncodeAll(w, anim); nil == err {
+		err = w.Flush()
+	}
+	if cerr := fh.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		logDisplay.Info(`recording saved`, `path`, name, `frames`, len(anim.Image))
+	}
+	return err
+
+}
+
+// writeFrames writes frameNNNNNN.png into the folder as the frames come
+// and concat.txt at the end, for ffmpeg -f concat -i concat.txt out.mp4
+func writeFrames(folder string, frames chan recordFrame) error {
+
+	if err := os.MkdirAll(folder, 0755); nil != err {
+		return err
+	}
+	var at []time.Time
+	for f := range frames {
+		fh, err := os.Create(path.Join(folder, fmt.Sprintf("frame%06d.png", len(at))))
+		if nil != err {
+			return err
+		}
+		err = png.Encode(fh, f.img)
+		if cerr := fh.Close(); nil == err {
+			err = cerr
+		}
+		if nil != err {
+			return err
+		}
+		at = append(at, f.at)
+	}
+	if 0 == len(at) {
+		return nil
+	}
+
+	fh, err := os.Create(path.Join(folder, `concat.txt`))
+	if nil != err {
+		return err
+	}
+	w := bufio.NewWriter(fh)
+	fmt.Fprintln(w, `ffconcat version 1.0`)
+	hold := time.Second
+	for i := range at {
+		if i+1 < len(at) {
+			hold = at[i+1].Sub(at[i])
+		}
+		fmt.Fprintf(w, "file frame%06d.png\nduration %.3f\n", i, hold.Seconds())
+	}
+	// the concat demuxer drops the duration of the last entry
+	fmt.Fprintf(w, "file frame%06d.png\n", len(at)-1)
+	err = w.Flush()
+	if cerr := fh.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		logDisplay.Info(`recording saved`, `path`, folder, `frames`, len(at))
+	}
+	return err
+
+}
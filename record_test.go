@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testFrame a w x h frame of one colour
+func testFrame(w, h int, c color.Color) *image.NRGBA {
+	im := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		im.Set(i%w, i/w, c)
+	}
+	return im
+}
+
+// TestRecordFrames three changed frames through the recorder become
+// numbered PNGs and a manifest holding each until the next
+func TestRecordFrames(t *testing.T) {
+
+	tmp, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	defer setConfig(currentConfig())
+	setConfig(&Config{Record: RecordSection{Format: recordFrames, Folder: tmp, Scale: 2, Queue: 8, Limit: 100}})
+
+	t0 := time.Date(2020, time.October, 16, 10, 23, 0, 0, time.Local)
+	rec := &Recorder{}
+	for i, at := range []time.Duration{0, 250 * time.Millisecond, 1250 * time.Millisecond} {
+		frame := testFrame(4, 4, color.Gray{uint8(80 * i)})
+		if !rec.Update(true, frame, true, t0.Add(at)) {
+			t.Fatalf("frame %d: recording ended", i)
+		}
+	}
+	rec.Update(false, nil, false, t0.Add(2*time.Second))
+	if d := rec.last.Sub(rec.started); 1250*time.Millisecond != d {
+		t.Errorf("recorded %v, want 1.25s on the time source", d)
+	}
+
+	want := `ffconcat version 1.0
+file frame000000.png
+duration 0.250
+file frame000001.png
+duration 1.000
+file frame000002.png
+duration 1.000
+file frame000002.png
+`
+	// the worker writes the manifest last
+	folder := filepath.Join(tmp, `rgbclock-20201016-102300`)
+	var b []byte
+	for end := time.Now().Add(5 * time.Second); want != string(b) && time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+		b, _ = ioutil.ReadFile(filepath.Join(folder, `concat.txt`))
+	}
+	if want != string(b) {
+		t.Errorf("concat.txt\n%s\nwant\n%s", b, want)
+	}
+	for i := 0; i < 3; i++ {
+		fh, err := os.Open(filepath.Join(folder, fmt.Sprintf("frame%06d.png", i)))
+		if nil != err {
+			t.Error(err)
+			continue
+		}
+		im, _, err := image.Decode(fh)
+		fh.Close()
+		if nil != err {
+			t.Error(err)
+		} else if want := image.Rect(0, 0, 8, 8); want != im.Bounds() {
+			t.Errorf("frame %d is %v, want %v at scale 2", i, im.Bounds(), want)
+		}
+	}
+
+}
+
+// TestRecordGIFDelays delays in hundredths are rounded against the start
+// so a third of a second each does not drift, the last holds as the one
+// before
+func TestRecordGIFDelays(t *testing.T) {
+
+	tmp, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	t0 := time.Date(2020, time.October, 16, 10, 23, 0, 0, time.Local)
+	for _, tc := range []struct {
+		name  string
+		at    []time.Duration
+		delay []int
+	}{
+		{`thirds`, []time.Duration{0, 333 * time.Millisecond, 666 * time.Millisecond}, []int{33, 34, 34}},
+		{`uneven`, []time.Duration{0, 50 * time.Millisecond, 1050 * time.Millisecond}, []int{5, 100, 100}},
+		{`one frame`, []time.Duration{0}, []int{100}},
+	} {
+		frames := make(chan recordFrame, len(tc.at))
+		for i, at := range tc.at {
+			frames <- recordFrame{img: testFrame(4, 4, color.Gray{uint8(80 * i)}), at: t0.Add(at)}
+		}
+		close(frames)
+		name := filepath.Join(tmp, tc.name+`.gif`)
+		if err = writeGIF(name, frames); nil != err {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		fh, err := os.Open(name)
+		if nil != err {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		anim, err := gif.DecodeAll(fh)
+		fh.Close()
+		if nil != err {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if !reflect.DeepEqual(tc.delay, anim.Delay) || len(tc.at) != len(anim.Image) {
+			t.Errorf("%s: %d frames delays %v, want %d %v", tc.name, len(anim.Image), anim.Delay, len(tc.at), tc.delay)
+		}
+	}
+
+}
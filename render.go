@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/mellena1/mbta-v3-go/mbta"
+)
+
+// FrameInput pins what a frame shows, the same input composes the same
+// frame whatever the wall clock or network
+type FrameInput struct {
+	Time        time.Time
+	Weather     []byte // weather server payload
+	LMS         []byte // LMS status query result, nil leaves the player as is
+	Predictions []byte // MBTA v3 predictions payload, nil for none
+	Mode        bool   // the alternate face, toggleMode flips it when running
+}
+
+// Render composes the frame for in without fetching anything, the clock
+// must not be started
+func (cl *Clock) Render(in FrameInput) (*image.RGBA, error) {
+
+	if err := applyWeather(in.Weather, in.Time); nil != err {
+		return nil, err
+	}
+	if nil != in.LMS {
+		cl.lms.mux.Lock()
+		err := cl.lms.applyStatus(in.LMS)
+		cl.lms.mux.Unlock()
+		if nil != err {
+			return nil, err
+		}
+		cl.lms.updateArt()
+	}
+	if cl.transit.inWindow(in.Time) && nil != in.Predictions {
+		pred, err := decodePredictions(in.Predictions)
+		if nil != err {
+			return nil, sourceError(`mbta`, `decode`, err)
+		}
+		cl.transit.applyPredictions(pred, in.Time)
+	}
+	mode = in.Mode
+
+	cl.Compose(in.Time)
+	return cl.comp.Frame(), nil
+
+}
+
+// decodePredictions reads a predictions payload with its included routes
+// and stops, the attributes the transit widget uses
+func decodePredictions(b []byte) ([]*mbta.Prediction, error) {
+
+	type (
+		ref struct {
+			Data struct {
+				Type string `json:"type"`
+				ID   string `json:"id"`
+			} `json:"data"`
+		}
+		resource struct {
+			Type          string          `json:"type"`
+			ID            string          `json:"id"`
+			Attributes    json.RawMessage `json:"attributes"`
+			Relationships struct {
+				Route ref `json:"route"`
+				Stop  ref `json:"stop"`
+			} `json:"relationships"`
+		}
+	)
+
+	var doc struct {
+		Data     []resource `json:"data"`
+		Included []resource `json:"included"`
+	}
+	if err := json.Unmarshal(b, &doc); nil != err {
+		return nil, err
+	}
+
+	routes := make(map[string]*mbta.Route)
+	stops := make(map[string]*mbta.Stop)
+	for _, r := range doc.Included {
+		var err error
+		switch r.Type {
+		case `route`:
+			rt := &mbta.Route{}
+			err = json.Unmarshal(r.Attributes, &struct {
+				Color          *string   `json:"color"`
+				Description    *string   `json:"description"`
+				DirectionNames *[]string `json:"direction_names"`
+				LongName       *string   `json:"long_name"`
+				ShortName      *string   `json:"short_name"`
+			}{&rt.Color, &rt.Description, &rt.DirectionNames, &rt.LongName, &rt.ShortName})
+			rt.ID, routes[r.ID] = r.ID, rt
+		case `stop`:
+			st := &mbta.Stop{}
+			err = json.Unmarshal(r.Attributes, &struct {
+				Name         *string  `json:"name"`
+				PlatformName **string `json:"platform_name"`
+			}{&st.Name, &st.PlatformName})
+			st.ID, stops[r.ID] = r.ID, st
+		}
+		if nil != err {
+			return nil, fmt.Errorf("%s %s: %v", r.Type, r.ID, err)
+		}
+	}
+
+	pred := make([]*mbta.Prediction, 0, len(doc.Data))
+	for _, r := range doc.Data {
+		p := &mbta.Prediction{ID: r.ID}
+		err := json.Unmarshal(r.Attributes, &struct {
+			ArrivalTime          **mbta.TimeISO8601                        `json:"arrival_time"`
+			DepartureTime        **mbta.TimeISO8601                        `json:"departure_time"`
+			DirectionID          *int                                      `json:"direction_id"`
+			ScheduleRelationship **mbta.PredictionScheduleRelationshipType `json:"schedule_relationship"`
+			Status               **string                                  `json:"status"`
+			StopSequence         *int                                      `json:"stop_sequence"`
+		}{&p.ArrivalTime, &p.DepartureTime, &p.DirectionID, &p.ScheduleRelationship, &p.Status, &p.StopSequence})
+		if nil != err {
+			return nil, fmt.Errorf("prediction %s: %v", r.ID, err)
+		}
+		p.Route, p.Stop = routes[r.Relationships.Route.Data.ID], stops[r.Relationships.Stop.Data.ID]
+		if nil == p.Route || nil == p.Stop {
+			return nil, fmt.Errorf("prediction %s: route or stop not included", r.ID)
+		}
+		if p.DirectionID < 0 || p.DirectionID >= len(p.Route.DirectionNames) {
+			return nil, fmt.Errorf("prediction %s: no direction %d", r.ID, p.DirectionID)
+		}
+		pred = append(pred, p)
+	}
+	return pred, nil
+
+}
+
+// readFixtures loads the weather, lms and mbta payloads in effect at from
+// the fixtures in folder, only the weather is required
+func readFixtures(folder string, at time.Time) (in FrameInput, err error) {
+	f, err := NewFixtures(folder)
+	if nil != err {
+		return
+	}
+	in.Time = at
+	in.Mode = true
+	if in.Weather, err = f.Payload(`weather.json`, at); nil != err {
+		return
+	}
+	for _, p := range []struct {
+		name string
+		b    *[]byte
+	}{{`lms.json`, &in.LMS}, {`mbta.json`, &in.Predictions}} {
+		if *p.b, err = f.Payload(p.name, at); os.IsNotExist(err) {
+			err = nil
+		} else if nil != err {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// go test -run Golden -update rewrites testdata/golden from the current
+// renderer, review the PNGs before committing them
+var update = flag.Bool(`update`, false, `rewrite the golden images`)
+
+// golden tolerance, antialiasing differs a little between rasterizer and
+// font versions: a channel may be off by pixelDelta on up to pixelShare
+// of the pixels
+const (
+	pixelDelta = 8
+	pixelShare = 0.005
+)
+
+// fixtureTime a Friday morning, daylight, the transit window open and two
+// trains due
+var fixtureTime = time.Date(2020, time.October, 16, 10, 23, 37, 0, time.FixedZone(`EDT`, -4*60*60))
+
+func TestGoldenLayouts(t *testing.T) {
+
+	for _, tc := range []struct {
+		name   string
+		layout string
+		scene  string
+	}{
+		{`simple`, `simple`, ``},
+		{`full`, `full`, ``},
+		{`full_mbta`, `full`, `mbta`},
+		{`jumbo`, `jumbo`, ``},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			frame := renderFixture(t, tc.layout, tc.scene)
+			checkGolden(t, `layout_`+tc.name, frame)
+		})
+	}
+
+}
+
+func TestGoldenRenderRepeats(t *testing.T) {
+	a := renderFixture(t, `full`, ``)
+	b := renderFixture(t, `full`, ``)
+	if n, _ := diffPixels(a, b, 0); 0 != n {
+		t.Fatalf("same input rendered %d different pixels", n)
+	}
+}
+
+func TestGoldenVU(t *testing.T) {
+
+	for _, tc := range []struct {
+		name string
+		lc   LMSConfig
+		draw func(ls *LMSServer)
+	}{
+		{`analog`, LMSConfig{
+			Meter:        `vuPeak`,
+			MeterMode:    `VU`,
+			MeterLayout:  `horizontal`,
+			MeterBase:    `svg/vuminimal.png`,
+			NeedleColor:  `#ffffff`,
+			NeedleWidth:  0.8,
+			NeedleLength: 0.85,
+			NeedleWell:   true,
+		}, func(ls *LMSServer) {
+			ls.vuAnalog([2]int32{}, [2]int32{30, 42}, [2]int32{}, [2]int32{}, [2]int32{})
+		}},
+		{`peak`, LMSConfig{
+			Meter:       `vuPeak`,
+			MeterMode:   `vuPeak`,
+			MeterLayout: `horizontal`,
+			MeterBase:   `svg/vupeak.svg`,
+			NeedleColor: `#0000ff`,
+		}, func(ls *LMSServer) {
+			ls.vuPeak([2]int32{-12, 1})
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := ioutil.TempDir(``, `rgbclock`)
+			if nil != err {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(cache)
+
+			tc.lc.Host, tc.lc.Port, tc.lc.BaseFolder = `127.0.0.1`, 1, cache
+			tc.lc.SSESEndpoint = `/`
+			ls := NewLMSServer(tc.lc)
+			defer ls.Close()
+			tc.draw(ls)
+			checkGolden(t, `vu_`+tc.name, ls.VU())
+		})
+	}
+
+}
+
+func TestGoldenMoon(t *testing.T) {
+
+	for _, day := range []int{2, 9, 16, 23} {
+		t.Run(fmt.Sprintf("day%02d", day), func(t *testing.T) {
+			tm := time.Date(2020, time.October, day, 21, 0, 0, 0, time.UTC)
+			img, err := NewLuna(tm, 42.365250, -71.105011).PhaseIcon(44, 44)
+			if nil != err {
+				t.Fatal(err)
+			}
+			checkGolden(t, fmt.Sprintf("moon_day%02d", day), img)
+		})
+	}
+
+}
+
+func TestGoldenDigits(t *testing.T) {
+
+	for _, tc := range []struct {
+		name  string
+		style func(time.Time, float64, string) ([2]draw.Image, error)
+	}{
+		{`plain`, imageTime},
+		{`thin`, imageTimeThin},
+		{`thick`, imageTimeThick},
+		{`dotty`, imageTimeDotty},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := tc.style(fixtureTime, 26, `#ff4500`)
+			if nil != err {
+				t.Fatal(err)
+			}
+			for i, im := range img {
+				checkGolden(t, fmt.Sprintf("digits_%s_%d", tc.name, i), im)
+			}
+		})
+	}
+
+}
+
+// renderFixture composes the testdata fixtures on the given layout,
+// forcing scene when not empty
+func renderFixture(t *testing.T, layout, scene string) *image.RGBA {
+
+	t.Helper()
+
+	defer keepLayout()()
+	*configPath, *layoutFlag = `testdata/config.yml`, layout
+	c, err := loadConfig()
+	if nil != err {
+		t.Fatal(err)
+	}
+	cacheFixedIcons()
+	snap = false // no test.png icon dump
+
+	cl, err := newClock(c, NewNotifier())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer cl.Stop()
+	if `` != scene {
+		if err = cl.scenes.Force(scene, time.Time{}); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	in, err := readFixtures(`testdata/fixtures`, fixtureTime)
+	if nil != err {
+		t.Fatal(err)
+	}
+	frame, err := cl.Render(in)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return frame
+
+}
+
+// checkGolden compares img with testdata/golden/name.png, or rewrites it
+// with -update
+func checkGolden(t *testing.T, name string, img image.Image) {
+
+	t.Helper()
+
+	file := path.Join(`testdata`, `golden`, name+`.png`)
+	if *update {
+		fh, err := os.Create(file)
+		if nil != err {
+			t.Fatal(err)
+		}
+		defer fh.Close()
+		if err = png.Encode(fh, img); nil != err {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	fh, err := os.Open(file)
+	if nil != err {
+		t.Fatalf("%v, run go test -run Golden -update", err)
+	}
+	defer fh.Close()
+	want, err := png.Decode(fh)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("%s: size %v, golden %v", name, img.Bounds().Size(), want.Bounds().Size())
+	}
+	n, total := diffPixels(img, want, pixelDelta)
+	if float64(n) > pixelShare*float64(total) {
+		t.Errorf("%s: %d of %d pixels differ from the golden image", name, n, total)
+	}
+
+}
+
+// diffPixels counts the pixels of a and b, the same size, with a channel
+// more than delta apart
+func diffPixels(a, b image.Image, delta int) (n, total int) {
+
+	ab, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [4][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+				if v := int(d[0]>>8) - int(d[1]>>8); v > delta || -v > delta {
+					n++
+					break
+				}
+			}
+			total++
+		}
+	}
+	return
+
+}
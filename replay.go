@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fixtures serves recorded payloads in place of the live sources.  Each
+// source is a file in the folder, weather.json, lms.json, mbta.json or
+// news.xml, or a folder of that name, weather/, of payloads named for the
+// time of day they take effect, 0645.json; sses.txt is a recorded VU/SA
+// event stream, played in a loop
+type Fixtures struct {
+	folder string
+}
+
+// ssesPace the gap between replayed VU/SA events
+const ssesPace = 50 * time.Millisecond
+
+// fixtures replace the live sources when set, see replay
+var fixtures *Fixtures
+
+// NewFixtures reads payloads from folder
+func NewFixtures(folder string) (*Fixtures, error) {
+	fi, err := os.Stat(folder)
+	if nil == err && !fi.IsDir() {
+		err = fmt.Errorf("%s: not a folder", folder)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &Fixtures{folder: folder}, nil
+}
+
+// Payload the recorded name, weather.json say, in effect at; before the
+// first of the day the last of the day before
+func (f *Fixtures) Payload(name string, at time.Time) ([]byte, error) {
+
+	file := path.Join(f.folder, name)
+	if fileExists(file) {
+		return ioutil.ReadFile(file)
+	}
+
+	ext := path.Ext(name)
+	folder := strings.TrimSuffix(file, ext)
+	files, err := ioutil.ReadDir(folder)
+	if nil != err {
+		return nil, err
+	}
+	var times []string
+	for _, fi := range files {
+		if t := strings.TrimSuffix(fi.Name(), ext); !fi.IsDir() && ext == path.Ext(fi.Name()) && 4 == len(t) {
+			times = append(times, t)
+		}
+	}
+	if 0 == len(times) {
+		return nil, fmt.Errorf("%s: no HHMM%s payloads", folder, ext)
+	}
+	sort.Strings(times)
+
+	hhmm := at.Format(`1504`)
+	pick := times[len(times)-1]
+	for _, t := range times {
+		if t > hhmm {
+			break
+		}
+		pick = t
+	}
+	return ioutil.ReadFile(path.Join(folder, pick+ext))
+
+}
+
+// replaySSES plays sses.txt into the events until ctx is done, looping at
+// the end; a missing recording deactivates the meters as a failed connect
+func (f *Fixtures) replaySSES(ctx context.Context, uri string, evCh chan<- *SSEvent) error {
+
+	file := path.Join(f.folder, `sses.txt`)
+	for nil == ctx.Err() {
+		fh, err := os.Open(file)
+		if nil != err {
+			logSSES.Error(`replay failed`, `file`, file, `err`, err)
+			sendEvent(ctx, evCh, &SSEvent{URI: uri, Active: false})
+			return err
+		}
+		n, err := ssescan(ctx, fh, uri, evCh, ssesPace)
+		fh.Close()
+		if nil != ctx.Err() {
+			break
+		}
+		if nil != err || 0 == n {
+			if nil == err {
+				err = fmt.Errorf("%s: no events", file)
+			}
+			logSSES.Error(`replay failed`, `file`, file, `err`, err)
+			sendEvent(ctx, evCh, &SSEvent{URI: uri, Active: false})
+			return err
+		}
+	}
+	// the consumer stops with ctx, nobody may be reading
+	sendEvent(ctx, evCh, &SSEvent{URI: uri, Active: false})
+	return nil
+
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// testFixtures a fixtures folder holding files, name to content
+func testFixtures(t *testing.T, files map[string]string) (*Fixtures, func()) {
+
+	t.Helper()
+
+	folder, err := ioutil.TempDir(``, `rgbclock`)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		file := path.Join(folder, name)
+		if err = os.MkdirAll(path.Dir(file), 0755); nil == err {
+			err = ioutil.WriteFile(file, []byte(content), 0644)
+		}
+		if nil != err {
+			os.RemoveAll(folder)
+			t.Fatal(err)
+		}
+	}
+	f, err := NewFixtures(folder)
+	if nil != err {
+		os.RemoveAll(folder)
+		t.Fatal(err)
+	}
+	return f, func() { os.RemoveAll(folder) }
+
+}
+
+func TestReplayPayload(t *testing.T) {
+
+	f, done := testFixtures(t, map[string]string{
+		`lms.json`:          `lms`,
+		`weather/0600.json`: `dawn`,
+		`weather/1800.json`: `dusk`,
+	})
+	defer done()
+
+	day := time.Date(2020, time.October, 16, 0, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		name string
+		at   time.Duration
+		want string
+	}{
+		{`lms.json`, 0, `lms`},
+		{`weather.json`, 5 * time.Hour, `dusk`}, // the day before's last
+		{`weather.json`, 6 * time.Hour, `dawn`},
+		{`weather.json`, 17 * time.Hour, `dawn`},
+		{`weather.json`, 23 * time.Hour, `dusk`},
+	} {
+		got, err := f.Payload(tc.name, day.Add(tc.at))
+		if nil != err {
+			t.Errorf("%s at %v: %v", tc.name, tc.at, err)
+		} else if tc.want != string(got) {
+			t.Errorf("%s at %v: got %q, want %q", tc.name, tc.at, got, tc.want)
+		}
+	}
+	if _, err := f.Payload(`mbta.json`, day); nil == err {
+		t.Error("payload for a missing fixture")
+	}
+
+}
+
+func TestReplaySSES(t *testing.T) {
+
+	f, done := testFixtures(t, map[string]string{
+		`sses.txt`: "event: VU\ndata: one\n\nevent: VU\ndata: two\n\n",
+	})
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	evCh := make(chan *SSEvent)
+	ended := make(chan error, 1)
+	go func() { ended <- f.replaySSES(ctx, `replay`, evCh) }()
+
+	// loops at the end of the recording
+	for _, want := range []string{`one`, `two`, `one`} {
+		select {
+		case ev := <-evCh:
+			if !ev.Active || nil == ev.Data {
+				t.Fatalf("event %+v, want active %q", ev, want)
+			}
+			if data, _ := ioutil.ReadAll(ev.Data); want != string(data) {
+				t.Fatalf("event %q, want %q", data, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %q event", want)
+		}
+	}
+
+	// nobody reading, cancel must still end the replay
+	cancel()
+	select {
+	case err := <-ended:
+		if nil != err {
+			t.Errorf("replay ended with %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replay blocked after cancel")
+	}
+
+}
+
+func TestReplaySSESMissing(t *testing.T) {
+
+	f, done := testFixtures(t, nil)
+	defer done()
+
+	evCh := make(chan *SSEvent, 1)
+	if err := f.replaySSES(context.Background(), `replay`, evCh); nil == err {
+		t.Error("replayed a missing recording")
+	}
+	if ev := <-evCh; ev.Active {
+		t.Error("meters left active without a recording")
+	}
+
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+type (
+	// Scene is a lower zone arrangement competing for the screen
+	Scene struct {
+		Name     string
+		Priority int
+		Dwell    time.Duration
+		Trigger  string   // region whose widget visibility activates the scene
+		Regions  []string // regions drawn while showing
+		Disabled bool
+		days     []int
+		from     time.Time
+		until    time.Time
+		window   bool
+		shown    time.Time
+	}
+
+	// SceneScheduler picks the scene for the lower zone, highest priority
+	// first, holding each for its dwell and rotating equal priorities
+	SceneScheduler struct {
+		scenes  []*Scene
+		widgets *WidgetRegistry
+		current *Scene
+		since   time.Time
+		forced  bool
+		force   *Scene // nil forces the full clock face
+		until   time.Time
+		mux     sync.Mutex
+	}
+)
+
+// defaultScenes keep the original cascade, LMS over MBTA over news, with
+// notifications over all
+var defaultScenes = []Scene{
+	{Name: `notify`, Priority: 40, Trigger: `notify`,
+		Regions: []string{`weather_pinned`, `notify`}},
+	{Name: `lms`, Priority: 30, Dwell: 10 * time.Second, Trigger: `lms`,
+		Regions: []string{`weather_pinned`, `lms_cover`, `lms_backdrop`, `lms_vu`, `lms_text`, `lms`, `lms_footer`, `lms_volume`}},
+	{Name: `queue`, Priority: 30, Dwell: 10 * time.Second, Trigger: `lms_queue`,
+		Regions: []string{`weather_pinned`, `lms_cover`, `lms_backdrop`, `lms_queue`, `lms`, `lms_footer`, `lms_volume`}},
+	{Name: `mbta`, Priority: 20, Dwell: 10 * time.Second, Trigger: `mbta`,
+		Regions: []string{`weather_pinned`, `mbta`}},
+	{Name: `news`, Priority: 10, Dwell: 10 * time.Second, Trigger: `news`,
+		Regions: []string{`weather_pinned`, `news`}},
+}
+
+// NewSceneScheduler builds the scenes, config.yml scenes entries override
+// the defaults or add custom scenes
+func NewSceneScheduler(widgets *WidgetRegistry, conf map[string]interface{}) (*SceneScheduler, error) {
+
+	ss := &SceneScheduler{widgets: widgets}
+	for _, d := range defaultScenes {
+		sc := d
+		sc.Regions = append([]string(nil), d.Regions...)
+		ss.scenes = append(ss.scenes, &sc)
+	}
+
+	names := make([]string, 0, len(conf))
+	for n := range conf {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		spec, err := cast.ToStringMapE(conf[n])
+		if err != nil {
+			return nil, fmt.Errorf("scenes.%s: %v", n, err)
+		}
+		if err = ss.apply(n, spec); err != nil {
+			return nil, fmt.Errorf("scenes.%s: %v", n, err)
+		}
+	}
+
+	return ss, nil
+
+}
+
+func (ss *SceneScheduler) apply(name string, spec map[string]interface{}) error {
+
+	var sc *Scene
+	for _, s := range ss.scenes {
+		if name == s.Name {
+			sc = s
+		}
+	}
+	if nil == sc {
+		sc = &Scene{Name: name, Trigger: name}
+		ss.scenes = append(ss.scenes, sc)
+	}
+
+	var err error
+	for k, v := range spec {
+		switch strings.ToLower(k) {
+		case `priority`:
+			sc.Priority, err = cast.ToIntE(v)
+		case `dwell`:
+			sc.Dwell, err = cast.ToDurationE(v)
+		case `trigger`:
+			sc.Trigger = cast.ToString(v)
+		case `regions`:
+			sc.Regions, err = cast.ToStringSliceE(v)
+		case `active`:
+			var active bool
+			active, err = cast.ToBoolE(v)
+			sc.Disabled = !active
+		case `days`:
+			sc.days, err = cast.ToIntSliceE(v) // 0=Sunday
+		case `from`:
+			sc.from, err = parseTime(cast.ToString(v))
+			sc.window = true
+		case `until`:
+			sc.until, err = parseTime(cast.ToString(v))
+			sc.window = true
+		default:
+			err = fmt.Errorf("unknown attribute %q", k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if 0 == len(sc.Regions) {
+		return fmt.Errorf("no regions")
+	}
+	return nil
+
+}
+
+// Allowed checks the day and time window rules
+func (sc *Scene) Allowed(t time.Time) bool {
+	if sc.Disabled {
+		return false
+	}
+	if len(sc.days) > 0 && !intInSlice(sc.days, int(t.Weekday())) {
+		return false
+	}
+	if !sc.window {
+		return true
+	}
+	check, _ := parseTime(t.Format("03:04 PM"))
+	if sc.until.Before(sc.from) {
+		// spans midnight
+		return !check.Before(sc.from) || check.Before(sc.until)
+	}
+	return !check.Before(sc.from) && check.Before(sc.until)
+}
+
+// Next returns the scene to show at t, nil leaves the full clock face
+func (ss *SceneScheduler) Next(t time.Time) *Scene {
+
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	if ss.forced {
+		if ss.until.IsZero() || t.Before(ss.until) {
+			if ss.force != ss.current {
+				ss.current = ss.force
+				ss.since = t
+			}
+			return ss.force
+		}
+		ss.forced = false
+	}
+
+	var top []*Scene
+	held := false
+	for _, sc := range ss.scenes {
+		if !sc.Allowed(t) || !ss.widgets.Visible(sc.Trigger) {
+			continue
+		}
+		if sc == ss.current {
+			held = t.Sub(ss.since) < sc.Dwell
+		}
+		if 0 == len(top) || sc.Priority > top[0].Priority {
+			top = []*Scene{sc}
+		} else if sc.Priority == top[0].Priority {
+			top = append(top, sc)
+		}
+	}
+
+	// dwell holds only against equal or lower priorities, a scene that
+	// outranks the current one shows at once
+	if held && top[0].Priority <= ss.current.Priority {
+		return ss.current
+	}
+
+	// rotate to the least recently shown of the top priority
+	var next *Scene
+	for _, sc := range top {
+		if sc == ss.current && len(top) > 1 {
+			continue
+		}
+		if nil == next || sc.shown.Before(next.shown) {
+			next = sc
+		}
+	}
+
+	if next != ss.current {
+		ss.current = next
+		ss.since = t
+	}
+	if nil != next {
+		next.shown = t
+	}
+	return next
+
+}
+
+// Current returns the scene showing, nil when none
+func (ss *SceneScheduler) Current() *Scene {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+	return ss.current
+}
+
+// Force shows the named scene regardless of triggers until the given time,
+// forever when zero; "none" forces the full clock face and an empty name
+// returns to scheduling
+func (ss *SceneScheduler) Force(name string, until time.Time) error {
+
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	switch name {
+	case ``:
+		ss.forced = false
+		return nil
+	case `none`:
+		ss.forced, ss.force, ss.until = true, nil, until
+		return nil
+	}
+	for _, sc := range ss.scenes {
+		if name == sc.Name {
+			ss.forced, ss.force, ss.until = true, sc, until
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown scene %q", name)
+
+}
+
+// Names returns the configured scene names
+func (ss *SceneScheduler) Names() []string {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+	names := make([]string, 0, len(ss.scenes))
+	for _, sc := range ss.scenes {
+		names = append(names, sc.Name)
+	}
+	return names
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// testScenes a scheduler over lms, mbta and news at 20, 20 and 10 with a
+// 10s dwell and notify at 40, each triggered while on names it
+func testScenes(on map[string]bool) *SceneScheduler {
+	wr := NewWidgetRegistry()
+	ss := &SceneScheduler{widgets: wr}
+	for _, sc := range []Scene{
+		{Name: `notify`, Priority: 40, Trigger: `notify`},
+		{Name: `lms`, Priority: 20, Dwell: 10 * time.Second, Trigger: `lms`},
+		{Name: `mbta`, Priority: 20, Dwell: 10 * time.Second, Trigger: `mbta`},
+		{Name: `news`, Priority: 10, Dwell: 10 * time.Second, Trigger: `news`},
+	} {
+		name, s := sc.Name, sc
+		wr.Register(name, Every(time.Second, func() bool { return on[name] }, func(*gg.Context, *Region) {}))
+		ss.scenes = append(ss.scenes, &s)
+	}
+	return ss
+}
+
+func sceneName(sc *Scene) string {
+	if nil == sc {
+		return `none`
+	}
+	return sc.Name
+}
+
+func TestSceneSchedule(t *testing.T) {
+
+	t0 := time.Date(2020, time.October, 16, 10, 0, 0, 0, time.UTC)
+	on := map[string]bool{}
+	ss := testScenes(on)
+
+	for _, step := range []struct {
+		name string
+		at   time.Duration
+		set  map[string]bool
+		want string
+	}{
+		{`nothing active`, 0, nil, `none`},
+		{`lms starts`, time.Second, map[string]bool{`lms`: true}, `lms`},
+		{`lower priority waits`, 2 * time.Second, map[string]bool{`news`: true}, `lms`},
+		{`equal priority waits out the dwell`, 3 * time.Second, map[string]bool{`mbta`: true}, `lms`},
+		{`higher priority preempts the dwell`, 4 * time.Second, map[string]bool{`notify`: true}, `notify`},
+		{`back after the notification`, 5 * time.Second, map[string]bool{`notify`: false}, `mbta`},
+		{`held for its dwell`, 14 * time.Second, nil, `mbta`},
+		{`rotates after the dwell`, 16 * time.Second, nil, `lms`},
+		{`rotates back`, 27 * time.Second, nil, `mbta`},
+		{`trigger gone`, 28 * time.Second, map[string]bool{`mbta`: false}, `lms`},
+		{`falls to the lower priority`, 40 * time.Second, map[string]bool{`lms`: false}, `news`},
+	} {
+		for k, v := range step.set {
+			on[k] = v
+		}
+		if got := sceneName(ss.Next(t0.Add(step.at))); step.want != got {
+			t.Errorf("%s: got %s, want %s", step.name, got, step.want)
+		}
+	}
+
+}
+
+func TestSceneForce(t *testing.T) {
+
+	t0 := time.Date(2020, time.October, 16, 10, 0, 0, 0, time.UTC)
+	ss := testScenes(map[string]bool{`lms`: true})
+
+	if err := ss.Force(`news`, t0.Add(time.Minute)); nil != err {
+		t.Fatal(err)
+	}
+	if got := sceneName(ss.Next(t0)); `news` != got {
+		t.Errorf("forced got %s", got)
+	}
+	if got := sceneName(ss.Next(t0.Add(2 * time.Minute))); `lms` != got {
+		t.Errorf("after the force got %s", got)
+	}
+	if err := ss.Force(`weather`, time.Time{}); nil == err {
+		t.Error("forced an unknown scene")
+	}
+
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 //SSE name constants
@@ -57,11 +59,12 @@ func hasPrefix(s []byte, prefix string) bool {
 	return bytes.HasPrefix(s, []byte(prefix))
 }
 
-func liveReq(verb, uri string, body io.Reader) (*http.Request, error) {
+func liveReq(ctx context.Context, verb, uri string, body io.Reader) (*http.Request, error) {
 	req, err := getReq(verb, uri, body)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	req.Header.Set(`Accept`, `text/event-stream`)
 	req.Header.Set(`Cache-Control`, `no-cache`)
@@ -75,20 +78,23 @@ var getReq = func(verb, uri string, body io.Reader) (*http.Request, error) {
 	return http.NewRequest(verb, uri, body)
 }
 
-func ssenotifyacquire(uri string, evCh chan<- *SSEvent) error {
+func ssenotifyacquire(ctx context.Context, uri string, evCh chan<- *SSEvent) error {
 	for {
-		foo, err := ssenotify(uri, evCh)
+		foo, err := ssenotify(ctx, uri, evCh)
 		if foo < 0 {
 			return err
 		}
+		if nil != ctx.Err() {
+			sendEvent(ctx, evCh, &SSEvent{URI: uri, Active: false}) // deactivate consumer and channel
+			return nil
+		}
 	}
-	return nil
 }
 
-func ssenotify(uri string, evCh chan<- *SSEvent) (int, error) {
+func ssenotify(ctx context.Context, uri string, evCh chan<- *SSEvent) (int, error) {
 
 	if evCh == nil {
-		fmt.Println(`ssenotify`, ErrNilChan)
+		logSSES.Error(`notify failed`, `err`, ErrNilChan)
 		return 0, ErrNilChan
 	}
 
@@ -97,57 +103,78 @@ func ssenotify(uri string, evCh chan<- *SSEvent) (int, error) {
 	// prime for exception status
 	thisEvent = &SSEvent{URI: uri, Active: false}
 
-	req, err := liveReq("GET", uri, nil)
+	req, err := liveReq(ctx, "GET", uri, nil)
 	if err != nil {
 		ef := fmt.Errorf("error getting sse request: %v", err)
-		fmt.Printf("%v\n", ef)
-		evCh <- thisEvent // deactivate consumer and channel
+		logSSES.Error(`stream failed`, `url`, uri, `err`, err)
+		sendEvent(ctx, evCh, thisEvent) // deactivate consumer and channel
 		return -1, ef
 	}
 
 	res, err := sseClient.Do(req)
 	if err != nil {
 		ef := fmt.Errorf("error performing request for %s: %v", uri, err)
-		fmt.Printf("%v\n", ef)
-		evCh <- thisEvent // deactivate consumer and channel
+		logSSES.Error(`stream failed`, `url`, uri, `err`, err)
+		sendEvent(ctx, evCh, thisEvent) // deactivate consumer and channel
 		return -1, ef
 	}
 
-	br := bufio.NewReader(res.Body)
 	defer res.Body.Close()
+	return ssescan(ctx, res.Body, uri, evCh, 0)
+}
+
+// ssescan sends the data events read from r, pace apart when not zero,
+// until the end of r or ctx is done; n the events sent
+func ssescan(ctx context.Context, r io.Reader, uri string, evCh chan<- *SSEvent, pace time.Duration) (int, error) {
+
+	br := bufio.NewReader(r)
+	n := 0
 
 	delim := []byte{':', ' '}
-	foo := 0
-	for {
+	for nil == ctx.Err() {
 
 		bs, err := br.ReadBytes('\n')
 
 		if err != nil && err != io.EOF {
-			return 0, err
-		}
-
-		if len(bs) < 2 {
-			continue
+			return n, err
 		}
 
 		spl := bytes.Split(bs, delim)
 
-		if len(spl) < 2 {
-			continue
-		}
-
-		thisEvent = &SSEvent{URI: uri, Active: true}
-		switch string(spl[0]) {
-		case eventTag:
-			thisEvent.Type = string(bytes.TrimSpace(spl[1]))
-		case dataTag:
-			thisEvent.Data = bytes.NewBuffer(bytes.TrimSpace(spl[1]))
-			evCh <- thisEvent
+		if len(bs) >= 2 && len(spl) >= 2 {
+			thisEvent := &SSEvent{URI: uri, Active: true}
+			switch string(spl[0]) {
+			case eventTag:
+				thisEvent.Type = string(bytes.TrimSpace(spl[1]))
+			case dataTag:
+				thisEvent.Data = bytes.NewBuffer(bytes.TrimSpace(spl[1]))
+				if !sendEvent(ctx, evCh, thisEvent) {
+					return n, nil
+				}
+				n++
+				if pace > 0 {
+					select {
+					case <-time.After(pace):
+					case <-ctx.Done():
+					}
+				}
+			}
 		}
 		if err == io.EOF {
 			break
 		}
 	}
 
-	return foo, nil
+	return n, nil
+}
+
+// sendEvent hands ev to the consumer, false if ctx is done first as the
+// consumer may be gone
+func sendEvent(ctx context.Context, evCh chan<- *SSEvent, ev *SSEvent) bool {
+	select {
+	case evCh <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// SunTimes one day's sunrise and sunset and the ends of twilight around
+// them, zero where the sun never reaches the elevation that day
+type SunTimes struct {
+	Rise   time.Time
+	Set    time.Time
+	Dawn   map[string]time.Time // twilight starts, civil, nautical, astronomical
+	Dusk   map[string]time.Time // and ends
+	Source string               // local, or weather when the server's times are used
+}
+
+// twilight depths, degrees the sun is below the horizon as each ends
+var twilights = map[string]float64{
+	`civil`:        6,
+	`nautical`:     12,
+	`astronomical`: 18,
+}
+
+// sunHorizon the elevation of sunrise and sunset, refraction and the
+// sun's radius lift it above the true horizon
+const sunHorizon = -0.833
+
+// julian cycle correction, J0
+const sunJ0 = 0.0009
+
+var (
+	// sunWeather prefer the weather server's sunrise and sunset, sun.weather
+	sunWeather bool
+	sunToday   SunTimes
+	// sunMux guards sunToday, the weather server's sunrise and sunset,
+	// daymode and evut, the weather goroutine writes them as the face,
+	// the dimmer and the API read them
+	sunMux sync.Mutex
+)
+
+func solarMeanAnomaly(d float64) float64 { return rad * (357.5291 + 0.98560028*d) }
+
+func eclipticLongitude(M float64) float64 {
+	C := rad * (1.9148*sin(M) + 0.02*sin(2*M) + 0.0003*sin(3*M)) // equation of center
+	return M + C + rad*102.9372 + math.Pi                        // perihelion of the Earth
+}
+
+// sunCoords declination and right ascension of the sun d days from J2000,
+// the ecliptic conversions the moon uses
+func sunCoords(d float64) (dec, ra float64) {
+	var m Moon
+	L := eclipticLongitude(solarMeanAnomaly(d))
+	return m.declination(L, 0), m.rightAscension(L, 0)
+}
+
+// sunElevation degrees above the horizon of the sun at t, seen from lat,
+// lng, negative below it
+func sunElevation(t time.Time, lat, lng float64) float64 {
+	var m Moon
+	d := toDays(t)
+	dec, ra := sunCoords(d)
+	H := m.siderealTime(d, rad*-lng) - ra
+	return rad2deg(m.calcAltitude(H, rad*lat, dec))
+}
+
+// localSunTimes works out the sun times of day's date, in its location,
+// seen from lat, lng
+func localSunTimes(day time.Time, lat, lng float64) SunTimes {
+
+	var m Moon
+	y, mo, dd := day.Date()
+	lw, phi := rad*-lng, rad*lat
+
+	d := toDays(time.Date(y, mo, dd, 12, 0, 0, 0, day.Location()))
+	// J0 only picks the day, in the transit it puts every time 78s late
+	n := math.Round(d - sunJ0 - lw/(2*math.Pi))
+	ds := lw/(2*math.Pi) + n
+	M := solarMeanAnomaly(ds)
+	L := eclipticLongitude(M)
+	dec := m.declination(L, 0)
+	noon := J2000 + ds + 0.0053*sin(M) - 0.0069*sin(2*L)
+
+	// rise and set, the times either side of noon the sun is at h
+	at := func(h float64) (rise, set time.Time) {
+		c := (sin(rad*h) - sin(phi)*sin(dec)) / (cos(phi) * cos(dec))
+		if c < -1 || c > 1 {
+			return
+		}
+		a := (math.Acos(c)+lw)/(2*math.Pi) + n
+		js := J2000 + a + 0.0053*sin(M) - 0.0069*sin(2*L)
+		loc := day.Location()
+		return fromJulian(noon - (js - noon)).In(loc), fromJulian(js).In(loc)
+	}
+
+	st := SunTimes{Dawn: make(map[string]time.Time), Dusk: make(map[string]time.Time), Source: `local`}
+	st.Rise, st.Set = at(sunHorizon)
+	for name, depth := range twilights {
+		st.Dawn[name], st.Dusk[name] = at(-depth)
+	}
+	return st
+
+}
+
+// weatherSunTimes the weather server's sunrise and sunset on day's date,
+// false until it has reported them
+func weatherSunTimes(day time.Time) (rise, set time.Time, ok bool) {
+	sunMux.Lock()
+	sr, ss := sunrise, sunset
+	sunMux.Unlock()
+	if sr.IsZero() || ss.IsZero() {
+		return
+	}
+	y, m, d := day.Date()
+	on := func(t time.Time) time.Time {
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, day.Location())
+	}
+	return on(sr), on(ss), true
+}
+
+// sunTimes for now's date: local from moon.lat and lng, the weather
+// server's sunrise and sunset when sun.weather is set or there is no
+// location
+func sunTimes(now time.Time) SunTimes {
+	located := 0 != lat || 0 != lng
+	st := SunTimes{Source: `none`}
+	if located {
+		st = localSunTimes(now, lat, lng)
+	}
+	if sunWeather || !located {
+		if rise, set, ok := weatherSunTimes(now); ok {
+			st.Rise, st.Set, st.Source = rise, set, `weather`
+		}
+	}
+	return st
+}
+
+// updateDaylight sets daymode and the time to the next sunrise or sunset
+// as of now, true when day turned to night or back
+func updateDaylight(now time.Time) bool {
+
+	st := sunTimes(now)
+	var dm daylight
+	var next string
+	m := now.Truncate(time.Minute)
+	switch {
+	case st.Rise.IsZero() || st.Set.IsZero():
+		// polar day or night, or nothing known yet
+		up := false
+		if `local` == st.Source {
+			up = sunElevation(now, lat, lng) > sunHorizon
+		}
+		dm = daylight{nightbright, up}
+		if up {
+			dm.brightness = daybright
+		}
+	case now.After(st.Rise) && now.Before(st.Set):
+		dm = daylight{daybright, true}
+		next = nextEvent(m, st.Set.Truncate(time.Minute))
+	case now.Before(st.Rise):
+		dm = daylight{nightbright, false}
+		next = nextEvent(m, st.Rise.Truncate(time.Minute))
+	default:
+		dm = daylight{nightbright, false}
+		next = nextEvent(m, sunTimes(now.AddDate(0, 0, 1)).Rise.Truncate(time.Minute))
+	}
+
+	sunMux.Lock()
+	defer sunMux.Unlock()
+	ref := daymode
+	sunToday, daymode, evut = st, dm, next
+	return ref.isdaylight != daymode.isdaylight
+
+}
+
+// currentDaylight daymode and the time to the next sunrise or sunset
+func currentDaylight() (daylight, string) {
+	sunMux.Lock()
+	defer sunMux.Unlock()
+	return daymode, evut
+}
+
+// sunStatus today's sun times for the API
+func sunStatus() map[string]interface{} {
+	sunMux.Lock()
+	defer sunMux.Unlock()
+	st := map[string]interface{}{`source`: sunToday.Source}
+	add := func(k string, t time.Time) {
+		if !t.IsZero() {
+			st[k] = t
+		}
+	}
+	add(`sunrise`, sunToday.Rise)
+	add(`sunset`, sunToday.Set)
+	for name := range twilights {
+		add(name+`_dawn`, sunToday.Dawn[name])
+		add(name+`_dusk`, sunToday.Dusk[name])
+	}
+	return st
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+
+	for _, tc := range []struct {
+		place    string
+		zone     string
+		lat, lng float64
+		day      string
+		rise     string // almanac minute, local time, empty for none
+		set      string
+	}{
+		{`London midsummer`, `Europe/London`, 51.5074, -0.1278, `2020-06-21`, `04:43`, `21:21`},
+		{`London midwinter`, `Europe/London`, 51.5074, -0.1278, `2020-12-21`, `08:03`, `15:53`},
+		{`Sydney midsummer`, `Australia/Sydney`, -33.8688, 151.2093, `2020-12-21`, `05:41`, `20:05`},
+		{`Tromsø polar day`, `Europe/Oslo`, 69.6492, 18.9553, `2020-06-21`, ``, ``},
+		{`Tromsø polar night`, `Europe/Oslo`, 69.6492, 18.9553, `2020-12-21`, ``, ``},
+	} {
+		loc, err := time.LoadLocation(tc.zone)
+		if nil != err {
+			t.Fatal(err)
+		}
+		day, _ := time.ParseInLocation(`2006-01-02`, tc.day, loc)
+		st := localSunTimes(day, tc.lat, tc.lng)
+		for _, c := range []struct {
+			name string
+			got  time.Time
+			want string
+		}{{`rise`, st.Rise, tc.rise}, {`set`, st.Set, tc.set}} {
+			if `` == c.want {
+				if !c.got.IsZero() {
+					t.Errorf("%s: %s at %v, want none", tc.place, c.name, c.got)
+				}
+				continue
+			}
+			want, _ := time.ParseInLocation(`2006-01-02 15:04`, tc.day+` `+c.want, loc)
+			if d := c.got.Sub(want); d < -time.Minute || d > time.Minute {
+				t.Errorf("%s: %s at %s, almanac %s", tc.place, c.name, c.got.Format(`15:04:05`), c.want)
+			}
+		}
+		if st.Rise.IsZero() {
+			continue
+		}
+		// twilight deepens away from the horizon
+		if !(st.Dawn[`nautical`].Before(st.Dawn[`civil`]) && st.Dawn[`civil`].Before(st.Rise) &&
+			st.Set.Before(st.Dusk[`civil`]) && st.Dusk[`civil`].Before(st.Dusk[`nautical`])) {
+			t.Errorf("%s: twilight out of order, dawn %v dusk %v", tc.place, st.Dawn, st.Dusk)
+		}
+	}
+
+}
+
+func TestSunPolar(t *testing.T) {
+
+	defer func(la, ln float64, ws bool, dm daylight) {
+		lat, lng, sunWeather, daymode = la, ln, ws, dm
+	}(lat, lng, sunWeather, daymode)
+	lat, lng, sunWeather = 69.6492, 18.9553, false
+
+	for _, tc := range []struct {
+		name  string
+		at    time.Time
+		up    bool
+		civil bool // civil twilight reached
+	}{
+		{`midnight sun`, time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC), true, false},
+		{`polar night noon`, time.Date(2020, time.December, 21, 10, 0, 0, 0, time.UTC), false, true},
+	} {
+		if up := sunElevation(tc.at, lat, lng) > sunHorizon; tc.up != up {
+			t.Errorf("%s: sun up %v", tc.name, up)
+		}
+		updateDaylight(tc.at)
+		if tc.up != daymode.isdaylight {
+			t.Errorf("%s: daylight %v", tc.name, daymode.isdaylight)
+		}
+		if `` != evut {
+			t.Errorf("%s: next event %q, want none", tc.name, evut)
+		}
+		st := localSunTimes(tc.at, lat, lng)
+		if civil := !st.Dawn[`civil`].IsZero(); tc.civil != civil {
+			t.Errorf("%s: civil twilight %v", tc.name, civil)
+		}
+	}
+
+}
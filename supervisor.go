@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supervisor owns the background workers, restarting those that crash
+// and stopping them all on shutdown
+type Supervisor struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	closing bool
+	mux     sync.Mutex
+}
+
+// restart backoff, doubling from min to max, reset after a long run;
+// shutdownGrace bounds the wait for workers on exit
+const (
+	backoffMin    = time.Second
+	backoffMax    = time.Minute
+	shutdownGrace = 5 * time.Second
+)
+
+var supervisor = NewSupervisor()
+
+var mWorkerRestarts = metrics.CounterVec(`rgbclock_worker_restarts_total`, `Background workers restarted after a crash.`, `worker`)
+
+// NewSupervisor creates a supervisor, Cancel stops its workers
+func NewSupervisor() *Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Supervisor{ctx: ctx, cancel: cancel}
+}
+
+// Context is done once shutdown starts
+func (sv *Supervisor) Context() context.Context {
+	return sv.ctx
+}
+
+// Cancel starts shutdown, workers see the context done
+func (sv *Supervisor) Cancel() {
+	sv.cancel()
+}
+
+// track adds a worker, false once shutdown is under way
+func (sv *Supervisor) track() bool {
+	sv.mux.Lock()
+	defer sv.mux.Unlock()
+	if sv.closing || nil != sv.ctx.Err() {
+		return false
+	}
+	sv.wg.Add(1)
+	return true
+}
+
+// Go runs the worker until it returns nil or the context is done, an
+// error or panic restarts it after a backoff
+func (sv *Supervisor) Go(name string, run func(ctx context.Context) error) {
+	sv.GoContext(sv.ctx, name, run)
+}
+
+// GoContext runs the worker as Go, ctx derived from Context ends it early
+func (sv *Supervisor) GoContext(ctx context.Context, name string, run func(ctx context.Context) error) {
+
+	if !sv.track() {
+		return
+	}
+
+	go func() {
+		defer sv.wg.Done()
+		backoff := backoffMin
+		for {
+			started := time.Now()
+			err := sv.run(ctx, run)
+			if nil == err || nil != ctx.Err() {
+				return
+			}
+			if time.Since(started) > backoffMax {
+				backoff = backoffMin
+			}
+			mWorkerRestarts.With(name).Inc()
+			logMain.Warn(`worker failed, restarting`, `worker`, name, `err`, err, `backoff`, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > backoffMax {
+				backoff = backoffMax
+			}
+		}
+	}()
+
+}
+
+func (sv *Supervisor) run(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// Every calls what then waits delay, until stopped or shut down, the
+// stop channel is buffered so a late stop never blocks
+func (sv *Supervisor) Every(name string, what func(), delay time.Duration) chan bool {
+
+	stop := make(chan bool, 1)
+	sv.Go(name, func(ctx context.Context) error {
+		for {
+			what()
+			select {
+			case <-time.After(delay):
+			case <-stop:
+				return nil
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return stop
+
+}
+
+// AfterFunc calls f once after d unless shut down first, a panic is
+// logged rather than taking the clock down
+func (sv *Supervisor) AfterFunc(name string, d time.Duration, f func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		if !sv.track() {
+			return
+		}
+		defer sv.wg.Done()
+		if err := sv.run(sv.ctx, func(context.Context) error { f(); return nil }); nil != err {
+			mWorkerRestarts.With(name).Inc()
+			logMain.Error(`timer failed`, `worker`, name, `err`, err)
+		}
+	})
+}
+
+// Wait for the workers to end, up to timeout, false if some did not
+func (sv *Supervisor) Wait(timeout time.Duration) bool {
+
+	sv.mux.Lock()
+	sv.closing = true
+	sv.mux.Unlock()
+	sv.cancel()
+
+	done := make(chan bool)
+	go func() {
+		sv.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		logMain.Warn(`workers still running at exit`, `timeout`, timeout)
+		return false
+	}
+
+}
+
+// funcName short name of f for logs, weather or (*MBTA).getPredicted
+func funcName(f interface{}) string {
+	n := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	n = strings.TrimSuffix(n, `-fm`)
+	return strings.TrimPrefix(n[strings.LastIndex(n, `/`)+1:], `main.`)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSupervisorRestart a failing worker is run again after the backoff,
+// a panic counts as a failure, nil ends it
+func TestSupervisorRestart(t *testing.T) {
+
+	sv := NewSupervisor()
+	restarts := mWorkerRestarts.With(`restart-test`).Value()
+	var runs int32
+	done := make(chan time.Time, 3)
+	start := time.Now()
+	sv.Go(`restart-test`, func(ctx context.Context) error {
+		done <- time.Now()
+		switch atomic.AddInt32(&runs, 1) {
+		case 1:
+			return errors.New(`lost`)
+		case 2:
+			panic(`crashed`)
+		}
+		return nil
+	})
+
+	var at []time.Time
+	for len(at) < 3 {
+		select {
+		case t0 := <-done:
+			at = append(at, t0)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%d runs, want 3", len(at))
+		}
+	}
+	if d := at[1].Sub(start); d < backoffMin {
+		t.Errorf("restarted after %v, want a %v backoff", d, backoffMin)
+	}
+	if d := at[2].Sub(at[1]); d < 2*backoffMin {
+		t.Errorf("second restart after %v, want the backoff doubled to %v", d, 2*backoffMin)
+	}
+	if !sv.Wait(time.Second) {
+		t.Error("worker still running after returning nil")
+	}
+	if n := mWorkerRestarts.With(`restart-test`).Value() - restarts; 2 != n {
+		t.Errorf("%d restarts counted, want 2", n)
+	}
+	if n := atomic.LoadInt32(&runs); 3 != n {
+		t.Errorf("%d runs, want 3", n)
+	}
+
+}
+
+func TestSupervisorEvery(t *testing.T) {
+
+	sv := NewSupervisor()
+	var calls int32
+	stop := sv.Every(`every-test`, func() { atomic.AddInt32(&calls, 1) }, 5*time.Millisecond)
+	for end := time.Now().Add(5 * time.Second); atomic.LoadInt32(&calls) < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatalf("%d calls, want 3", atomic.LoadInt32(&calls))
+		}
+	}
+	stop <- true
+	if !sv.Wait(time.Second) {
+		t.Fatal("stopped worker still running")
+	}
+	n := atomic.LoadInt32(&calls)
+	time.Sleep(20 * time.Millisecond)
+	if n != atomic.LoadInt32(&calls) {
+		t.Error("called after stop")
+	}
+	// a stop after shut down does not block
+	stop <- true
+
+}
+
+func TestSupervisorAfterFunc(t *testing.T) {
+
+	sv := NewSupervisor()
+	fired := make(chan bool, 1)
+	sv.AfterFunc(`after-test`, 5*time.Millisecond, func() { fired <- true })
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer never fired")
+	}
+
+	// a panic is counted, not fatal
+	failed := mWorkerRestarts.With(`after-panic`).Value()
+	panicked := make(chan bool)
+	sv.AfterFunc(`after-panic`, time.Millisecond, func() { close(panicked); panic(`boom`) })
+	<-panicked
+	for end := time.Now().Add(5 * time.Second); failed == mWorkerRestarts.With(`after-panic`).Value(); time.Sleep(time.Millisecond) {
+		if time.Now().After(end) {
+			t.Fatal("timer panic not counted")
+		}
+	}
+
+	// none fire once shut down
+	late := sv.AfterFunc(`after-late`, 20*time.Millisecond, func() { fired <- true })
+	defer late.Stop()
+	sv.Wait(time.Second)
+	select {
+	case <-fired:
+		t.Error("fired after shut down")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+}
+
+// TestSupervisorWaitTimeout a worker ignoring shut down leaves Wait to
+// give up after the grace period
+func TestSupervisorWaitTimeout(t *testing.T) {
+
+	sv := NewSupervisor()
+	release := make(chan bool)
+	defer close(release)
+	sv.Go(`stuck-test`, func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	if sv.Wait(50 * time.Millisecond) {
+		t.Fatal("waited out a stuck worker")
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Errorf("gave up after %v, want the 50ms grace", d)
+	}
+	// shut down, nothing new starts
+	var started int32
+	sv.Go(`late-test`, func(context.Context) error { atomic.AddInt32(&started, 1); return nil })
+	time.Sleep(10 * time.Millisecond)
+	if 0 != atomic.LoadInt32(&started) {
+		t.Error("worker started after shut down")
+	}
+
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+)
+
+type (
+	// TimeSource tells the clock the time, the wall clock or a replayed day
+	TimeSource interface {
+		// Now the current time
+		Now() time.Time
+		// Wall the real time d of this source's time takes
+		Wall(d time.Duration) time.Duration
+	}
+
+	wallTime struct{}
+
+	// ReplayTime runs from start at speed times the wall clock
+	ReplayTime struct {
+		start time.Time
+		epoch time.Time
+		speed float64
+	}
+)
+
+// replayMinWait keeps a fast replay from polling in a tight loop
+const replayMinWait = 100 * time.Millisecond
+
+// timeSource the time the clock shows and schedules by
+var timeSource TimeSource = wallTime{}
+
+// timeNow the time of the time source
+func timeNow() time.Time {
+	return timeSource.Now()
+}
+
+// wallDelay d of the time source in real time, for schedules
+func wallDelay(d time.Duration) time.Duration {
+	return timeSource.Wall(d)
+}
+
+func (wallTime) Now() time.Time {
+	return time.Now()
+}
+
+func (wallTime) Wall(d time.Duration) time.Duration {
+	return d
+}
+
+// NewReplayTime starts a replay at start, speed 1 is real time
+func NewReplayTime(start time.Time, speed float64) *ReplayTime {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &ReplayTime{start: start, epoch: time.Now(), speed: speed}
+}
+
+// Now the replayed time
+func (rt *ReplayTime) Now() time.Time {
+	return rt.start.Add(time.Duration(float64(time.Since(rt.epoch)) * rt.speed))
+}
+
+// Wall d shortened by the speed, no less than replayMinWait
+func (rt *ReplayTime) Wall(d time.Duration) time.Duration {
+	if d = time.Duration(float64(d) / rt.speed); d < replayMinWait {
+		d = replayMinWait
+	}
+	return d
+}
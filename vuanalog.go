@@ -21,7 +21,7 @@ func (ls *LMSServer) initVUBase() {
 
 	vuf, err := os.Open(ls.vulayout.base)
 	if err != nil {
-		fmt.Println(`Open exception`, ls.vulayout.base, `:`, err)
+		logLMS.Error(`meter open failed`, `file`, ls.vulayout.base, `err`, err)
 		panic(err)
 	}
 	defer vuf.Close()
@@ -32,7 +32,7 @@ func (ls *LMSServer) initVUBase() {
 
 		vu, err := png.Decode(vuf)
 		if err != nil {
-			fmt.Println(`Decode PNG exception`, ls.vulayout.base, `:`, err)
+			logLMS.Error(`meter decode failed`, `file`, ls.vulayout.base, `err`, err)
 			panic(err)
 		}
 
@@ -93,7 +93,7 @@ func (ls *LMSServer) initVUBase() {
 
 		iconI, err := oksvg.ReadIconStream(iconMem)
 		if err != nil {
-			fmt.Println(iconMem.String())
+			logLMS.Error(`meter svg parse failed`, `file`, ls.vulayout.base, `err`, err)
 			panic(err)
 		}
 
@@ -319,7 +319,7 @@ func (ls *LMSServer) vuAnalogNotUsed(channel string, swing, sw, sh int) (img dra
 
 	canvas.End()
 
-	fmt.Println(iconMem.String())
+	logLMS.Debug(`meter svg`, `svg`, iconMem.String())
 	iconI, err := oksvg.ReadIconStream(iconMem)
 	if err != nil {
 		return img, err
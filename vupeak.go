@@ -69,7 +69,7 @@ func (ls *LMSServer) vuPeak(dBfs [2]int32) {
 
 	iconI, err := oksvg.ReadIconStream(iconMem)
 	if err != nil {
-		fmt.Println(iconMem.String())
+		logLMS.Error(`peak meter svg parse failed`, `svg`, iconMem.String(), `err`, err)
 		panic(err)
 	}
 
@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"image/draw"
@@ -70,7 +72,6 @@ func parseTime(t string) (time.Time, error) {
 
 func cacheImage(current string, ic iconCache, scale float64, color string) (iconCache, error) {
 
-	var err error
 	if ic.last != current {
 
 		i := getIcon(current)
@@ -82,15 +83,16 @@ func cacheImage(current string, ic iconCache, scale float64, color string) (icon
 		}
 
 		ic.m.Lock()
-		ic.image, err = getImageIconWIP(i)
+		im, err := getImageIconWIP(i)
 		if err != nil {
-			fmt.Println(err)
-			ic.image, err = getImageIcon(i)
+			logWeather.Debug(`icon fallback render`, `icon`, current, `err`, err)
+			im, err = getImageIcon(i)
 		}
 		if err != nil {
 			ic.m.Unlock()
 			return ic, err
 		}
+		ic.image = im
 		ic.last = current
 		ic.m.Unlock()
 		return ic, nil
@@ -100,15 +102,15 @@ func cacheImage(current string, ic iconCache, scale float64, color string) (icon
 
 func cacheThermo(current string, ic iconCache, sw, sh int) (iconCache, error) {
 
-	var err error
 	if ic.last != current {
 
 		ic.m.Lock()
-		ic.image, err = thermometer(sw, sh)
+		im, err := thermometer(sw, sh)
 		if err != nil {
 			ic.m.Unlock()
 			return ic, err
 		}
+		ic.image = im
 		ic.last = current
 		ic.m.Unlock()
 		return ic, nil
@@ -118,15 +120,15 @@ func cacheThermo(current string, ic iconCache, sw, sh int) (iconCache, error) {
 
 func cacheImageMethod(current string, ic iconCache, sw, sh int, f func(int, int) (draw.Image, error)) (iconCache, error) {
 
-	var err error
 	if ic.last != current {
 
 		ic.m.Lock()
-		ic.image, err = f(sw, sh)
+		im, err := f(sw, sh)
 		if err != nil {
 			ic.m.Unlock()
 			return ic, err
 		}
+		ic.image = im
 		ic.last = current
 		ic.m.Unlock()
 		return ic, nil
@@ -134,85 +136,193 @@ func cacheImageMethod(current string, ic iconCache, sw, sh int, f func(int, int)
 	return ic, nil
 }
 
+// weatherMux guards w, the fetch replaces it while the face and the API
+// read it
+var weatherMux sync.RWMutex
+
+// currentWeather a copy of the last good payload
+func currentWeather() Weather {
+	weatherMux.RLock()
+	defer weatherMux.RUnlock()
+	return w
+}
+
 var lastHour int = -1
 var snap bool = true
 var lastPrice float64 = 0.00
 
+// weather refreshes the conditions, a failure keeps the last good state
 func weather() {
+	err := fetchWeather()
+	mWeatherFetch.With(outcome(err)).Inc()
+	if nil != err {
+		health.Fail(err)
+		// the sun goes on without the weather server
+		if updateDaylight(timeNow()) {
+			cacheWeatherIcons(true)
+		}
+		return
+	}
+	atomic.StoreInt64(&weatherFetched, timeNow().Unix())
+	health.OK(`weather`)
+}
+
+func fetchWeather() error {
 
 	snap = false
-	test := ``
+	if nil != fixtures {
+		now := timeNow()
+		resp, err := fixtures.Payload(`weather.json`, now)
+		if nil != err {
+			return sourceError(`weather`, `fetch`, err)
+		}
+		return applyWeather(resp, now)
+	}
+
 	var netClient = &http.Client{
 		Timeout: time.Second * 5,
 	}
 	res, err := netClient.Get(weatherserveruri)
 	if nil != err {
-		return
+		return sourceError(`weather`, `fetch`, err)
 	}
 
 	resp, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
 	if nil != err {
-		return
+		return sourceError(`weather`, `fetch`, err)
 	}
+	return applyWeather(resp, timeNow())
 
-	err = json.Unmarshal(resp, &w)
-	checkFatal(err)
+}
 
-	w.Current.Temperature =
-		strings.Replace(w.Current.Temperature, ".0", "", -1)
+// applyWeather takes a weather server payload as of now, the last good
+// payload stays if it does not decode
+func applyWeather(resp []byte, now time.Time) (err error) {
 
-	test = w.Current.Sunrise + "-" + w.Current.Sunset
+	// validate before replacing the last good payload
+	var nw Weather
+	if err = json.Unmarshal(resp, &nw); nil != err {
+		return sourceError(`weather`, `decode`, err)
+	}
+	re := regexp.MustCompile(`[-]?\d[\d,]*[\.]?[\d{2}]*`)
+	temps := re.FindAllString(nw.Current.Temperature, -1)
+	if len(temps) < 2 {
+		return sourceError(`weather`, `decode`, fmt.Errorf("temperature %q not F and C", nw.Current.Temperature))
+	}
+	test := nw.Current.Sunrise + "-" + nw.Current.Sunset
 	if lastHorizon != test {
-		sunrise, err = parseTime(w.Current.Sunrise)
-		checkFatal(err)
-		sunset, err = parseTime(w.Current.Sunset)
-		checkFatal(err)
+		sr, err := parseTime(nw.Current.Sunrise)
+		if nil != err {
+			return sourceError(`weather`, `sunrise`, err)
+		}
+		ss, err := parseTime(nw.Current.Sunset)
+		if nil != err {
+			return sourceError(`weather`, `sunset`, err)
+		}
+		sunMux.Lock()
+		sunrise, sunset = sr, ss
+		sunMux.Unlock()
 	}
 	lastHorizon = test
 
-	refI := daymode
-	check, _ := parseTime(time.Now().Format("03:04 PM"))
-	if check.After(sunrise) && check.Before(sunset.Add(-time.Minute)) {
-		daymode = daylight{daybright, true}
-		evut = nextEvent(check, sunset)
-	} else {
-		daymode = daylight{nightbright, false}
-		nooner, _ := parseTime(`11:59 AM`)
-		if check.Before(nooner) {
-			evut = nextEvent(check, sunrise)
-		} else {
-			evut = nextEvent(check, sunrise.Add(24*time.Hour))
+	// a little tweaking
+	nw.Current.Temperature = strings.Replace(nw.Current.Temperature, ".0", "", -1)
+	nw.Current.Humidity = strings.Replace(nw.Current.Humidity, `%`, ``, -1)
+	nw.Current.tempF, _ = strconv.ParseFloat(temps[0], 64)
+	nw.Current.tempC, _ = strconv.ParseFloat(temps[1], 64)
+
+	// keep the price trend across payloads
+	nw.Current.trend, nw.Current.trendColor = w.Current.trend, w.Current.trendColor
+	if lastPrice != nw.Current.Price {
+		trend := ``
+		nw.Current.trendColor = `#ffff00`
+		if nw.Current.Price > lastPrice {
+			trend = `▲`
+			nw.Current.trendColor = `#00ff00`
+		} else if nw.Current.Price < lastPrice {
+			trend = `▼`
+			nw.Current.trendColor = `#ff0000`
+
+		}
+		trend = ``
+		nw.Current.trend = fmt.Sprintf("%s%.2f", trend, nw.Current.Price)
+		lastPrice = nw.Current.Price
+	}
+
+	weatherMux.Lock()
+	w = nw
+	weatherMux.Unlock()
+
+	boundary := updateDaylight(now)
+
+	hr, _, _ := now.Clock()
+
+	lastHour = hr
+
+	cacheWeatherIcons(boundary)
+
+	return nil
+
+}
+
+// iconsMux guards the weather icon caches the face draws, iconRender keeps
+// the fetch and a reload from rendering them at the same time
+var (
+	iconsMux   sync.RWMutex
+	iconRender sync.Mutex
+)
+
+// cacheWeatherIcons renders the current icons, boundary forces a refresh
+// at dawn and dusk, icons keep their last good image when a render fails
+func cacheWeatherIcons(boundary bool) {
+	iconRender.Lock()
+	defer iconRender.Unlock()
+	renderWeatherIcons(boundary)
+}
+
+// renderWeatherIcons renders off to the side and swaps the caches in,
+// iconRender must be held
+func renderWeatherIcons(boundary bool) {
+
+	iconFailed := func(icon string, err error) {
+		if nil != err {
+			logWeather.Warn(`icon render failed`, `icon`, icon, `err`, err)
 		}
 	}
 
+	wc := currentWeather().Current
+	now, dp1, dp2, dp3, dp4, wind, thermo := imIcon, imIconDP1, imIconDP2, imIconDP3, imIconDP4, imWindDir, imThermo
+
 	// force refresh on boundary - for conditions that have a noctuque in use - icon-33 24 hr issue
-	if refI != daymode {
-		imIcon, _ = cacheImage(`icon-0`, imIcon, 0.00, ``)
+	if boundary {
+		now, _ = cacheImage(`icon-0`, now, 0.00, ``)
 	}
-	imIcon, err = cacheImage(w.Current.Icon, imIcon, 0.00, ``)
+	var err error
+	now, err = cacheImage(wc.Icon, now, 0.00, ``)
 	// if throws Param mismatch error - check the SVG contains no "none" attributes
-	checkFatal(err)
+	iconFailed(wc.Icon, err)
 	// debug here
 	if snap {
 		ft, err := os.Create("test.png")
-		checkFatal(err)
-		defer ft.Close()
-		png.Encode(ft, imIcon.image)
+		if nil == err {
+			defer ft.Close()
+			png.Encode(ft, now.image)
+		}
 		snap = false
 	}
 
-	imIconDP1, err = cacheImage(w.Current.Daypart1.Icon, imIconDP1, 0.35, ``)
-	checkFatal(err)
+	dp1, err = cacheImage(wc.Daypart1.Icon, dp1, 0.35, ``)
+	iconFailed(wc.Daypart1.Icon, err)
 
-	imIconDP2, err = cacheImage(w.Current.Daypart2.Icon, imIconDP2, 0.35, ``)
-	checkFatal(err)
+	dp2, err = cacheImage(wc.Daypart2.Icon, dp2, 0.35, ``)
+	iconFailed(wc.Daypart2.Icon, err)
 
-	imIconDP3, err = cacheImage(w.Current.Daypart3.Icon, imIconDP3, 0.35, ``)
-	checkFatal(err)
+	dp3, err = cacheImage(wc.Daypart3.Icon, dp3, 0.35, ``)
+	iconFailed(wc.Daypart3.Icon, err)
 
-	imIconDP4, err = cacheImage(w.Current.Daypart4.Icon, imIconDP4, 0.35, ``)
-	checkFatal(err)
+	dp4, err = cacheImage(wc.Daypart4.Icon, dp4, 0.35, ``)
+	iconFailed(wc.Daypart4.Icon, err)
 
 	/*
 			if w.Current.Beafort != lastWindIcon {
@@ -223,39 +333,47 @@ func weather() {
 			lastWindIcon = w.Current.Beafort
 	*/
 
-	test = fmt.Sprintf("wind-%s", strings.Split(w.Current.Wind, " ")[0])
-	imWindDir, err = cacheImage(test, imWindDir, 0.00, ``)
-	checkFatal(err)
+	test := fmt.Sprintf("wind-%s", strings.Split(wc.Wind, " ")[0])
+	wind, err = cacheImage(test, wind, 0.00, ``)
+	iconFailed(test, err)
 
-	hr, _, _ := time.Now().Clock()
+	tx := int(float64(clockw) * 0.4)
+	thermo, err = cacheThermo(strconv.FormatFloat(wc.tempF, 'f', -1, 64), thermo, tx, tx)
+	iconFailed(`thermometer`, err)
 
-	lastHour = hr
+	iconsMux.Lock()
+	imIcon, imIconDP1, imIconDP2, imIconDP3, imIconDP4, imWindDir, imThermo = now, dp1, dp2, dp3, dp4, wind, thermo
+	iconsMux.Unlock()
 
-	// a little tweaking
-	w.Current.Humidity = strings.Replace(w.Current.Humidity, `%`, ``, -1)
+}
 
-	re := regexp.MustCompile(`[-]?\d[\d,]*[\.]?[\d{2}]*`)
-	temps := re.FindAllString(w.Current.Temperature, -1)
-	w.Current.tempF, _ = strconv.ParseFloat(temps[0], 64)
-	w.Current.tempC, _ = strconv.ParseFloat(temps[1], 64)
+// renderFixedIcons the precipitation and humidity icons, iconRender must
+// be held
+func renderFixedIcons() {
 
-	tx := int(float64(clockw) * 0.4)
-	imThermo, err = cacheThermo(temps[0], imThermo, tx, tx)
+	precip, _ := cacheImage(`brolly`, imPrecip, 0.00, ``)
+	humid, _ := cacheImage(`humidity`, imHumid, 0.00, ``)
+	iconsMux.Lock()
+	imPrecip, imHumid = precip, humid
+	iconsMux.Unlock()
 
-	if lastPrice != w.Current.Price {
-		trend := ``
-		w.Current.trendColor = `#ffff00`
-		if w.Current.Price > lastPrice {
-			trend = `▲`
-			w.Current.trendColor = `#00ff00`
-		} else if w.Current.Price < lastPrice {
-			trend = `▼`
-			w.Current.trendColor = `#ff0000`
+}
 
-		}
-		trend = ``
-		w.Current.trend = fmt.Sprintf("%s%.2f", trend, w.Current.Price)
-		lastPrice = w.Current.Price
+// rescaleWeatherIcons re-renders every icon after a layout change, the
+// face keeps drawing the old ones until the new set is swapped in
+func rescaleWeatherIcons() {
+
+	mapInit()
+	iconRender.Lock()
+	defer iconRender.Unlock()
+	for _, ic := range []*iconCache{&imIcon, &imIconDP1, &imIconDP2, &imIconDP3, &imIconDP4, &imWindDir, &imThermo, &imPrecip, &imHumid} {
+		iconsMux.Lock()
+		ic.last = ``
+		iconsMux.Unlock()
+	}
+	renderFixedIcons()
+	if `` != currentWeather().Current.Icon {
+		renderWeatherIcons(false)
 	}
 
 }
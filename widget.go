@@ -0,0 +1,210 @@
+package main
+
+import (
+	"image"
+	"sort"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+type (
+	// Widget is a display module placed into a layout region
+	Widget interface {
+		// Start and Stop manage any background updates
+		Start()
+		Stop()
+		// PreferredSize is the natural size, zero fills the region
+		PreferredSize() image.Point
+		// Dirty reports a change since the last Render
+		Dirty() bool
+		Visible() bool
+		// Render draws into the region rect
+		Render(dst *gg.Context, rg *Region)
+	}
+
+	// WidgetFunc adapts a plain render function, always visible and dirty
+	WidgetFunc func(dst *gg.Context, rg *Region)
+
+	// WidgetRegistry maps layout regions to their widgets
+	WidgetRegistry struct {
+		widgets map[string]Widget
+		order   []string
+		started bool
+	}
+
+	// tickWidget redraws a render function once per period
+	tickWidget struct {
+		render  WidgetFunc
+		visible func() bool
+		period  time.Duration
+		last    time.Time
+	}
+
+	// imageWidget places a module glyph at the region anchor
+	imageWidget struct {
+		image   func() image.Image
+		visible func() bool
+		stop    func()
+		last    image.Image
+	}
+)
+
+// Start does nothing
+func (wf WidgetFunc) Start() {}
+
+// Stop does nothing
+func (wf WidgetFunc) Stop() {}
+
+// PreferredSize fills the region
+func (wf WidgetFunc) PreferredSize() image.Point { return image.ZP }
+
+// Dirty always
+func (wf WidgetFunc) Dirty() bool { return true }
+
+// Visible always
+func (wf WidgetFunc) Visible() bool { return true }
+
+// Render calls the function
+func (wf WidgetFunc) Render(dst *gg.Context, rg *Region) { wf(dst, rg) }
+
+// NewWidgetRegistry creates an empty registry
+func NewWidgetRegistry() *WidgetRegistry {
+	return &WidgetRegistry{
+		widgets: make(map[string]Widget),
+	}
+}
+
+// Register binds a widget to the named region, replacing any existing
+func (wr *WidgetRegistry) Register(region string, w Widget) {
+	if _, ok := wr.widgets[region]; !ok {
+		wr.order = append(wr.order, region)
+	}
+	wr.widgets[region] = w
+	if wr.started {
+		w.Start()
+	}
+}
+
+// RegisterAll binds a module's widgets, regions in name order
+func (wr *WidgetRegistry) RegisterAll(ws map[string]Widget) {
+	names := make([]string, 0, len(ws))
+	for n := range ws {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		wr.Register(n, ws[n])
+	}
+}
+
+// Widget returns the widget bound to the region, nil if none
+func (wr *WidgetRegistry) Widget(region string) Widget {
+	return wr.widgets[region]
+}
+
+// Visible reports the region has a visible widget
+func (wr *WidgetRegistry) Visible(region string) bool {
+	w, ok := wr.widgets[region]
+	return ok && w.Visible()
+}
+
+// Dirty reports any visible widget of the named regions has changed
+func (wr *WidgetRegistry) Dirty(names ...string) bool {
+	for _, n := range names {
+		if w, ok := wr.widgets[n]; ok && w.Visible() && w.Dirty() {
+			return true
+		}
+	}
+	return false
+}
+
+// Start starts all widgets in registration order
+func (wr *WidgetRegistry) Start() {
+	if wr.started {
+		return
+	}
+	for _, n := range wr.order {
+		wr.widgets[n].Start()
+	}
+	wr.started = true
+}
+
+// Stop stops all widgets in reverse registration order
+func (wr *WidgetRegistry) Stop() {
+	if !wr.started {
+		return
+	}
+	for i := len(wr.order) - 1; i >= 0; i-- {
+		wr.widgets[wr.order[i]].Stop()
+	}
+	wr.started = false
+}
+
+// Start does nothing, modules update themselves
+func (iw *imageWidget) Start() {}
+
+// Stop calls the module stop, if any
+func (iw *imageWidget) Stop() {
+	if nil != iw.stop {
+		iw.stop()
+	}
+}
+
+// PreferredSize is the glyph size
+func (iw *imageWidget) PreferredSize() image.Point {
+	if im := iw.image(); nil != im {
+		return im.Bounds().Size()
+	}
+	return image.ZP
+}
+
+// Dirty when the module has replaced the glyph
+func (iw *imageWidget) Dirty() bool {
+	return iw.image() != iw.last
+}
+
+// Visible per the module
+func (iw *imageWidget) Visible() bool {
+	return nil == iw.visible || iw.visible()
+}
+
+// Render places the glyph at the region anchor
+func (iw *imageWidget) Render(dst *gg.Context, rg *Region) {
+	iw.last = iw.image()
+	rg.DrawImage(dst, iw.last)
+}
+
+// Every adapts a render function redrawn once per period, a zero period
+// draws once; visible is optional
+func Every(period time.Duration, visible func() bool, render WidgetFunc) Widget {
+	return &tickWidget{render: render, visible: visible, period: period}
+}
+
+// Start does nothing
+func (tw *tickWidget) Start() {}
+
+// Stop does nothing
+func (tw *tickWidget) Stop() {}
+
+// PreferredSize fills the region
+func (tw *tickWidget) PreferredSize() image.Point { return image.ZP }
+
+// Dirty once per period
+func (tw *tickWidget) Dirty() bool {
+	if tw.period <= 0 {
+		return tw.last.IsZero()
+	}
+	return !tw.last.Equal(frameTime().Truncate(tw.period))
+}
+
+// Visible per the visible func
+func (tw *tickWidget) Visible() bool {
+	return nil == tw.visible || tw.visible()
+}
+
+// Render calls the function
+func (tw *tickWidget) Render(dst *gg.Context, rg *Region) {
+	tw.last = frameTime().Truncate(tw.period)
+	tw.render(dst, rg)
+}